@@ -44,3 +44,22 @@ $(function () {
         }
     }
 });
+
+// Submit the current plan with a form, e.g. to change check parameters
+function setPlanText(form){
+    form.plantext.value = decodeURIComponent(escape(atob(planTextBase64)));
+}
+
+// Copy the remediation SQL to the clipboard
+function copyRemediation(button){
+    var sql = document.getElementById('remediation-sql');
+    var range = document.createRange();
+    range.selectNodeContents(sql);
+    var selection = window.getSelection();
+    selection.removeAllRanges();
+    selection.addRange(range);
+    if (document.execCommand('copy')) {
+        $(button).text('Copied');
+    }
+    selection.removeAllRanges();
+}
@@ -1,25 +1,108 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/stephendotcarter/planchecker/plan"
+	"io/ioutil"
 	"os"
+	"strings"
 )
 
+// Repeatable flag
+type paramFlags []string
+
+func (p *paramFlags) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *paramFlags) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
 func main() {
+	var params paramFlags
+	var rules paramFlags
+	var waivers paramFlags
+	profile := flag.String("profile", "", "YAML or JSON file with check parameters")
+	enable := flag.String("enable", "", "Comma separated check ids to run, all if empty")
+	disable := flag.String("disable", "", "Comma separated check ids not to run")
+	sql := flag.String("sql", "", "Write the remediation SQL script to a file, - for stdout")
+	flag.Var(&params, "param", "Override a check parameter, name=value (repeatable)")
+	flag.Var(&rules, "rules", "YAML or JSON file with rule checks (repeatable)")
+	flag.Var(&waivers, "waivers", "YAML or JSON file with waivers (repeatable)")
+	flag.Parse()
+
 	// Read filename from arguments
-	filename := os.Args[1]
+	filename := flag.Arg(0)
+
+	// Add the rule checks before selecting checks so they can be enabled by id
+	for _, filename := range rules {
+		if err := plan.LoadRuleFile(filename); err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Load the check parameters
+	checkConfig := plan.CheckConfig{
+		Enabled:  plan.ParseCheckIds(*enable),
+		Disabled: plan.ParseCheckIds(*disable),
+		Params:   plan.NewParams(),
+	}
+	if *profile != "" {
+		p, err := plan.LoadParams(*profile)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+		checkConfig.Params = p
+	}
+	for _, param := range params {
+		if err := checkConfig.Params.SetOverride(param); err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, filename := range waivers {
+		w, err := plan.LoadWaiverFile(filename)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+		checkConfig.Waivers = append(checkConfig.Waivers, w...)
+	}
+	if err := checkConfig.Validate(); err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
 
 	// Create new explain object
 	var explain plan.Explain
 
-	// Init the explain from filename
-	err := explain.InitFromFile(filename, true)
+	// Init the explain from filename, without debug output when only printing SQL
+	err := explain.InitFromFile(filename, *sql != "-")
 	if err != nil {
 		fmt.Printf("%s\n", err)
 		os.Exit(1)
 	}
 
+	// Run the checks with the parameters
+	explain.ApplyFindings(explain.CheckWithConfig(checkConfig))
+
+	// Only print the remediation SQL if requested
+	if *sql == "-" {
+		fmt.Print(explain.RemediationScript())
+		return
+	}
+	if *sql != "" {
+		if err := ioutil.WriteFile(*sql, []byte(explain.RemediationScript()), 0644); err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Print Plan
 	explain.PrintPlan()
 }
@@ -18,6 +18,9 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Run the checks and attach the warnings to the nodes
+	explain.ApplyFindings(explain.Check())
+
 	// Print Plan
 	explain.PrintPlan()
 }
@@ -6,4 +6,5 @@ require (
 	github.com/gorilla/context v0.0.0-20160422134237-a8d44e7d8e4d // indirect
 	github.com/gorilla/mux v0.0.0-20160502175624-9c19ed558d5d
 	github.com/lib/pq v0.0.0-20160316202507-3cd0097429be
+	gopkg.in/yaml.v2 v2.4.0
 )
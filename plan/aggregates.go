@@ -0,0 +1,298 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Aggregation and sort analysis
+//
+// Greenplum aggregates in two phases when it pays off: each segment
+// aggregates its own rows first and only the partial groups are
+// redistributed on the grouping columns:
+//
+//	->  HashAggregate                       final phase
+//	      Group By: t.b
+//	      ->  Redistribute Motion 4:4
+//	            Hash Key: t.b
+//	            ->  HashAggregate           partial phase
+//	                  Group By: t.b
+//	                  ->  Seq Scan on t
+//
+// Without the partial phase every row crosses the interconnect. The checks
+// in this file report:
+//   - agg-groups-underestimated: aggregates producing far more groups than
+//     estimated, sized too small and likely to spill
+//   - two-phase-agg-missing: rows redistributed to an aggregate that reduces
+//     them a lot, without aggregating on the segments first
+//   - sort-wide-rows: sorts of wide rows, which need much more memory
+//   - sort-repeated: rows sorted again on the keys a sort below already
+//     sorted them by
+//   - distinct-redistribute: DISTINCT, UNION and other set operations
+//     redistributing all rows to remove duplicates
+//
+// Only aggregates and sorts processing sort_agg_min_rows rows per segment
+// or more are reported.
+
+// Grouping columns of an aggregate, empty for a plain Aggregate
+func (n *Node) GroupKey() string {
+	if key := n.Condition("Group By"); key != "" {
+		return key
+	}
+	return n.Condition("Group Key")
+}
+
+// Sort key of a Sort node, including the "Sort Key (Distinct)" of a DISTINCT
+func (n *Node) SortKey() string {
+	if key := n.Condition("Sort Key"); key != "" {
+		return key
+	}
+	return n.Condition("Sort Key (Distinct)")
+}
+
+// Column names of a key list without the table qualifier, e.g. "a, b" for
+// "t.a, t.b"
+func keyColumns(key string) []string {
+	columns := []string{}
+	for _, k := range strings.Split(key, ", ") {
+		columns = append(columns, lastIdentifier(strings.TrimSpace(k)))
+	}
+	return columns
+}
+
+// True if the columns start with all the prefix columns
+func hasColumnPrefix(columns []string, prefix []string) bool {
+	if len(prefix) == 0 || len(prefix) > len(columns) {
+		return false
+	}
+	for i := range prefix {
+		if columns[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Find the closest Sort below a node, nil if there is none
+func sortBelow(n *Node) *Node {
+	var found *Node
+	for _, d := range n.Descendants() {
+		if d.IsType(NodeTypeSort) && d.SortKey() != "" {
+			found = d
+			break
+		}
+	}
+	return found
+}
+
+// True if an aggregate is already fed by a partial aggregate below the motion
+func isPartiallyAggregated(motion *Node) bool {
+	for c := motion; len(c.SubNodes) == 1; {
+		c = c.SubNodes[0]
+		if c.Category == CategoryAggregate {
+			return true
+		}
+		if c.IsType(NodeTypeResult, NodeTypeSubqueryScan, NodeTypeSort) == false {
+			return false
+		}
+	}
+	return false
+}
+
+// True for nodes removing duplicate rows: Unique, SetOp, sorts for DISTINCT
+// and aggregates on top of a UNION
+func isDistinct(n *Node) bool {
+	if n.IsType(NodeTypeUnique, NodeTypeSetOp) {
+		return true
+	}
+	if n.IsType(NodeTypeSort) && n.Condition("Sort Key (Distinct)") != "" {
+		return true
+	}
+	return n.Category == CategoryAggregate && len(n.SubNodes) == 1 && n.SubNodes[0].IsType(NodeTypeAppend)
+}
+
+// Redistribute Motions feeding a node removing duplicates, one per input of
+// a UNION
+func distinctMotions(n *Node) []*Node {
+	inputs := []*Node{n}
+	for c := n; len(c.SubNodes) == 1; c = c.SubNodes[0] {
+		if c.SubNodes[0].IsType(NodeTypeAppend) {
+			inputs = c.SubNodes[0].SubNodes
+			break
+		}
+		if c.SubNodes[0].IsType(NodeTypeResult, NodeTypeSubqueryScan, NodeTypeSort) == false {
+			break
+		}
+	}
+
+	motions := []*Node{}
+	for _, input := range inputs {
+		if input.IsType(NodeTypeRedistributeMotion, NodeTypeExplicitRedistributeMotion) {
+			motions = append(motions, input)
+		} else if m := redistributeBelow(input); m != nil {
+			motions = append(motions, m)
+		}
+	}
+	return motions
+}
+
+// Statement forcing a two-phase aggregate for the optimizer that produced the plan
+func multiphaseAggRemediation(e *Explain) []string {
+	if e != nil && e.Optimizer == "off" {
+		return []string{"SET gp_enable_multiphase_agg = on;"}
+	}
+	return []string{"SET optimizer_force_multistage_agg = on;"}
+}
+
+var aggregateChecks = []NodeCheck{
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "agg-groups-underestimated",
+			Name:          "checkNodeAggGroupsUnderestimated",
+			Description:   "Aggregate producing far more groups than estimated",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryStatistics,
+			Severity:      SeverityWarning,
+			Documentation: "The aggregate produced agg_groups_factor times more groups than estimated. The number of groups comes from the number of distinct values in the statistics; when it is too low the hash table is sized too small and spills, and a single-phase plan may be chosen when two phases would be cheaper.",
+			Parameters:    []string{"agg_groups_factor", "sort_agg_min_rows"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeHashAggregate, NodeTypeGroupAggregate) == false || n.GroupKey() == "" {
+				return
+			}
+			if n.ActualRowsPerSeg.Valid == false || n.ActualRowsPerSeg.Value < f.Params.Float("sort_agg_min_rows") {
+				return
+			}
+			if n.ActualRowsPerSeg.Value < float64(n.Rows)*f.Params.Float("agg_groups_factor") {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:       fmt.Sprintf("%.0f groups per segment on (%s), estimated %d", n.ActualRowsPerSeg.Value, n.GroupKey(), n.Rows),
+				Resolution:  "Refresh the statistics of the grouping columns, or raise their statistics target",
+				Remediation: analyzeRemediation(n)})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "two-phase-agg-missing",
+			Name:          "checkNodeTwoPhaseAggMissing",
+			Description:   "Rows redistributed to an aggregate without a partial aggregate",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryInterconnect,
+			Severity:      SeverityWarning,
+			Documentation: "All rows are redistributed on the grouping columns and then reduced agg_reduction_factor times or more by the aggregate. Aggregating on each segment before the motion would only move the partial groups. The optimizer picks a single phase when it expects few rows per group, usually from missing statistics.",
+			Parameters:    []string{"agg_reduction_factor", "sort_agg_min_rows"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeHashAggregate, NodeTypeGroupAggregate) == false || n.GroupKey() == "" {
+				return
+			}
+			motion := redistributeBelow(n)
+			if motion == nil || isPartiallyAggregated(motion) {
+				return
+			}
+			in := motion.rowsPerSegment()
+			out := n.rowsPerSegment()
+			if in < f.Params.Float("sort_agg_min_rows") || in < out*f.Params.Float("agg_reduction_factor") {
+				return
+			}
+			remediation := analyzeRemediation(motion)
+			remediation = append(remediation, multiphaseAggRemediation(f.Explain)...)
+			f.AddNodeWarning(n, Warning{
+				Cause:       fmt.Sprintf("%.0f rows per segment are redistributed by %s (#%d), moving %s, and aggregated to %.0f groups", in, motion.Operator, motion.Id, motionVolume(motion), out),
+				Resolution:  "Refresh the statistics so the rows are aggregated on each segment before the motion",
+				Remediation: remediation})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "sort-wide-rows",
+			Name:          "checkNodeSortWideRows",
+			Description:   "Sort of very wide rows",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryMemory,
+			Severity:      SeverityWarning,
+			Documentation: "The sorted rows are sort_width bytes wide or more. The whole row is kept in memory and written to workfiles while sorting, so wide rows fill work_mem quickly. Sorting only the keys, and joining the other columns afterwards, keeps the sort small.",
+			Parameters:    []string{"sort_width", "sort_agg_min_rows"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeSort) == false || n.Width < f.Params.Int("sort_width") {
+				return
+			}
+			if n.rowsPerSegment() < f.Params.Float("sort_agg_min_rows") {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("Sort of %.0f rows %d bytes wide, %s per segment", n.rowsPerSegment(), n.Width, n.bytesPerSegment()),
+				Resolution: "Select only the columns needed, or sort the keys and join the wide columns afterwards"})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "sort-repeated",
+			Name:          "checkNodeSortRepeated",
+			Description:   "Rows sorted again on the same key",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryExecution,
+			Severity:      SeverityWarning,
+			Documentation: "A Sort below already sorted the rows on the same or longer key, but a motion or join in between lost the order. Sorting twice doubles the work; a Gather Motion with a Merge Key, or sorting only once at the top, keeps one sort.",
+			Parameters:    []string{"sort_agg_min_rows"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeSort) == false || n.SortKey() == "" {
+				return
+			}
+			below := sortBelow(n)
+			if below == nil || hasColumnPrefix(keyColumns(below.SortKey()), keyColumns(n.SortKey())) == false {
+				return
+			}
+			if n.rowsPerSegment() < f.Params.Float("sort_agg_min_rows") {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("Rows sorted again on (%s), already sorted by Sort (#%d) on (%s)", n.SortKey(), below.Id, below.SortKey()),
+				Resolution: "Check why the order is lost in between, so the rows are sorted once"})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "distinct-redistribute",
+			Name:          "checkNodeDistinctRedistribute",
+			Description:   "DISTINCT or UNION redistributing all rows",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryInterconnect,
+			Severity:      SeverityWarning,
+			Documentation: "Removing duplicates needs equal rows on the same segment, so every row of a DISTINCT, UNION, INTERSECT or EXCEPT is redistributed on all its columns unless the input is distributed on them. UNION ALL does not remove duplicates and needs no motion.",
+			Parameters:    []string{"sort_agg_min_rows"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			if isDistinct(n) == false {
+				return
+			}
+			rows := 0.0
+			bytes := ByteSize(0)
+			motions := distinctMotions(n)
+			for _, m := range motions {
+				rows += m.rowsPerSegment()
+				bytes += motionMaxBytes(m)
+			}
+			if len(motions) == 0 || rows < f.Params.Float("sort_agg_min_rows") {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("%.0f rows per segment are redistributed by %s to remove duplicates, an estimated %s", rows, plural(len(motions), "motion"), bytes),
+				Resolution: "Use UNION ALL when duplicates are not possible, or remove the duplicates on fewer columns"})
+		}},
+}
+
+func init() {
+	for _, c := range aggregateChecks {
+		RegisterNodeCheck(c)
+	}
+}
@@ -0,0 +1,141 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroupAndSortKeys(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain12.txt")
+	if got := e.Nodes[1].GroupKey(); got != "hd.recorded_date, hd.str_cd, dt.prd_cd_1, hd.card_no" {
+		t.Errorf("GroupAggregate group key = %q", got)
+	}
+	if got := e.Nodes[2].SortKey(); got != "hd.recorded_date, hd.str_cd, dt.prd_cd_1, hd.card_no" {
+		t.Errorf("Sort key = %q", got)
+	}
+	if got := keyColumns(e.Nodes[2].SortKey()); strings.Join(got, " ") != "recorded_date str_cd prd_cd_1 card_no" {
+		t.Errorf("key columns = %q", got)
+	}
+	if sortBelow(e.Nodes[2]) != e.Nodes[5] {
+		t.Errorf("Sort below #2 is not #5")
+	}
+
+	// A plain Aggregate has no group key
+	if got := loadTestExplain(t, "../testdata/explain19.txt").Nodes[0].GroupKey(); got != "" {
+		t.Errorf("Aggregate group key = %q", got)
+	}
+}
+
+func TestHasColumnPrefix(t *testing.T) {
+	tests := []struct {
+		columns []string
+		prefix  []string
+		want    bool
+	}{
+		{[]string{"a", "b"}, []string{"a"}, true},
+		{[]string{"a", "b"}, []string{"a", "b"}, true},
+		{[]string{"a", "b"}, []string{"b"}, false},
+		{[]string{"a"}, []string{"a", "b"}, false},
+		{[]string{"a"}, []string{}, false},
+	}
+
+	for _, test := range tests {
+		if got := hasColumnPrefix(test.columns, test.prefix); got != test.want {
+			t.Errorf("hasColumnPrefix(%v, %v) = %v, want %v", test.columns, test.prefix, got, test.want)
+		}
+	}
+}
+
+// Warnings of the aggregate and sort checks on testdata with the default thresholds
+func TestAggregateChecksTestdata(t *testing.T) {
+	got := testdataWarnings(t, "agg-groups-underestimated", "two-phase-agg-missing", "sort-wide-rows", "sort-repeated", "distinct-redistribute")
+	compareTestdataWarnings(t, got, map[string][]string{
+		// Both phases of the GROUP BY were estimated to produce 1 row, the
+		// final phase sorts again on the keys of the partial phase
+		"explain12.txt": {"#1 agg-groups-underestimated", "#2 sort-repeated", "#4 agg-groups-underestimated"},
+	})
+}
+
+func TestAggregateCheckCauses(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain12.txt")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"agg-groups-underestimated", "sort-repeated"}})
+	want := "71116 groups per segment on (hd.recorded_date, hd.str_cd, dt.prd_cd_1, hd.card_no), estimated 1"
+	if w := f.NodeWarnings[e.Nodes[1]]; len(w) != 1 || w[0].Cause != want {
+		t.Errorf("agg-groups-underestimated warnings = %+v, want %q", w, want)
+	}
+	want = "Rows sorted again on (hd.recorded_date, hd.str_cd, dt.prd_cd_1, hd.card_no), already sorted by Sort (#5) on (hd.recorded_date, hd.str_cd, dt.prd_cd_1, hd.card_no)"
+	if w := f.NodeWarnings[e.Nodes[2]]; len(w) != 1 || w[0].Cause != want {
+		t.Errorf("sort-repeated warnings = %+v, want %q", w, want)
+	}
+
+	// The Sort below the final GroupAggregate is 338 bytes wide
+	p := NewParams()
+	p.Set("sort_width", 300)
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"sort-wide-rows"}, Params: p})
+	want = "Sort of 71449 rows 338 bytes wide, 23.0 MB per segment"
+	if w := f.NodeWarnings[e.Nodes[2]]; len(w) != 1 || len(f.NodeWarnings) != 1 || w[0].Cause != want {
+		t.Errorf("sort-wide-rows warnings = %+v, want %q", f.NodeWarnings, want)
+	}
+
+	// The partial GroupAggregate #4 reduces the rows of Redistribute Motion #6
+	// less than 10 times
+	p = NewParams()
+	p.Set("agg_reduction_factor", 1)
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"two-phase-agg-missing"}, Params: p})
+	w := f.NodeWarnings[e.Nodes[4]]
+	if len(w) != 1 || len(f.NodeWarnings) != 1 || strings.HasPrefix(w[0].Cause, "74913 rows per segment are redistributed by Redistribute Motion 40:40 (#6)") == false {
+		t.Fatalf("two-phase-agg-missing warnings = %+v", f.NodeWarnings)
+	}
+	// Planned with optimizer=off
+	if strings.Join(w[0].Remediation, " ") != "SET gp_enable_multiphase_agg = on;" {
+		t.Errorf("remediation = %q", w[0].Remediation)
+	}
+}
+
+// explain11 aggregates on the segments before the Redistribute Motion #4
+func TestTwoPhaseAggMissing(t *testing.T) {
+	p := NewParams()
+	p.Set("agg_reduction_factor", 1)
+	e := loadTestExplain(t, "../testdata/explain11.txt")
+	if f := e.CheckWithConfig(CheckConfig{Enabled: []string{"two-phase-agg-missing"}, Params: p}); len(f.NodeWarnings) != 0 {
+		t.Errorf("two-phase-agg-missing reported for a two-phase aggregate: %+v", f.NodeWarnings)
+	}
+
+	// Without the partial aggregate the final one is reported
+	e = loadEditedExplain(t, "../testdata/explain11.txt",
+		"->  HashAggregate  (cost=0.00..5425.97", "->  Materialize  (cost=0.00..5425.97")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"two-phase-agg-missing"}, Params: p})
+	w := f.NodeWarnings[e.Nodes[3]]
+	if len(w) != 1 || len(f.NodeWarnings) != 1 || strings.Join(w[0].Remediation, " ") != "SET optimizer_force_multistage_agg = on;" {
+		t.Errorf("two-phase-agg-missing warnings = %+v", f.NodeWarnings)
+	}
+}
+
+// explain12 with the final Sort removing duplicates
+func TestDistinctRedistribute(t *testing.T) {
+	e := loadEditedExplain(t, "../testdata/explain12.txt",
+		"Sort Key: hd.recorded_date", "Sort Key (Distinct): hd.recorded_date")
+	if isDistinct(e.Nodes[2]) == false || e.Nodes[2].SortKey() == "" {
+		t.Fatalf("Sort (Distinct) not recognised")
+	}
+	if motions := distinctMotions(e.Nodes[2]); len(motions) != 1 || motions[0] != e.Nodes[3] {
+		t.Errorf("distinct motions = %v", motions)
+	}
+
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"distinct-redistribute"}})
+	want := "71449 rows per segment are redistributed by 1 motion to remove duplicates, an estimated 921.2 MB"
+	if w := f.NodeWarnings[e.Nodes[2]]; len(w) != 1 || len(f.NodeWarnings) != 1 || w[0].Cause != want {
+		t.Errorf("distinct-redistribute warnings = %+v, want %q", f.NodeWarnings, want)
+	}
+
+	// The Aggregate over the Append of explain19 has no motion below
+	if motions := distinctMotions(loadTestExplain(t, "../testdata/explain19.txt").Nodes[2]); len(motions) != 0 {
+		t.Errorf("distinct motions of explain19 = %v", motions)
+	}
+}
+
+func TestMultiphaseAggRemediation(t *testing.T) {
+	if got := multiphaseAggRemediation(nil); strings.Join(got, " ") != "SET optimizer_force_multistage_agg = on;" {
+		t.Errorf("remediation without a plan = %q", got)
+	}
+}
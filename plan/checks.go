@@ -0,0 +1,227 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Check registry
+//
+// Every check is described by a CheckInfo. The Id is stable: it is used to
+// enable or disable checks and is attached to every warning the check
+// produces, so it must not change or be reused once released.
+//
+// Checks are kept in NODECHECKS and EXPLAINCHECKS. Checks defined outside
+// plan.go are added with RegisterNodeCheck() and RegisterExplainCheck()
+// which reject duplicate Ids.
+//
+// Scope lists the optimizers ("orca", "legacy") and Dialects the databases
+// the check applies to. They are used to select checks with CheckConfig.
+
+// How serious a warning is
+type Severity int
+
+const (
+	SeverityInfo Severity = iota + 1
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:     "info",
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+// Area of the plan a check looks at
+type CheckCategory string
+
+const (
+	CheckCategoryStatistics   CheckCategory = "statistics"
+	CheckCategoryJoins        CheckCategory = "joins"
+	CheckCategoryMemory       CheckCategory = "memory"
+	CheckCategoryExecution    CheckCategory = "execution"
+	CheckCategoryPartitioning CheckCategory = "partitioning"
+	CheckCategorySkew         CheckCategory = "skew"
+	CheckCategoryPredicates   CheckCategory = "predicates"
+	CheckCategoryComplexity   CheckCategory = "complexity"
+	CheckCategoryOptimizer    CheckCategory = "optimizer"
+	CheckCategorySettings     CheckCategory = "settings"
+	CheckCategoryInterconnect CheckCategory = "interconnect"
+	CheckCategoryCustom       CheckCategory = "custom" // Default for rule checks
+)
+
+// Databases producing plans that can be checked
+const (
+	DialectGreenplum = "greenplum"
+	DialectHawq      = "hawq"
+)
+
+// Describes a check
+type CheckInfo struct {
+	Id            string // Stable identifier, e.g. "spill-files"
+	Name          string
+	Description   string
+	CreatedAt     string
+	Scope         []string // Optimizers the check applies to
+	Dialects      []string // Databases the check applies to
+	Category      CheckCategory
+	Severity      Severity // Severity of the warnings produced
+	Documentation string   // Why the check matters and how to act on it
+	Parameters    []string // Names of the parameters used by the check, see params.go
+}
+
+type NodeCheck struct {
+	CheckInfo
+	Exec func(*Node, *Findings)
+}
+
+type ExplainCheck struct {
+	CheckInfo
+	Exec func(*Explain, *Findings)
+}
+
+// Selects which checks to run
+type CheckConfig struct {
+	Enabled   []string // Only run these check Ids, all checks run if empty
+	Disabled  []string // Never run these check Ids
+	Optimizer string   // Only run checks with this optimizer in Scope, any if empty
+	Dialect   string   // Only run checks with this dialect in Dialects, any if empty
+	Params    *Params  // Thresholds used by the checks, defaults if nil
+	Waivers   []Waiver // Suppress matching warnings, in addition to waivers in the query
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Parse a severity name, e.g. "critical"
+func ParseSeverity(name string) (Severity, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for s, n := range severityNames {
+		if n == name {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// Add a node check.
+// Panics if the Id is empty or already registered.
+func RegisterNodeCheck(c NodeCheck) {
+	mustBeNewCheckId(c.Id)
+	NODECHECKS = append(NODECHECKS, c)
+}
+
+// Add an explain check.
+// Panics if the Id is empty or already registered.
+func RegisterExplainCheck(c ExplainCheck) {
+	mustBeNewCheckId(c.Id)
+	EXPLAINCHECKS = append(EXPLAINCHECKS, c)
+}
+
+func mustBeNewCheckId(id string) {
+	if id == "" {
+		panic("check registered without an Id")
+	}
+	if _, ok := LookupCheck(id); ok {
+		panic(fmt.Sprintf("check \"%s\" is already registered", id))
+	}
+}
+
+// Return all node and explain checks
+func Checks() []CheckInfo {
+	checks := []CheckInfo{}
+	for _, c := range NODECHECKS {
+		checks = append(checks, c.CheckInfo)
+	}
+	for _, c := range EXPLAINCHECKS {
+		checks = append(checks, c.CheckInfo)
+	}
+	return checks
+}
+
+// Find a check by Id
+func LookupCheck(id string) (CheckInfo, bool) {
+	for _, c := range Checks() {
+		if c.Id == id {
+			return c, true
+		}
+	}
+	return CheckInfo{}, false
+}
+
+// Split a comma separated list of check Ids, e.g. "spill-files,data-skew"
+func ParseCheckIds(s string) []string {
+	ids := []string{}
+	for _, id := range strings.Split(s, ",") {
+		id = strings.TrimSpace(id)
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Return an error if any enabled or disabled check Id does not exist
+func (c CheckConfig) Validate() error {
+	for _, id := range append(append([]string{}, c.Enabled...), c.Disabled...) {
+		if _, ok := LookupCheck(id); ok == false {
+			return errors.New(fmt.Sprintf("Unknown check \"%s\"", id))
+		}
+	}
+	return nil
+}
+
+// True if the check should run with this config
+func (c CheckConfig) IsEnabled(info CheckInfo) bool {
+	if len(c.Enabled) > 0 && containsString(c.Enabled, info.Id) == false {
+		return false
+	}
+	if containsString(c.Disabled, info.Id) {
+		return false
+	}
+	if c.Optimizer != "" && containsString(info.Scope, c.Optimizer) == false {
+		return false
+	}
+	if c.Dialect != "" && containsString(info.Dialects, c.Dialect) == false {
+		return false
+	}
+	return true
+}
+
+// Run the checks selected by the config and return the findings
+func (e *Explain) CheckWithConfig(c CheckConfig) *Findings {
+	nodeChecks := []NodeCheck{}
+	for _, check := range NODECHECKS {
+		if c.IsEnabled(check.CheckInfo) {
+			nodeChecks = append(nodeChecks, check)
+		}
+	}
+
+	explainChecks := []ExplainCheck{}
+	for _, check := range EXPLAINCHECKS {
+		if c.IsEnabled(check.CheckInfo) {
+			explainChecks = append(explainChecks, check)
+		}
+	}
+
+	return e.checkWithParams(nodeChecks, explainChecks, c.Params, c.Waivers)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package plan
+
+import (
+	"testing"
+)
+
+// Every check has a unique Id and is fully described
+func TestCheckRegistry(t *testing.T) {
+	ids := map[string]bool{}
+	for _, c := range Checks() {
+		if c.Id == "" || ids[c.Id] {
+			t.Errorf("check %s has an empty or duplicate Id %q", c.Name, c.Id)
+		}
+		ids[c.Id] = true
+
+		if c.Severity.String() == "unknown" || c.Category == "" || c.Documentation == "" || len(c.Scope) == 0 || len(c.Dialects) == 0 {
+			t.Errorf("check %s is not fully described: %+v", c.Id, c)
+		}
+		if found, ok := LookupCheck(c.Id); ok == false || found.Name != c.Name {
+			t.Errorf("LookupCheck(%q) = %+v, %t", c.Id, found, ok)
+		}
+	}
+
+	if _, ok := LookupCheck("no-such-check"); ok {
+		t.Errorf("LookupCheck found an unknown check")
+	}
+}
+
+// Registering an Id twice panics and leaves the checks unchanged
+func TestRegisterDuplicateCheck(t *testing.T) {
+	count := len(NODECHECKS)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a duplicate Id did not panic")
+		}
+		if len(NODECHECKS) != count {
+			t.Errorf("duplicate check was added")
+		}
+	}()
+	RegisterNodeCheck(NodeCheck{CheckInfo: CheckInfo{Id: "spill-files"}})
+}
+
+func TestParseCheckIds(t *testing.T) {
+	got := ParseCheckIds(" spill-files, ,data-skew,")
+	if len(got) != 2 || got[0] != "spill-files" || got[1] != "data-skew" {
+		t.Errorf("ParseCheckIds = %q", got)
+	}
+	if got := ParseCheckIds(""); len(got) != 0 {
+		t.Errorf("ParseCheckIds(\"\") = %q", got)
+	}
+
+	if s, ok := ParseSeverity(" Critical "); ok == false || s != SeverityCritical {
+		t.Errorf("ParseSeverity(\" Critical \") = %s, %t", s, ok)
+	}
+	if _, ok := ParseSeverity("fatal"); ok {
+		t.Errorf("ParseSeverity(\"fatal\") found a severity")
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	info := CheckInfo{Id: "spill-files", Scope: []string{"orca"}, Dialects: []string{DialectGreenplum}}
+
+	tests := []struct {
+		config CheckConfig
+		want   bool
+	}{
+		{CheckConfig{}, true},
+		{CheckConfig{Enabled: []string{"spill-files"}}, true},
+		{CheckConfig{Enabled: []string{"data-skew"}}, false},
+		{CheckConfig{Disabled: []string{"spill-files"}}, false},
+		{CheckConfig{Enabled: []string{"spill-files"}, Disabled: []string{"spill-files"}}, false},
+		{CheckConfig{Optimizer: "orca"}, true},
+		{CheckConfig{Optimizer: "legacy"}, false},
+		{CheckConfig{Dialect: DialectHawq}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.config.IsEnabled(info); got != test.want {
+			t.Errorf("%+v.IsEnabled() = %t, want %t", test.config, got, test.want)
+		}
+	}
+
+	if err := (CheckConfig{Disabled: []string{"spill-files", "no-such-check"}}).Validate(); err == nil || err.Error() != "Unknown check \"no-such-check\"" {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := (CheckConfig{Enabled: []string{"spill-files"}}).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+// Warnings are labelled with the check that produced them and only the
+// selected checks run
+func TestCheckWithConfigExplain05(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+
+	all := e.CheckWithConfig(CheckConfig{})
+	found := map[string]int{}
+	for _, warnings := range all.NodeWarnings {
+		for _, w := range warnings {
+			info, ok := LookupCheck(w.CheckId)
+			if ok == false || w.Severity != info.Severity || w.Node == nil {
+				t.Errorf("warning %q labelled with check %q and severity %s", w.Cause, w.CheckId, w.Severity)
+			}
+			found[w.CheckId]++
+		}
+	}
+	if found["spill-files"] != 1 || found["row-misestimate"] == 0 {
+		t.Errorf("warnings by check = %v", found)
+	}
+
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"spill-files"}})
+	if len(f.NodeWarnings) != 1 || len(f.NodeWarnings[e.Nodes[1]]) != 1 || len(f.Warnings) != 0 {
+		t.Errorf("only spill-files enabled, found warnings on %d nodes", len(f.NodeWarnings))
+	}
+
+	f = e.CheckWithConfig(CheckConfig{Disabled: []string{"row-misestimate"}})
+	if countWarnings(f) != countWarnings(all)-found["row-misestimate"] {
+		t.Errorf("row-misestimate disabled, found %d warnings of %d", countWarnings(f), countWarnings(all))
+	}
+}
@@ -0,0 +1,127 @@
+package plan
+
+import (
+	"fmt"
+	"time"
+)
+
+// Consistency diagnostics
+//
+// A plan that was edited by hand, truncated or copied from a wrapped
+// terminal can still parse but produce a tree that EXPLAIN would never
+// output. The checks only make sense on a real plan, so Validate() looks for
+// signs of a damaged plan and reports them as diagnostics, separately from
+// the performance warnings:
+//   - A node with a startup cost above its total cost
+//   - A child with a higher total cost than its parent. Motions, Limits and
+//     Sequences are skipped as their cost can be lower than their children.
+//   - Children costing more together than their parent, which makes the
+//     cost of the node itself negative before it is clamped to 0. Appends are
+//     skipped as the planner can cost them below the sum of the partitions.
+//   - A node reporting its first row after its last row, or running longer
+//     than the total runtime of the query. Start offsets are not compared
+//     with the runtime as the clocks of the segments can differ.
+//   - A Motion without a slice
+//   - SubPlans without a node or not attached to a node, and nodes that are
+//     not part of the tree
+//
+// Any diagnostic means the analysis of the plan may be wrong.
+
+// Kinds of diagnostics
+const (
+	DiagnosticStartupCost  = "startup-cost"
+	DiagnosticChildCost    = "child-cost"
+	DiagnosticNegativeCost = "negative-node-cost"
+	DiagnosticActualTime   = "actual-time"
+	DiagnosticMotionSlice  = "motion-without-slice"
+	DiagnosticOrphan       = "orphan"
+)
+
+// Costs are printed with 2 decimals
+const costTolerance = 0.01
+
+// Times are printed with at most 3 decimals of a ms
+const timeTolerance = time.Millisecond
+
+// A sign that the plan is not what EXPLAIN produced
+type Diagnostic struct {
+	Kind    string
+	Message string
+	Node    *Node `json:"-"` // Nil for the plan as a whole
+}
+
+// Check the tree is consistent with a plan produced by EXPLAIN.
+// Analyze() stores the result in Explain.Diagnostics.
+func (e *Explain) Validate() []Diagnostic {
+	diagnostics := []Diagnostic{}
+	add := func(kind string, n *Node, format string, v ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{Kind: kind, Message: fmt.Sprintf(format, v...), Node: n})
+	}
+
+	// Nodes and SubPlans must all be reachable from the top node
+	reachable := map[*Node]bool{}
+	attached := map[*Plan]bool{}
+	e.Walk(func(n *Node) error {
+		reachable[n] = true
+		for _, p := range n.SubPlans {
+			attached[p] = true
+		}
+		return nil
+	}, nil)
+	for i, p := range e.Plans {
+		if p.TopNode == nil {
+			add(DiagnosticOrphan, nil, "%s has no nodes", p.Name)
+		} else if i > 0 && attached[p] == false {
+			add(DiagnosticOrphan, nil, "%s is not attached to a node", p.Name)
+		}
+	}
+	for _, n := range e.Nodes {
+		if reachable[n] == false {
+			add(DiagnosticOrphan, n, "Node is not part of the plan tree")
+		}
+	}
+
+	for _, n := range e.Nodes {
+		if n.StartupCost > n.TotalCost+costTolerance {
+			add(DiagnosticStartupCost, n, "Startup cost %.2f is higher than total cost %.2f", n.StartupCost, n.TotalCost)
+		}
+
+		if n.Category == CategoryMotion && n.Slice.Valid == false {
+			add(DiagnosticMotionSlice, n, "Motion without a slice")
+		}
+
+		if n.MsFirst.Valid && n.MsEnd.Valid && n.MsFirst.Value > n.MsEnd.Value+timeTolerance {
+			add(DiagnosticActualTime, n, "First row after %s but last row after %s", n.MsFirst, n.MsEnd)
+		}
+		if e.Runtime.Valid && n.MsEnd.Valid && n.MsEnd.Value > e.Runtime.Value+timeTolerance {
+			add(DiagnosticActualTime, n, "Node ran for %s, longer than the total runtime %s", n.MsEnd, e.Runtime)
+		}
+
+		// The cost of these nodes is not the sum of their children
+		if n.Category == CategoryMotion || n.IsType(NodeTypeLimit, NodeTypeSequence) {
+			continue
+		}
+		children := n.Children()
+		for _, c := range children {
+			if c.TotalCost > n.TotalCost+costTolerance {
+				add(DiagnosticChildCost, n, "Child #%d %s costs %.2f, more than this node's %.2f", c.Id, c.Operator, c.TotalCost, n.TotalCost)
+			}
+		}
+		if len(children) > 1 && n.IsType(NodeTypeAppend) == false {
+			if cost := n.TotalCost - n.childCost(); cost < -costTolerance*float64(len(children)) {
+				add(DiagnosticNegativeCost, n, "Node cost is %.2f, the children cost more than the node", cost)
+			}
+		}
+	}
+
+	return diagnostics
+}
+
+// Sum of the total cost of the children
+func (n *Node) childCost() float64 {
+	cost := 0.0
+	for _, c := range n.Children() {
+		cost += c.TotalCost
+	}
+	return cost
+}
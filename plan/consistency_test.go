@@ -0,0 +1,80 @@
+package plan
+
+import (
+	"testing"
+)
+
+// Plans produced by EXPLAIN have no diagnostics
+func TestValidateTestdata(t *testing.T) {
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+		for _, d := range e.Diagnostics {
+			t.Errorf("%s: %s on %v: %s", filename, d.Kind, d.Node, d.Message)
+		}
+	}
+}
+
+// The Append of explain21 costs less than its partitions together, as
+// EXPLAIN does for Appends, but not less than any one of them
+func TestValidateAppendExplain21(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain21.txt")
+	a := e.Nodes[3]
+	if a.IsType(NodeTypeAppend) == false || a.TotalCost-a.childCost() >= 0 {
+		t.Fatalf("node #3 %s costs %.2f with children of %.2f", a.Operator, a.TotalCost, a.childCost())
+	}
+	if len(e.Diagnostics) != 0 {
+		t.Errorf("diagnostics = %+v", e.Diagnostics)
+	}
+
+	// A partition costing more than the Append is still reported
+	e = loadEditedExplain(t, "../testdata/explain21.txt",
+		"(cost=0.00..4920544.60 rows=15 width=0)", "(cost=0.00..97562999.00 rows=15 width=0)")
+	if len(e.Diagnostics) != 1 || e.Diagnostics[0].Kind != DiagnosticChildCost || e.Diagnostics[0].Node != e.Nodes[3] {
+		t.Errorf("diagnostics of the edited plan = %+v", e.Diagnostics)
+	}
+}
+
+// Kind of a diagnostic and the node it is reported on
+type diagnosticAt struct {
+	kind string
+	id   int
+}
+
+// Each edit of explain05 damages the plan in a way Validate reports
+func TestValidateDamagedExplain05(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want []diagnosticAt
+	}{
+		{"startup above total cost",
+			"Hash Join  (cost=0.00..862.00", "Hash Join  (cost=900.00..862.00",
+			[]diagnosticAt{{DiagnosticStartupCost, 1}}},
+		{"child above parent cost",
+			"->  Hash  (cost=431.00..431.00", "->  Hash  (cost=0.00..900.00",
+			[]diagnosticAt{{DiagnosticChildCost, 1}, {DiagnosticNegativeCost, 1}}},
+		{"first row after last row",
+			"6897 ms to first row, 7429 ms to end", "7500 ms to first row, 7429 ms to end",
+			[]diagnosticAt{{DiagnosticActualTime, 1}}},
+		{"node longer than runtime",
+			"Total runtime: 7442.441 ms", "Total runtime: 7435.000 ms",
+			[]diagnosticAt{{DiagnosticActualTime, 0}}},
+		{"motion without slice",
+			"Redistribute Motion 2:2  (slice1; segments: 2)  (cost", "Redistribute Motion 2:2  (cost",
+			[]diagnosticAt{{DiagnosticMotionSlice, 6}}},
+	}
+
+	for _, test := range tests {
+		e := loadEditedExplain(t, "../testdata/explain05.txt", test.old, test.new)
+		if len(e.Diagnostics) != len(test.want) {
+			t.Errorf("%s: %d diagnostics %+v, want %d", test.name, len(e.Diagnostics), e.Diagnostics, len(test.want))
+			continue
+		}
+		for i, d := range e.Diagnostics {
+			if d.Kind != test.want[i].kind || d.Node == nil || d.Node.Id != test.want[i].id || d.Message == "" {
+				t.Errorf("%s: diagnostic %d = %s on %v, want %s on node #%d", test.name, i, d.Kind, d.Node, test.want[i].kind, test.want[i].id)
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Coordinator bottlenecks
+//
+// Nodes above the top Gather Motion run in slice 0 on the coordinator, a
+// single process, while the rest of the plan runs in parallel on every
+// segment:
+//
+//	Result                        slice 0, coordinator
+//	  ->  Window                  slice 0, coordinator
+//	        ->  Gather Motion 4:1 (slice1; segments: 4)
+//	              ->  Seq Scan    slice 1, segments
+//
+// Window functions without PARTITION BY, final sorts of large results and
+// aggregates over the gathered rows can make the coordinator the bottleneck.
+// A plan without any Motion runs entirely on the coordinator (entry db), e.g.
+// queries on catalog tables or functions. DML without a Motion such as
+// "Insert (slice0; segments: 4)" shows slice 0 but runs on the segments, so
+// slice 0 is only the coordinator when it does not show more than 1 segment.
+//
+// The time spent on the coordinator is the self time (MsNode) of the nodes
+// in slice 0. Its share of the runtime is reported in the summary and by the
+// coordinator-time check when it is above coordinator_time_percent.
+
+// True if the node runs on the coordinator.
+// Gather Motions are labelled with the slice sending the rows so they are not
+// included.
+func (n *Node) OnCoordinator() bool {
+	return n.Category != CategoryMotion && n.SliceId() == 0 && n.SegmentCount().Value <= 1
+}
+
+// True if the plan has no Motions and no slice running on several segments,
+// so it runs entirely on the coordinator
+func (e *Explain) IsEntryDb() bool {
+	for _, n := range e.Nodes {
+		if n.Category == CategoryMotion {
+			return false
+		}
+		if n.Segments.Valid && n.Segments.Value > 1 {
+			return false
+		}
+	}
+	return len(e.Nodes) > 0
+}
+
+// Return the self time of the coordinator nodes and its percentage of the
+// runtime, only valid for EXPLAIN ANALYZE output
+func (e *Explain) CoordinatorTime() (OptionalDuration, float64) {
+	if len(e.Plans) == 0 || e.Plans[0].TopNode == nil || e.Plans[0].TopNode.MsEnd.Value <= 0 {
+		return OptionalDuration{}, 0
+	}
+	total := time.Duration(0)
+	found := false
+	for _, n := range e.Nodes {
+		if n.OnCoordinator() && n.MsNode.Valid {
+			total += n.MsNode.Value
+			found = true
+		}
+	}
+	if found == false {
+		return OptionalDuration{}, 0
+	}
+	return validDuration(total), float64(total) / float64(e.Plans[0].TopNode.MsEnd.Value) * 100
+}
+
+// Rows a coordinator node processes, the largest of its own rows and the
+// rows of its children
+func coordinatorRows(n *Node) float64 {
+	rows := n.rowsPerSegment()
+	for _, c := range n.SubNodes {
+		if r := c.rowsPerSegment(); r > rows {
+			rows = r
+		}
+	}
+	return rows
+}
+
+// Suggest how to move the work of a coordinator node to the segments
+func coordinatorResolution(n *Node) string {
+	switch {
+	case n.IsType(NodeTypeWindow) && n.Condition("Partition By") == "":
+		return "Add PARTITION BY to the window function so it runs on the segments"
+	case n.IsType(NodeTypeSort):
+		return "Sort on the segments and merge in the Gather Motion, or limit the rows sorted"
+	case n.Category == CategoryAggregate:
+		return "Aggregate on the segments before gathering, e.g. group by the distribution key first"
+	}
+	return "Move the work below the Gather Motion so it runs on the segments"
+}
+
+var coordinatorChecks = []NodeCheck{
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "coordinator-rows",
+			Name:          "checkNodeCoordinatorRows",
+			Description:   "Coordinator node processing many rows",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryExecution,
+			Severity:      SeverityWarning,
+			Documentation: "A sort, aggregate, window or join runs on the coordinator over coordinator_rows rows or more. The coordinator is a single process, so this work does not scale with the number of segments.",
+			Parameters:    []string{"coordinator_rows"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.OnCoordinator() == false {
+				return
+			}
+			if n.Category != CategorySort && n.Category != CategoryAggregate && n.Category != CategoryJoin {
+				return
+			}
+			rows := coordinatorRows(n)
+			if rows < f.Params.Float("coordinator_rows") {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("%s runs on the coordinator over %.0f rows", n.Operator, rows),
+				Resolution: coordinatorResolution(n)})
+		}},
+}
+
+var coordinatorExplainChecks = []ExplainCheck{
+	ExplainCheck{
+		CheckInfo: CheckInfo{
+			Id:            "coordinator-time",
+			Name:          "checkExplainCoordinatorTime",
+			Description:   "Large share of the runtime spent on the coordinator",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryExecution,
+			Severity:      SeverityWarning,
+			Documentation: "The nodes in slice 0 spent coordinator_time_percent of the runtime or more. While the coordinator works the segments wait, so this part of the query does not get faster with more segments.",
+			Parameters:    []string{"coordinator_time_percent"},
+		},
+		Exec: func(e *Explain, f *Findings) {
+			if e.IsEntryDb() {
+				return
+			}
+			total, percent := e.CoordinatorTime()
+			if total.Valid == false || percent < f.Params.Float("coordinator_time_percent") {
+				return
+			}
+			nodes := []string{}
+			for _, n := range topNodes(e.Nodes, 3, func(n *Node) (float64, bool) {
+				return float64(n.MsNode.Value), n.OnCoordinator() && n.MsNode.Value > 0
+			}) {
+				nodes = append(nodes, fmt.Sprintf("#%d %s %s", n.Id, n.Operator, n.MsNode))
+			}
+			f.AddWarning(Warning{
+				Cause:      fmt.Sprintf("%s (%.0f%%) of the runtime is spent on the coordinator, mostly in %s", total, percent, strings.Join(nodes, ", ")),
+				Resolution: "Move the work below the Gather Motion so it runs on the segments"})
+		}},
+	ExplainCheck{
+		CheckInfo: CheckInfo{
+			Id:            "entry-db",
+			Name:          "checkExplainEntryDb",
+			Description:   "Query running entirely on the coordinator",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryExecution,
+			Severity:      SeverityInfo,
+			Documentation: "The plan has no Motions so it runs in the coordinator (entry db) without using the segments. This is expected for catalog tables and some functions, but data processed this way is not parallel.",
+		},
+		Exec: func(e *Explain, f *Findings) {
+			if e.IsEntryDb() == false {
+				return
+			}
+			scans := 0
+			for _, n := range e.Nodes {
+				if n.Category == CategoryScan {
+					scans++
+				}
+			}
+			if scans == 0 {
+				return
+			}
+			runtime := ""
+			if total, _ := e.CoordinatorTime(); total.Valid {
+				runtime = fmt.Sprintf(" for %s", total)
+			}
+			f.AddWarning(Warning{
+				Cause:      fmt.Sprintf("Plan has no motions, all %s and %s run on the coordinator%s", plural(len(e.Nodes), "node"), plural(scans, "scan"), runtime),
+				Resolution: "Check the tables read are distributed tables if the query should run on the segments"})
+		}},
+}
+
+func init() {
+	for _, c := range coordinatorChecks {
+		RegisterNodeCheck(c)
+	}
+	for _, c := range coordinatorExplainChecks {
+		RegisterExplainCheck(c)
+	}
+}
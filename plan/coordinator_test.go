@@ -0,0 +1,142 @@
+package plan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOnCoordinator(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     []int
+	}{
+		// The Gather Motion is labelled with the sending slice
+		{"../testdata/explain17.txt", []int{0}},
+		{"../testdata/explain05.txt", []int{}},
+		// All nodes of a plan without motions
+		{"../testdata/explain06.txt", []int{0, 1, 2, 3, 4}},
+	}
+
+	for _, test := range tests {
+		got := []int{}
+		for _, n := range loadTestExplain(t, test.filename).Nodes {
+			if n.OnCoordinator() {
+				got = append(got, n.Id)
+			}
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: coordinator nodes %v, want %v", test.filename, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: coordinator nodes %v, want %v", test.filename, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestIsEntryDb(t *testing.T) {
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+		want := filename == "../testdata/explain06.txt" || filename == "../testdata/explain08.txt"
+		if e.IsEntryDb() != want {
+			t.Errorf("%s: IsEntryDb() = %v, want %v", filename, e.IsEntryDb(), want)
+		}
+	}
+	if (&Explain{}).IsEntryDb() {
+		t.Errorf("empty plan is entry db")
+	}
+}
+
+// explain19 with the coordinator Aggregate taking 753 ms longer than the
+// Gather Motion below it
+func TestCoordinatorTime(t *testing.T) {
+	e := loadEditedExplain(t, "../testdata/explain19.txt",
+		"Rows out:  1 rows with 147 ms to end", "Rows out:  1 rows with 900 ms to end")
+	total, percent := e.CoordinatorTime()
+	if total != validDuration(753*time.Millisecond) || int(percent) != 83 {
+		t.Errorf("coordinator time = %s (%.1f%%), want 753 ms (83.7%%)", total, percent)
+	}
+	if s := e.Summarize(5, nil); s.CoordinatorTime != total || s.CoordinatorPercent != percent {
+		t.Errorf("summary coordinator time = %s (%.1f%%)", s.CoordinatorTime, s.CoordinatorPercent)
+	}
+
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"coordinator-time"}})
+	want := "753 ms (84%) of the runtime is spent on the coordinator, mostly in #0 Aggregate 753 ms"
+	if len(f.Warnings) != 1 || f.Warnings[0].Cause != want {
+		t.Errorf("coordinator-time warnings = %+v, want %q", f.Warnings, want)
+	}
+
+	// The Aggregate of the unedited plan takes no time
+	e = loadTestExplain(t, "../testdata/explain19.txt")
+	if f := e.CheckWithConfig(CheckConfig{Enabled: []string{"coordinator-time"}}); len(f.Warnings) != 0 {
+		t.Errorf("coordinator-time warnings = %+v", f.Warnings)
+	}
+	// Without EXPLAIN ANALYZE there is no time
+	if total, _ := loadTestExplain(t, "../testdata/explain17.txt").CoordinatorTime(); total.Valid {
+		t.Errorf("coordinator time of EXPLAIN = %s", total)
+	}
+}
+
+// Warnings of the coordinator checks on testdata with the default thresholds
+func TestCoordinatorChecksTestdata(t *testing.T) {
+	got := testdataWarnings(t, "coordinator-rows", "coordinator-time", "entry-db")
+	compareTestdataWarnings(t, got, map[string][]string{
+		// Catalog queries without motions
+		"explain06.txt": {"entry-db"},
+		"explain08.txt": {"entry-db"},
+		// Nested Loop above the Gather Motions
+		"explain09.txt": {"#0 coordinator-rows"},
+	})
+}
+
+func TestCoordinatorCheckCauses(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain06.txt")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"entry-db"}})
+	want := "Plan has no motions, all 5 nodes and 3 scans run on the coordinator"
+	if len(f.Warnings) != 1 || f.Warnings[0].Cause != want {
+		t.Errorf("entry-db warnings = %+v, want %q", f.Warnings, want)
+	}
+
+	e = loadTestExplain(t, "../testdata/explain09.txt")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"coordinator-rows"}})
+	want = "Nested Loop runs on the coordinator over 1671897473232 rows"
+	if w := f.NodeWarnings[e.Nodes[0]]; len(w) != 1 || w[0].Cause != want {
+		t.Errorf("coordinator-rows warnings = %+v, want %q", w, want)
+	}
+
+	// Aggregates are moved to the segments
+	p := NewParams()
+	p.Set("coordinator_rows", 100)
+	e = loadTestExplain(t, "../testdata/explain14.txt")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"coordinator-rows"}, Params: p})
+	w := f.NodeWarnings[e.Nodes[0]]
+	if len(w) != 1 || w[0].Cause != "Aggregate runs on the coordinator over 320 rows" || w[0].Resolution != "Aggregate on the segments before gathering, e.g. group by the distribution key first" {
+		t.Errorf("coordinator-rows warnings = %+v", w)
+	}
+}
+
+// DML without a Motion shows slice 0 but runs on the segments
+func TestCoordinatorDml(t *testing.T) {
+	e := loadTestExplain(t, writeTestFile(t, "insert.txt", `                                  QUERY PLAN
+------------------------------------------------------------------------------
+ Insert (slice0; segments: 4)  (rows=250 width=8)
+   ->  Seq Scan on t  (cost=0.00..10.00 rows=250 width=8)
+ Optimizer status: legacy query optimizer
+(3 rows)
+`))
+	insert := e.Nodes[0]
+	if insert.Operator != "Insert" || insert.SliceId() != 0 || insert.Segments != validInt(4) {
+		t.Fatalf("Insert parsed as %q slice %d on %s segments", insert.Operator, insert.SliceId(), insert.Segments)
+	}
+	for _, n := range e.Nodes {
+		if n.OnCoordinator() {
+			t.Errorf("%s on the coordinator", n.Operator)
+		}
+	}
+	if e.IsEntryDb() {
+		t.Errorf("Insert on 4 segments is entry db")
+	}
+}
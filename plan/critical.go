@@ -0,0 +1,139 @@
+package plan
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Critical path
+//
+// The chain of nodes that determined the total runtime of an EXPLAIN ANALYZE
+// plan. Starting at the top node each step moves to the child that finished
+// last (latest EndTime, i.e. MsOffset + MsEnd) as the parent could not finish
+// before it. If two children finish together the one which produced its
+// first row later (StartTime + MsFirst) is chosen. The path crosses Motions
+// so it can span several slices.
+//
+// Each step is charged the time between its critical child finishing and
+// the node itself finishing. The last node on the path is charged its whole
+// runtime. The durations add up to the runtime of the top node minus the
+// time before the last node started (CriticalPathStart).
+
+// A node on the critical path
+type CriticalPathStep struct {
+	Node     *Node `json:"-"`
+	NodeId   int
+	Slice    int64
+	Duration time.Duration // Time on the critical path spent in this node
+}
+
+// Time on the critical path spent in a slice
+type SliceTime struct {
+	Slice    int64
+	Duration time.Duration
+}
+
+// Duration in milliseconds in JSON, like OptionalDuration
+func (s CriticalPathStep) MarshalJSON() ([]byte, error) {
+	type step CriticalPathStep
+	return json.Marshal(struct {
+		step
+		Duration float64
+	}{step(s), durationToMs(s.Duration)})
+}
+
+// Duration in milliseconds in JSON, like OptionalDuration
+func (s SliceTime) MarshalJSON() ([]byte, error) {
+	type sliceTime SliceTime
+	return json.Marshal(struct {
+		sliceTime
+		Duration float64
+	}{sliceTime(s), durationToMs(s.Duration)})
+}
+
+// Populate Explain.CriticalPath and Node.OnCriticalPath
+func (e *Explain) calculateCriticalPath() {
+	e.CriticalPath = nil
+	e.CriticalPathStart = OptionalDuration{}
+	for _, n := range e.Nodes {
+		n.OnCriticalPath = false
+	}
+
+	if len(e.Plans) == 0 || e.Plans[0].TopNode == nil {
+		return
+	}
+
+	n := e.Plans[0].TopNode
+	if n.EndTime.Valid == false {
+		return
+	}
+
+	for n != nil {
+		next := criticalChild(n)
+
+		step := CriticalPathStep{
+			Node:   n,
+			NodeId: n.Id,
+			Slice:  n.SliceId(),
+		}
+		if next != nil {
+			step.Duration = n.EndTime.Value - next.EndTime.Value
+		} else {
+			step.Duration = n.EndTime.Value - n.StartTime.Value
+			e.CriticalPathStart = n.StartTime
+		}
+		if step.Duration < 0 {
+			step.Duration = 0
+		}
+
+		n.OnCriticalPath = true
+		e.CriticalPath = append(e.CriticalPath, step)
+		n = next
+	}
+}
+
+// Return the child that finished last or nil if no child has timings
+func criticalChild(n *Node) *Node {
+	var found *Node
+	for _, c := range n.Children() {
+		if c.EndTime.Valid == false {
+			continue
+		}
+		if found == nil || c.EndTime.Value > found.EndTime.Value {
+			found = c
+		} else if c.EndTime.Value == found.EndTime.Value && firstRowTime(c) > firstRowTime(found) {
+			found = c
+		}
+	}
+	return found
+}
+
+// When the node produced its first row relative to the start of the query
+func firstRowTime(n *Node) time.Duration {
+	return n.StartTime.Value + n.MsFirst.Value
+}
+
+// Return the time on the critical path spent in each slice, largest first.
+// The slice at the top is the one to optimise first.
+func (e *Explain) CriticalPathBySlice() []SliceTime {
+	bySlice := map[int64]time.Duration{}
+	order := []int64{}
+	for _, s := range e.CriticalPath {
+		if _, ok := bySlice[s.Slice]; !ok {
+			order = append(order, s.Slice)
+		}
+		bySlice[s.Slice] += s.Duration
+	}
+
+	sliceTimes := []SliceTime{}
+	for _, slice := range order {
+		sliceTimes = append(sliceTimes, SliceTime{slice, bySlice[slice]})
+	}
+
+	sort.SliceStable(sliceTimes, func(i, j int) bool {
+		return sliceTimes[i].Duration > sliceTimes[j].Duration
+	})
+
+	return sliceTimes
+}
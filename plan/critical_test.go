@@ -0,0 +1,94 @@
+package plan
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// explain05 finishes with the Hash Join, which waited for the Hash built
+// from the Redistribute Motion in slice 1
+func TestCriticalPathExplain05(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+
+	want := []CriticalPathStep{
+		{NodeId: 0, Slice: 2, Duration: 0},
+		{NodeId: 1, Slice: 2, Duration: ms(7469 - 7042)},
+		{NodeId: 5, Slice: 2, Duration: ms(7042 - 4593)},
+		{NodeId: 6, Slice: 1, Duration: ms(4593 - 1474)},
+		{NodeId: 7, Slice: 1, Duration: ms(1474 - 742)},
+		{NodeId: 9, Slice: 1, Duration: ms(742 - 42)},
+	}
+	if len(e.CriticalPath) != len(want) {
+		t.Fatalf("critical path has %d steps, want %d", len(e.CriticalPath), len(want))
+	}
+	for i, step := range e.CriticalPath {
+		if step.NodeId != want[i].NodeId || step.Slice != want[i].Slice || step.Duration != want[i].Duration || step.Node != e.Nodes[step.NodeId] {
+			t.Errorf("step %d = #%d in slice %d for %s, want #%d in slice %d for %s", i,
+				step.NodeId, step.Slice, FormatDuration(step.Duration),
+				want[i].NodeId, want[i].Slice, FormatDuration(want[i].Duration))
+		}
+	}
+	if e.CriticalPathStart != validDuration(ms(42)) {
+		t.Errorf("critical path starts at %s, want 42 ms", e.CriticalPathStart)
+	}
+
+	slices := e.CriticalPathBySlice()
+	if len(slices) != 2 || slices[0] != (SliceTime{1, ms(3119 + 732 + 700)}) || slices[1] != (SliceTime{2, ms(427 + 2449)}) {
+		t.Errorf("time per slice = %v", slices)
+	}
+
+	// Durations are milliseconds in JSON
+	data, err := json.Marshal([]interface{}{e.CriticalPath[1], slices[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"NodeId":1,"Slice":2,"Duration":427},{"Slice":2,"Duration":2876}]`; string(data) != want {
+		t.Errorf("JSON = %s, want %s", data, want)
+	}
+}
+
+// The path runs from the top node down through children and only the nodes
+// on it are marked
+func TestCriticalPathTestdata(t *testing.T) {
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+
+		if e.Nodes[0].EndTime.Valid == false {
+			if len(e.CriticalPath) != 0 || e.CriticalPathStart.Valid {
+				t.Errorf("%s: critical path without EXPLAIN ANALYZE", filename)
+			}
+			continue
+		}
+
+		onPath := map[*Node]bool{}
+		total := time.Duration(0)
+		for i, step := range e.CriticalPath {
+			onPath[step.Node] = true
+			total += step.Duration
+			if i == 0 && step.Node != e.Plans[0].TopNode {
+				t.Errorf("%s: critical path starts at node #%d", filename, step.NodeId)
+			}
+			if i > 0 && step.Node.Parent != e.CriticalPath[i-1].Node {
+				t.Errorf("%s: step %d node #%d is not a child of #%d", filename, i, step.NodeId, e.CriticalPath[i-1].NodeId)
+			}
+			if step.Duration < 0 {
+				t.Errorf("%s: step %d has negative duration %s", filename, i, FormatDuration(step.Duration))
+			}
+		}
+		for _, n := range e.Nodes {
+			if n.OnCriticalPath != onPath[n] {
+				t.Errorf("%s: node #%d OnCriticalPath = %t", filename, n.Id, n.OnCriticalPath)
+			}
+		}
+
+		// The time of the slices adds up to the path
+		sliceTotal := time.Duration(0)
+		for _, s := range e.CriticalPathBySlice() {
+			sliceTotal += s.Duration
+		}
+		if sliceTotal != total {
+			t.Errorf("%s: slices add up to %s, path to %s", filename, FormatDuration(sliceTotal), FormatDuration(total))
+		}
+	}
+}
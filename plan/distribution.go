@@ -0,0 +1,261 @@
+package plan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Distribution key advice
+//
+// A Redistribute or Broadcast Motion below a join moves a table because it
+// is not distributed on the columns it is joined on:
+//
+//	->  Hash Join
+//	      Hash Cond: a.col1 = b.col2
+//	      ->  Seq Scan on atable a
+//	      ->  Hash
+//	            ->  Redistribute Motion 2:2  (slice1; segments: 2)
+//	                  Hash Key: b.col2
+//	                  ->  Seq Scan on bigtable b
+//
+// For every such motion reading a single table the columns are taken from
+// the Hash Key of a Redistribute Motion, or from the join keys resolving to
+// the table for a Broadcast Motion. Keys that are cast are skipped as the
+// distribution can not match them.
+//
+// The advice is consolidated per table: when a table is moved on different
+// columns, the columns saving the most motions (then the most data) are
+// recommended and the other motions are counted as conflicting. The motion
+// is only saved when the other side of the join is distributed on the
+// matching columns as well. Tables moving less than distribution_min_mb are
+// not worth redistributing and get no advice.
+
+// Recommended distribution key for a table
+type DistributionAdvice struct {
+	Table      string
+	Columns    []string
+	Motions    []*Node  `json:"-"` // Motions saved by the new key
+	Bytes      ByteSize // Estimated bytes the saved motions move, actual if larger
+	Conflicts  int      // Motions of the table needing other columns
+	Statements []string // ALTER TABLE statement
+}
+
+// A motion of a table on a set of columns
+type tableMotion struct {
+	table   string
+	columns []string
+	motion  *Node
+}
+
+// Return the join a motion feeds, looking through nodes between them
+func joinAbove(n *Node) *Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Category == CategoryJoin {
+			return p
+		}
+		if p.IsType(NodeTypeHash, NodeTypeMaterialize, NodeTypeSort, NodeTypeResult) == false {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Columns of the Hash Key of a Redistribute Motion reading a single table.
+// Nil if any key is not a plain column of the table.
+func hashKeyColumns(n *Node, scan *Node) []string {
+	keys := n.Condition("Hash Key")
+	if keys == "" {
+		return nil
+	}
+	columns := []string{}
+	for _, key := range strings.Split(keys, ", ") {
+		ref, cast, ok := parseColumnRef(key)
+		if ok == false {
+			// Unqualified column, e.g. "Hash Key: year"
+			column := strings.TrimSpace(key)
+			if columnRefPattern.MatchString("t."+column) == false {
+				return nil
+			}
+			ref = ColumnRef{Column: column}
+		} else if n.ScanOf(ref) != scan {
+			return nil
+		}
+		if cast != "" {
+			return nil
+		}
+		if containsString(columns, ref.Column) == false {
+			columns = append(columns, ref.Column)
+		}
+	}
+	return columns
+}
+
+// Columns of the join keys resolving to the scanned table, nil if a key is cast
+func joinKeyColumns(join *Node, motion *Node, scan *Node) []string {
+	columns := []string{}
+	for _, key := range join.JoinKeys() {
+		for i, ref := range []ColumnRef{key.Left, key.Right} {
+			if motion.ScanOf(ref) != scan {
+				continue
+			}
+			if (i == 0 && key.LeftCast != "") || (i == 1 && key.RightCast != "") {
+				return nil
+			}
+			if containsString(columns, ref.Column) == false {
+				columns = append(columns, ref.Column)
+			}
+		}
+	}
+	return columns
+}
+
+// Find the motions below joins that move a single table
+func (e *Explain) tableMotions() []tableMotion {
+	motions := []tableMotion{}
+	for _, n := range e.Nodes {
+		if n.IsType(NodeTypeRedistributeMotion, NodeTypeBroadcastMotion) == false {
+			continue
+		}
+		join := joinAbove(n)
+		if join == nil {
+			continue
+		}
+
+		scans := []*Node{}
+		for _, d := range n.Descendants() {
+			if d.Category == CategoryScan && d.TableName() != "" {
+				scans = append(scans, d)
+			}
+		}
+		if len(scans) != 1 {
+			continue
+		}
+
+		columns := []string{}
+		if n.IsType(NodeTypeRedistributeMotion) {
+			columns = hashKeyColumns(n, scans[0])
+		}
+		if len(columns) == 0 {
+			columns = joinKeyColumns(join, n, scans[0])
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		motions = append(motions, tableMotion{scans[0].TableName(), columns, n})
+	}
+	return motions
+}
+
+// Recommend a distribution key for each table moved below a join, largest
+// saving first. The distribution_min_mb of params is used, or the default
+// when params is nil.
+func (e *Explain) DistributionAdvice(params *Params) []DistributionAdvice {
+	if params == nil {
+		params = NewParams()
+	}
+	minBytes := ByteSize(params.Float("distribution_min_mb") * float64(Megabyte))
+
+	tables := []string{}
+	byTable := map[string][]tableMotion{}
+	for _, m := range e.tableMotions() {
+		if _, ok := byTable[m.table]; ok == false {
+			tables = append(tables, m.table)
+		}
+		byTable[m.table] = append(byTable[m.table], m)
+	}
+
+	advice := []DistributionAdvice{}
+	for _, table := range tables {
+		// Group the motions of the table by columns
+		keys := []string{}
+		byKey := map[string]*DistributionAdvice{}
+		for _, m := range byTable[table] {
+			key := strings.Join(m.columns, ", ")
+			a, ok := byKey[key]
+			if ok == false {
+				a = &DistributionAdvice{Table: table, Columns: m.columns}
+				byKey[key] = a
+				keys = append(keys, key)
+			}
+			a.Motions = append(a.Motions, m.motion)
+			a.Bytes += motionMaxBytes(m.motion)
+		}
+
+		best := byKey[keys[0]]
+		for _, key := range keys[1:] {
+			a := byKey[key]
+			if len(a.Motions) > len(best.Motions) || (len(a.Motions) == len(best.Motions) && a.Bytes > best.Bytes) {
+				best = a
+			}
+		}
+		if best.Bytes < minBytes {
+			continue
+		}
+		best.Conflicts = len(byTable[table]) - len(best.Motions)
+		columns := []string{}
+		for _, c := range best.Columns {
+			columns = append(columns, quoteIdent(c))
+		}
+		best.Statements = []string{fmt.Sprintf("ALTER TABLE %s SET DISTRIBUTED BY (%s);", quoteIdent(table), strings.Join(columns, ", "))}
+		advice = append(advice, *best)
+	}
+
+	sort.SliceStable(advice, func(i, j int) bool {
+		return advice[i].Bytes > advice[j].Bytes
+	})
+	return advice
+}
+
+// Statements distributing moved tables by the columns they are joined on,
+// for the tables moving distribution_min_mb or more
+func distributionRemediation(e *Explain, p *Params) []string {
+	statements := []string{}
+	for _, a := range e.DistributionAdvice(p) {
+		statements = append(statements, a.Statements...)
+	}
+	return statements
+}
+
+// Format a count with a noun, e.g. "1 motion" or "2 motions"
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+var distributionChecks = []ExplainCheck{
+	ExplainCheck{
+		CheckInfo: CheckInfo{
+			Id:            "distribution-key",
+			Name:          "checkExplainDistributionKey",
+			Description:   "Table moved for joins on columns other than its distribution key",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryInterconnect,
+			Severity:      SeverityInfo,
+			Documentation: "The table is redistributed or broadcast to be joined, so it is not distributed on the join columns. Distributing it on those columns, with the other table distributed on the matching columns, lets the join run without moving the data. One suggestion is made per table, for the columns saving the most motions.",
+			Parameters:    []string{"distribution_min_mb"},
+		},
+		Exec: func(e *Explain, f *Findings) {
+			for _, a := range e.DistributionAdvice(f.Params) {
+				conflicts := ""
+				if a.Conflicts > 0 {
+					conflicts = fmt.Sprintf(", %s of the table on other columns", plural(a.Conflicts, "other motion"))
+				}
+				f.AddWarning(Warning{
+					Cause: fmt.Sprintf("\"%s\" is moved on (%s) by %s, an estimated %s%s",
+						a.Table, strings.Join(a.Columns, ", "), plural(len(a.Motions), "motion"), a.Bytes, conflicts),
+					Resolution:  fmt.Sprintf("Consider distributing \"%s\" by (%s) to save %s", a.Table, strings.Join(a.Columns, ", "), plural(len(a.Motions), "motion")),
+					Remediation: a.Statements})
+			}
+		}},
+}
+
+func init() {
+	for _, c := range distributionChecks {
+		RegisterExplainCheck(c)
+	}
+}
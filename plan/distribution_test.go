@@ -0,0 +1,154 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Params suggesting a distribution key however little a table moves
+func allDistributionParams() *Params {
+	p := NewParams()
+	p.Set("distribution_min_mb", 0)
+	return p
+}
+
+func TestDistributionAdvice(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     []string // Table, columns and motion ids of each advice
+	}{
+		// Redistributed on the Hash Key public.sales.year
+		{"../testdata/explain05.txt", []string{"sales (year) #6"}},
+		// Both sides of the join are redistributed on date_dt
+		{"../testdata/explain15.txt", []string{"adwd_date (date_dt) #25 #28", "adwm_dfa_artemis_network (dfa_network_id) #18"}},
+		// Broadcast Motions take the columns from the join keys
+		{"../testdata/explain17.txt", []string{"d_date (date_id) #11", "d_customer_account (account_sk) #18"}},
+		{"../testdata/explain18.txt", []string{"bigtable (col2) #2"}},
+		// All join keys are cast to text
+		{"../testdata/explain12.txt", []string{}},
+	}
+
+	for _, test := range tests {
+		got := []string{}
+		for _, a := range loadTestExplain(t, test.filename).DistributionAdvice(allDistributionParams()) {
+			advice := a.Table + " (" + strings.Join(a.Columns, ", ") + ")"
+			for _, m := range a.Motions {
+				advice += fmt.Sprintf(" #%d", m.Id)
+			}
+			if a.Conflicts != 0 {
+				t.Errorf("%s: %s has %d conflicts", test.filename, advice, a.Conflicts)
+			}
+			got = append(got, advice)
+		}
+		if strings.Join(got, "; ") != strings.Join(test.want, "; ") {
+			t.Errorf("%s: advice = %q, want %q", test.filename, got, test.want)
+		}
+	}
+}
+
+// The largest saving comes first
+func TestDistributionAdviceBytes(t *testing.T) {
+	advice := loadTestExplain(t, "../testdata/explain17.txt").DistributionAdvice(allDistributionParams())
+	if len(advice) != 2 || advice[0].Bytes != 3712 || advice[1].Bytes != 256 {
+		t.Fatalf("advice = %+v", advice)
+	}
+	want := "ALTER TABLE d_date SET DISTRIBUTED BY (date_id);"
+	if len(advice[0].Statements) != 1 || advice[0].Statements[0] != want {
+		t.Errorf("statements = %q, want %q", advice[0].Statements, want)
+	}
+}
+
+// explain15 with adwd_date redistributed on another column for one join
+func TestDistributionAdviceConflicts(t *testing.T) {
+	e := loadEditedExplain(t, "../testdata/explain15.txt",
+		"Hash Key: adwd_click.date_dt", "Hash Key: adwd_click.date_sk")
+
+	advice := e.DistributionAdvice(allDistributionParams())
+	if len(advice) != 2 {
+		t.Fatalf("advice = %+v", advice)
+	}
+	a := advice[0]
+	if a.Table != "adwd_date" || strings.Join(a.Columns, ", ") != "date_dt" || len(a.Motions) != 1 || a.Motions[0].Id != 25 || a.Conflicts != 1 {
+		t.Errorf("advice = %+v", a)
+	}
+}
+
+// Only tables moving distribution_min_mb or more are advised, 100 MB by default
+func TestDistributionAdviceMinBytes(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+	if advice := e.DistributionAdvice(nil); len(advice) != 0 {
+		t.Errorf("advice for 83.9 MB = %+v", advice)
+	}
+	p := NewParams()
+	p.Set("distribution_min_mb", 80)
+	if advice := e.DistributionAdvice(p); len(advice) != 1 || advice[0].Table != "sales" {
+		t.Errorf("advice with distribution_min_mb 80 = %+v", advice)
+	}
+	if advice := loadTestExplain(t, "../testdata/explain16.txt").DistributionAdvice(nil); len(advice) != 1 || advice[0].Table != "pa_expenditure_items_all" {
+		t.Errorf("explain16 advice = %+v", advice)
+	}
+}
+
+func TestDistributionKeyCheckTestdata(t *testing.T) {
+	// Only explain16 moves more than 100 MB
+	compareTestdataWarnings(t, testdataWarnings(t, "distribution-key"), map[string][]string{
+		"explain16.txt": {"distribution-key"},
+	})
+
+	e := loadTestExplain(t, "../testdata/explain16.txt")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"distribution-key"}})
+	want := "\"pa_expenditure_items_all\" is moved on (project_id) by 1 motion, an estimated 512.6 MB"
+	if len(f.Warnings) != 1 || f.Warnings[0].Cause != want {
+		t.Fatalf("distribution-key warnings = %+v, want %q", f.Warnings, want)
+	}
+	if w := f.Warnings[0]; strings.Join(w.Remediation, " ") != "ALTER TABLE pa_expenditure_items_all SET DISTRIBUTED BY (project_id);" {
+		t.Errorf("remediation = %q", w.Remediation)
+	}
+
+	// Smaller tables are reported with a lower distribution_min_mb
+	p := NewParams()
+	p.Set("distribution_min_mb", 5)
+	e = loadTestExplain(t, "../testdata/explain05.txt")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"distribution-key"}, Params: p})
+	if len(f.Warnings) != 1 || strings.HasPrefix(f.Warnings[0].Cause, "\"sales\" is moved on (year) by 1 motion, an estimated 83.9 MB") == false {
+		t.Errorf("distribution-key warnings = %+v", f.Warnings)
+	}
+}
+
+// Only tables moving distribution_min_mb or more are distributed
+func TestDistributionRemediation(t *testing.T) {
+	all := allDistributionParams()
+
+	tests := []struct {
+		filename string
+		params   *Params
+		want     []string
+	}{
+		{"../testdata/explain16.txt", NewParams(), []string{"ALTER TABLE pa_expenditure_items_all SET DISTRIBUTED BY (project_id);"}},
+		// The redistributed side of a.col1 = b.col2 moves 7.6 MB
+		{"../testdata/explain18.txt", NewParams(), []string{}},
+		{"../testdata/explain18.txt", all, []string{"ALTER TABLE bigtable SET DISTRIBUTED BY (col2);"}},
+		{"../testdata/explain17.txt", all, []string{
+			"ALTER TABLE d_date SET DISTRIBUTED BY (date_id);",
+			"ALTER TABLE d_customer_account SET DISTRIBUTED BY (account_sk);",
+		}},
+		{"../testdata/explain12.txt", all, []string{}},
+	}
+
+	for _, test := range tests {
+		got := distributionRemediation(loadTestExplain(t, test.filename), test.params)
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: remediation with distribution_min_mb %v = %q, want %q", test.filename, test.params.Float("distribution_min_mb"), got, test.want)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	if got := plural(1, "motion"); got != "1 motion" {
+		t.Errorf("plural(1) = %q", got)
+	}
+	if got := plural(3, "motion"); got != "3 motions" {
+		t.Errorf("plural(3) = %q", got)
+	}
+}
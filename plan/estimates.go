@@ -0,0 +1,122 @@
+package plan
+
+import (
+	"math"
+	"strconv"
+)
+
+// Cardinality estimation quality
+//
+// The estimated Rows of a node is per segment and per scan, so the actual
+// rows are converted to the same unit before comparing:
+//
+//	total actual rows  = AvgRows x Workers, or ActualRows when one worker reports
+//	ActualRowsPerSeg   = total actual rows / segments / scans
+//
+// segments is taken from the slice the node runs in, falling back to Workers
+// when unknown. Nodes in slice 0 run on the master so segments is 1.
+// A Motion reports the rows at its destination, so segments is the M
+// receivers of "Motion N:M" rather than the N senders of its slice, i.e. 1
+// for a Gather Motion.
+//
+// The q-error is the factor between estimate and actual, always >= 1:
+//
+//	QError = max(estimate, actual) / min(estimate, actual)
+//
+// Both values are raised to at least 1 row so empty results do not divide by zero.
+
+// Populate ActualRowsPerSeg and QError on every analyzed node
+func (e *Explain) calculateQErrors() {
+	for _, n := range e.Nodes {
+		n.ActualRowsPerSeg = OptionalCount{}
+		n.QError = OptionalFloat{}
+
+		total := 0.0
+		workers := int64(1)
+		if n.AvgRows.Valid && n.Workers.Valid {
+			total = n.AvgRows.Value * float64(n.Workers.Value)
+			workers = n.Workers.Value
+		} else if n.ActualRows.Valid {
+			total = n.ActualRows.Value
+		} else {
+			continue
+		}
+
+		segments := workers
+		if _, receivers := n.MotionSegments(); receivers.Valid {
+			segments = receivers.Value
+		} else if s := n.SegmentCount(); s.Valid {
+			segments = s.Value
+		}
+		if segments < 1 {
+			segments = 1
+		}
+
+		actual := total / float64(segments)
+		if n.Scans.Value > 1 {
+			actual = actual / float64(n.Scans.Value)
+		}
+
+		n.ActualRowsPerSeg = validCount(actual)
+		n.QError = validFloat(qError(float64(n.Rows), actual))
+	}
+}
+
+func qError(estimate float64, actual float64) float64 {
+	estimate = math.Max(estimate, 1)
+	actual = math.Max(actual, 1)
+	return math.Max(estimate, actual) / math.Min(estimate, actual)
+}
+
+// True if the node produced more rows than estimated
+func (n *Node) IsUnderestimated() bool {
+	return n.ActualRowsPerSeg.Valid && n.ActualRowsPerSeg.Value > float64(n.Rows)
+}
+
+// True if a child is misestimated in the same direction by at least as
+// much, so the misestimate of the node comes from below
+func (n *Node) inheritsMisestimate() bool {
+	for _, c := range n.SubNodes {
+		if c.QError.Valid && c.QError.Value >= n.QError.Value && c.IsUnderestimated() == n.IsUnderestimated() {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the number of segments the node runs on.
+// Nodes above the top Motion run on the master, i.e. 1 segment.
+func (n *Node) SegmentCount() OptionalInt {
+	for c := n; c != nil; c = c.Parent {
+		if c.Slice.Valid {
+			return c.Segments
+		}
+	}
+	return validInt(1)
+}
+
+// Return the number of sending and receiving segments of a Motion, e.g. 2 and
+// 1 for "Gather Motion 2:1". Not valid for other nodes.
+func (n *Node) MotionSegments() (OptionalInt, OptionalInt) {
+	if n.Category != CategoryMotion {
+		return OptionalInt{}, OptionalInt{}
+	}
+	m := patterns["MOTION"].FindStringSubmatch(n.Operator)
+	if len(m) != 3 {
+		return OptionalInt{}, OptionalInt{}
+	}
+	senders, err1 := strconv.ParseInt(m[1], 10, 64)
+	receivers, err2 := strconv.ParseInt(m[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return OptionalInt{}, OptionalInt{}
+	}
+	return validInt(senders), validInt(receivers)
+}
+
+// Return up to limit nodes with the highest q-error, worst first.
+// Only nodes with a q-error greater than 1 are included.
+func (e *Explain) WorstMisestimates(limit int) []*Node {
+	return topNodes(e.Nodes, limit, func(n *Node) (float64, bool) {
+		return n.QError.Value, n.QError.Valid && n.QError.Value > 1
+	})
+}
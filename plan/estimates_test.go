@@ -0,0 +1,153 @@
+package plan
+
+import (
+	"testing"
+)
+
+func TestQError(t *testing.T) {
+	tests := []struct {
+		estimate float64
+		actual   float64
+		want     float64
+	}{
+		{1, 1, 1},
+		{100, 1, 100},
+		{1, 100, 100},
+		{0, 0, 1},
+		{10, 0.5, 10},
+		{50, 200, 4},
+	}
+
+	for _, test := range tests {
+		if got := qError(test.estimate, test.actual); got != test.want {
+			t.Errorf("qError(%v, %v) = %v, want %v", test.estimate, test.actual, got, test.want)
+		}
+	}
+}
+
+func TestMotionSegments(t *testing.T) {
+	tests := []struct {
+		filename  string
+		id        int
+		senders   OptionalInt
+		receivers OptionalInt
+	}{
+		{"../testdata/explain05.txt", 0, validInt(2), validInt(1)},
+		{"../testdata/explain05.txt", 6, validInt(2), validInt(2)},
+		{"../testdata/explain05.txt", 1, OptionalInt{}, OptionalInt{}},
+		{"../testdata/explain12.txt", 0, validInt(40), validInt(1)},
+		{"../testdata/explain10.txt", 3, validInt(1), validInt(2)},
+	}
+
+	for _, test := range tests {
+		n := loadTestExplain(t, test.filename).Nodes[test.id]
+		senders, receivers := n.MotionSegments()
+		if senders != test.senders || receivers != test.receivers {
+			t.Errorf("%s: %s has %s:%s segments, want %s:%s", test.filename, n.Operator, senders, receivers, test.senders, test.receivers)
+		}
+	}
+}
+
+// Actual rows are compared per segment and per scan, as estimated
+func TestQErrorsExplain05(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+
+	tests := []struct {
+		id     int
+		perSeg float64
+		why    string
+	}{
+		{0, 11000, "a Gather Motion receives all rows on the coordinator"},
+		{1, 5500, "11000 rows reported by one segment, 2 segments in the slice"},
+		{4, 2750, "avg 2750 rows x 2 workers over 2 segments"},
+		{6, 5500000, "a Redistribute Motion receives on 2 segments"},
+		{3, 0, "no rows out of the Partition Selector"},
+	}
+	for _, test := range tests {
+		n := e.Nodes[test.id]
+		want := qError(float64(n.Rows), test.perSeg)
+		if n.ActualRowsPerSeg != validCount(test.perSeg) || n.QError != validFloat(want) {
+			t.Errorf("node #%d %s: %s rows per segment, q-error %s, want %v and %.1f: %s", n.Id, n.Operator, n.ActualRowsPerSeg, n.QError, test.perSeg, want, test.why)
+		}
+	}
+	if e.Nodes[3].IsUnderestimated() || e.Nodes[4].IsUnderestimated() == false {
+		t.Errorf("Partition Selector estimating 50 rows is not over estimated or the scan is not underestimated")
+	}
+
+	// A rescanned node is compared per scan
+	e = loadTestExplain(t, "../testdata/explain22.txt")
+	if n := e.Nodes[7]; n.ActualRowsPerSeg != validCount(7920.0*2/2/96) {
+		t.Errorf("Materialize rescanned 96 times has %s rows per segment and scan", n.ActualRowsPerSeg)
+	}
+
+	// Plans without EXPLAIN ANALYZE have no q-error
+	e = loadTestExplain(t, "../testdata/explain01.txt")
+	for _, n := range e.Nodes {
+		if n.QError.Valid || n.ActualRowsPerSeg.Valid {
+			t.Errorf("explain01 node #%d has q-error %s", n.Id, n.QError)
+		}
+	}
+}
+
+func TestWorstMisestimates(t *testing.T) {
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+		worst := e.WorstMisestimates(3)
+		if len(worst) > 3 {
+			t.Errorf("%s: %d misestimates returned, limit 3", filename, len(worst))
+		}
+		for i, n := range worst {
+			if n.QError.Value <= 1 || (i > 0 && n.QError.Value > worst[i-1].QError.Value) {
+				t.Errorf("%s: misestimate %d has q-error %s", filename, i, n.QError)
+			}
+		}
+	}
+
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+	worst := e.WorstMisestimates(2)
+	if len(worst) != 2 || worst[0].QError.Value != 5500000 || worst[1].QError.Value != 5500000 {
+		t.Errorf("worst misestimates of explain05 = %v", nodeIds(worst))
+	}
+}
+
+// Nodes misestimated by misestimate_factor or more are reported, unless
+// they are small or carry up the misestimate of a child
+func TestRowMisestimateCheck(t *testing.T) {
+	got := testdataWarnings(t, "row-misestimate")
+	compareTestdataWarnings(t, got, map[string][]string{
+		"explain02.txt": {"#0 row-misestimate", "#3 row-misestimate"},
+		"explain04.txt": {"#2 row-misestimate", "#3 row-misestimate"},
+		// The Gather Motion #0 is underestimated 11000x, more than its child
+		"explain05.txt": {"#0 row-misestimate", "#4 row-misestimate", "#9 row-misestimate"},
+		"explain12.txt": {"#0 row-misestimate", "#8 row-misestimate"},
+		"explain13.txt": {"#5 row-misestimate"},
+		"explain15.txt": {"#8 row-misestimate", "#10 row-misestimate", "#11 row-misestimate"},
+		"explain18.txt": {"#1 row-misestimate"},
+	})
+
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+	f := runNodeCheck(t, e, "checkNodeRowMisestimate")
+	want := "Rows underestimated by 11000x (estimated 1, actual 11000 per segment)"
+	if w := f.NodeWarnings[e.Nodes[0]]; len(w) != 1 || w[0].Cause != want {
+		t.Errorf("Gather Motion warnings = %v, want %q", w, want)
+	}
+	// The Redistribute Motion #6 passes on the 5500000x of the scan #7 below
+	if len(f.NodeWarnings[e.Nodes[6]]) != 0 || e.Nodes[6].inheritsMisestimate() == false {
+		t.Errorf("Redistribute Motion warnings = %v", f.NodeWarnings[e.Nodes[6]])
+	}
+
+	// Every node misestimated enough is reported without a minimum of rows
+	p := NewParams()
+	p.Set("misestimate_min_rows", 0)
+	e = loadTestExplain(t, "../testdata/explain14.txt")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"row-misestimate"}, Params: p})
+	for _, n := range e.Nodes {
+		want := n.QError.Valid && n.QError.Value >= p.Float("misestimate_factor") && n.inheritsMisestimate() == false
+		if want != (len(f.NodeWarnings[n]) == 1) {
+			t.Errorf("explain14: node #%d with q-error %s has %d warnings", n.Id, n.QError, len(f.NodeWarnings[n]))
+		}
+	}
+	if len(f.NodeWarnings) == 0 {
+		t.Errorf("explain14 without a minimum of rows reported on no nodes")
+	}
+}
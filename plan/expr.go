@@ -0,0 +1,596 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression language used by rule checks
+//
+// An expression is evaluated against the fields of a node or of the whole
+// EXPLAIN output, see rules.go for the available fields:
+//
+//	type == "Seq Scan" && object like "fact_%" && rows > 1e8
+//	spill_files > 0 or ms_node > 0.5 * ms_end
+//	setting("optimizer") == "off"
+//
+// Values are numbers, strings, booleans or null. Fields not present in the
+// plan, e.g. timings of an EXPLAIN without ANALYZE, are null. Comparisons
+// involving null or values of different types are false.
+//
+// Operators, lowest precedence first:
+//
+//	|| or
+//	&& and
+//	! not
+//	== != < <= > >= like ilike ~ !~
+//	+ -
+//	* /
+//
+// like and ilike use SQL patterns (% and _), ~ and !~ regular expressions.
+// Functions: setting(name), lower(s), upper(s), abs(x).
+
+// A compiled expression
+type Expr struct {
+	Source string
+	root   exprNode
+}
+
+// Looks up the value of a field
+type exprEnv func(name string) interface{}
+
+type exprNode interface {
+	eval(env exprEnv) interface{}
+}
+
+type exprLiteral struct {
+	value interface{}
+}
+
+type exprField struct {
+	name string
+}
+
+type exprUnary struct {
+	op      string
+	operand exprNode
+}
+
+type exprBinary struct {
+	op          string
+	left, right exprNode
+	pattern     *regexp.Regexp // Compiled once when the right side is a literal
+}
+
+type exprCall struct {
+	name string
+	args []exprNode
+}
+
+type exprToken struct {
+	kind  string // "num", "str", "ident", "op", "eof"
+	text  string
+	value interface{}
+	pos   int
+}
+
+type exprParser struct {
+	src    string
+	tokens []exprToken
+	pos    int
+	fields map[string]bool
+}
+
+var exprFunctions = map[string]int{
+	"setting": 1,
+	"lower":   1,
+	"upper":   1,
+	"abs":     1,
+}
+
+// Compile an expression, fields are the names it may refer to
+func CompileExpr(src string, fields []string) (*Expr, error) {
+	tokens, err := tokenizeExpr(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{src: src, tokens: tokens, fields: map[string]bool{}}
+	for _, f := range fields {
+		p.fields[f] = true
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != "eof" {
+		return nil, p.errorf(t, "unexpected \"%s\"", t.text)
+	}
+
+	return &Expr{src, root}, nil
+}
+
+// Evaluate the expression
+func (x *Expr) Eval(env exprEnv) interface{} {
+	return x.root.eval(env)
+}
+
+// Evaluate the expression as a condition
+func (x *Expr) Match(env exprEnv) bool {
+	return exprTruth(x.Eval(env))
+}
+
+func tokenizeExpr(src string) ([]exprToken, error) {
+	tokens := []exprToken{}
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(src) && unicode.IsDigit(rune(src[i+1]))):
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.' || src[i] == 'e' || src[i] == 'E' ||
+				((src[i] == '-' || src[i] == '+') && (src[i-1] == 'e' || src[i-1] == 'E'))) {
+				i++
+			}
+			f, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid number \"%s\" at position %d", src[start:i], start+1))
+			}
+			tokens = append(tokens, exprToken{"num", src[start:i], f, start})
+
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			s := ""
+			for i < len(src) && rune(src[i]) != c {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				s += string(src[i])
+				i++
+			}
+			if i >= len(src) {
+				return nil, errors.New(fmt.Sprintf("unterminated string at position %d", start+1))
+			}
+			i++
+			tokens = append(tokens, exprToken{"str", src[start:i], s, start})
+
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i])) || src[i] == '_') {
+				i++
+			}
+			word := src[start:i]
+			switch strings.ToLower(word) {
+			case "and", "or", "not", "like", "ilike":
+				tokens = append(tokens, exprToken{"op", strings.ToLower(word), nil, start})
+			case "true":
+				tokens = append(tokens, exprToken{"num", word, true, start})
+			case "false":
+				tokens = append(tokens, exprToken{"num", word, false, start})
+			case "null":
+				tokens = append(tokens, exprToken{"num", word, nil, start})
+			default:
+				tokens = append(tokens, exprToken{"ident", word, nil, start})
+			}
+
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "!~", "<", ">", "!", "~", "+", "-", "*", "/", "(", ")", ","} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, errors.New(fmt.Sprintf("unexpected \"%c\" at position %d", c, i+1))
+			}
+			tokens = append(tokens, exprToken{"op", op, nil, i})
+			i += len(op)
+		}
+	}
+	tokens = append(tokens, exprToken{"eof", "end of expression", nil, len(src)})
+	return tokens, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != "eof" {
+		p.pos++
+	}
+	return t
+}
+
+// Consume the next token if it is one of the operators
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != "op" {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) errorf(t exprToken, format string, v ...interface{}) error {
+	return errors.New(fmt.Sprintf("%s at position %d", fmt.Sprintf(format, v...), t.pos+1))
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); ok == false {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: "or", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); ok == false {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: "and", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if _, ok := p.accept("!", "not"); ok {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{"not", operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "like", "ilike", "~", "!~")
+	if ok == false {
+		return left, nil
+	}
+
+	t := p.peek()
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &exprBinary{op: op, left: left, right: right}
+
+	// Patterns are compiled once if they are constant
+	if lit, ok := right.(*exprLiteral); ok {
+		if s, ok := lit.value.(string); ok && (op == "like" || op == "ilike" || op == "~" || op == "!~") {
+			b.pattern, err = compilePattern(op, s)
+			if err != nil {
+				return nil, p.errorf(t, "invalid pattern: %s", err)
+			}
+		}
+	}
+
+	return b, nil
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if ok == false {
+			return left, nil
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*", "/")
+		if ok == false {
+			return left, nil
+		}
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case "num", "str":
+		return &exprLiteral{t.value}, nil
+
+	case "ident":
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+		if p.fields[t.text] == false {
+			return nil, p.errorf(t, "unknown field \"%s\"", t.text)
+		}
+		return &exprField{t.text}, nil
+
+	case "op":
+		if t.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.accept(")"); ok == false {
+				return nil, p.errorf(p.peek(), "expected \")\"")
+			}
+			return inner, nil
+		}
+		if t.text == "-" {
+			operand, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			return &exprUnary{"-", operand}, nil
+		}
+	}
+	return nil, p.errorf(t, "unexpected \"%s\"", t.text)
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	argCount, ok := exprFunctions[name.text]
+	if ok == false {
+		return nil, p.errorf(name, "unknown function \"%s\"", name.text)
+	}
+
+	call := &exprCall{name: name.text}
+	if _, ok := p.accept(")"); ok == false {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if _, ok := p.accept(","); ok {
+				continue
+			}
+			if _, ok := p.accept(")"); ok == false {
+				return nil, p.errorf(p.peek(), "expected \")\"")
+			}
+			break
+		}
+	}
+
+	if len(call.args) != argCount {
+		return nil, p.errorf(name, "%s() takes %d argument(s)", name.text, argCount)
+	}
+	return call, nil
+}
+
+// Convert a like/ilike or regular expression pattern
+func compilePattern(op string, pattern string) (*regexp.Regexp, error) {
+	if op == "~" || op == "!~" {
+		return regexp.Compile(pattern)
+	}
+
+	re := "^"
+	for _, c := range pattern {
+		switch c {
+		case '%':
+			re += ".*"
+		case '_':
+			re += "."
+		default:
+			re += regexp.QuoteMeta(string(c))
+		}
+	}
+	re += "$"
+	if op == "ilike" {
+		re = "(?i)" + re
+	}
+	return regexp.Compile(re)
+}
+
+func (l *exprLiteral) eval(env exprEnv) interface{} {
+	return l.value
+}
+
+func (f *exprField) eval(env exprEnv) interface{} {
+	return env(f.name)
+}
+
+func (u *exprUnary) eval(env exprEnv) interface{} {
+	v := u.operand.eval(env)
+	if u.op == "not" {
+		return exprTruth(v) == false
+	}
+	if f, ok := v.(float64); ok {
+		return -f
+	}
+	return nil
+}
+
+func (b *exprBinary) eval(env exprEnv) interface{} {
+	switch b.op {
+	case "and":
+		return exprTruth(b.left.eval(env)) && exprTruth(b.right.eval(env))
+	case "or":
+		return exprTruth(b.left.eval(env)) || exprTruth(b.right.eval(env))
+	}
+
+	left := b.left.eval(env)
+	right := b.right.eval(env)
+
+	switch b.op {
+	case "==":
+		return left != nil && left == right
+	case "!=":
+		return left != nil && right != nil && left != right
+	case "like", "ilike", "~", "!~":
+		s, ok := left.(string)
+		if ok == false {
+			return false
+		}
+		re := b.pattern
+		if re == nil {
+			pattern, ok := right.(string)
+			if ok == false {
+				return false
+			}
+			var err error
+			if re, err = compilePattern(b.op, pattern); err != nil {
+				return false
+			}
+		}
+		if b.op == "!~" {
+			return re.MatchString(s) == false
+		}
+		return re.MatchString(s)
+	}
+
+	// Remaining operators need two numbers or, for ordering, two strings
+	lf, lok := left.(float64)
+	rf, rok := right.(float64)
+	if lok == false || rok == false {
+		ls, lok := left.(string)
+		rs, rok := right.(string)
+		if lok == false || rok == false {
+			if b.op == "+" || b.op == "-" || b.op == "*" || b.op == "/" {
+				return nil
+			}
+			return false
+		}
+		switch b.op {
+		case "<":
+			return ls < rs
+		case "<=":
+			return ls <= rs
+		case ">":
+			return ls > rs
+		case ">=":
+			return ls >= rs
+		case "+":
+			return ls + rs
+		}
+		return nil
+	}
+
+	switch b.op {
+	case "<":
+		return lf < rf
+	case "<=":
+		return lf <= rf
+	case ">":
+		return lf > rf
+	case ">=":
+		return lf >= rf
+	case "+":
+		return lf + rf
+	case "-":
+		return lf - rf
+	case "*":
+		return lf * rf
+	case "/":
+		if rf == 0 {
+			return nil
+		}
+		return lf / rf
+	}
+	return nil
+}
+
+func (c *exprCall) eval(env exprEnv) interface{} {
+	arg := c.args[0].eval(env)
+	switch c.name {
+	case "setting":
+		if name, ok := arg.(string); ok {
+			return env("setting:" + name)
+		}
+	case "lower":
+		if s, ok := arg.(string); ok {
+			return strings.ToLower(s)
+		}
+	case "upper":
+		if s, ok := arg.(string); ok {
+			return strings.ToUpper(s)
+		}
+	case "abs":
+		if f, ok := arg.(float64); ok {
+			return math.Abs(f)
+		}
+	}
+	return nil
+}
+
+// Values are true if they are true, a non zero number or a non empty string
+func exprTruth(v interface{}) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case float64:
+		return value != 0
+	case string:
+		return value != ""
+	}
+	return false
+}
+
+// Format a value for output in a message, numbers with up to 2 decimals
+func formatExprValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "-"
+	case float64:
+		if value == math.Trunc(value) {
+			return strconv.FormatFloat(value, 'f', 0, 64)
+		}
+		return strings.TrimRight(strconv.FormatFloat(value, 'f', 2, 64), "0")
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,184 @@
+package plan
+
+import (
+	"testing"
+)
+
+// Fields of the environment used by the expression tests
+var exprTestFields = map[string]interface{}{
+	"rows":     1000.0,
+	"width":    8.0,
+	"type":     "Seq Scan",
+	"object":   "fact_sales",
+	"analyzed": true,
+	"ms_node":  nil,
+	"empty":    "",
+}
+
+func exprTestEnv(name string) interface{} {
+	if name == "setting:optimizer" {
+		return "off"
+	}
+	return exprTestFields[name]
+}
+
+func exprTestFieldNames() []string {
+	names := []string{}
+	for name := range exprTestFields {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestExprEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		// Precedence
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"10 - 4 - 3", 3.0},
+		{"12 / 2 / 3", 2.0},
+		{"-2 * 3", -6.0},
+		{"1 + 2 > 2 && 2 < 1 + 2", true},
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+		{"!false && false", false},
+		{"not rows > 10", false},
+		{"rows > 10 and width < 4 or type == \"Seq Scan\"", true},
+		{"1e3 == rows", true},
+		{".5 * 4", 2.0},
+
+		// Fields and functions
+		{"rows * width", 8000.0},
+		{"object + \"_1\"", "fact_sales_1"},
+		{"lower(\"ABC\")", "abc"},
+		{"upper(type)", "SEQ SCAN"},
+		{"abs(-3)", 3.0},
+		{"setting(\"optimizer\") == \"off\"", true},
+		{"setting(\"unknown\")", nil},
+
+		// Null and mismatched types
+		{"ms_node", nil},
+		{"ms_node > 0", false},
+		{"ms_node < 0", false},
+		{"ms_node == null", false},
+		{"ms_node != 1", false},
+		{"ms_node + 1", nil},
+		{"rows == \"1000\"", false},
+		{"rows / 0", nil},
+		{"-type", nil},
+		{"abs(type)", nil},
+
+		// Truth of values
+		{"!empty", true},
+		{"!object", false},
+		{"!0", true},
+		{"!ms_node", true},
+
+		// Strings
+		{"\"abc\" < \"abd\"", true},
+		{"'it\\'s' == \"it's\"", true},
+
+		// Patterns
+		{"object like \"fact_%\"", true},
+		{"object like \"FACT_%\"", false},
+		{"object ilike \"FACT_%\"", true},
+		{"object like \"fact\"", false},
+		{"object like \"fact_sale_\"", true},
+		{"object like \"f.ct%\"", false},
+		{"object ~ \"^fact_(sales|orders)$\"", true},
+		{"object !~ \"^dim_\"", true},
+		{"object like type", false},
+		{"rows like \"1%\"", false},
+		{"ms_node ~ \".*\"", false},
+	}
+
+	for _, test := range tests {
+		x, err := CompileExpr(test.src, exprTestFieldNames())
+		if err != nil {
+			t.Errorf("CompileExpr(%q) returned error: %s", test.src, err)
+			continue
+		}
+		if got := x.Eval(exprTestEnv); got != test.want {
+			t.Errorf("%q = %#v, want %#v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "unexpected \"end of expression\" at position 1"},
+		{"rows >", "unexpected \"end of expression\" at position 7"},
+		{"rows > 1 1", "unexpected \"1\" at position 10"},
+		{"(rows > 1", "expected \")\" at position 10"},
+		{"cost > 1", "unknown field \"cost\" at position 1"},
+		{"foo(rows)", "unknown function \"foo\" at position 1"},
+		{"lower()", "lower() takes 1 argument(s) at position 1"},
+		{"lower(type, type)", "lower() takes 1 argument(s) at position 1"},
+		{"lower(type", "expected \")\" at position 11"},
+		{"type == \"Seq", "unterminated string at position 9"},
+		{"rows > 1.2.3", "invalid number \"1.2.3\" at position 8"},
+		{"rows # 1", "unexpected \"#\" at position 6"},
+		{"object ~ \"(\"", "invalid pattern: error parsing regexp: missing closing ): `(` at position 10"},
+	}
+
+	for _, test := range tests {
+		_, err := CompileExpr(test.src, exprTestFieldNames())
+		if err == nil {
+			t.Errorf("CompileExpr(%q) did not return an error", test.src)
+			continue
+		}
+		if err.Error() != test.want {
+			t.Errorf("CompileExpr(%q) error = %q, want %q", test.src, err, test.want)
+		}
+	}
+}
+
+func TestExprMatch(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"rows", true},
+		{"type", true},
+		{"empty", false},
+		{"ms_node", false},
+		{"rows - 1000", false},
+	}
+
+	for _, test := range tests {
+		x, err := CompileExpr(test.src, exprTestFieldNames())
+		if err != nil {
+			t.Errorf("CompileExpr(%q) returned error: %s", test.src, err)
+			continue
+		}
+		if got := x.Match(exprTestEnv); got != test.want {
+			t.Errorf("Match(%q) = %t, want %t", test.src, got, test.want)
+		}
+	}
+}
+
+func TestFormatExprValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, "-"},
+		{1000.0, "1000"},
+		{1.5, "1.5"},
+		{2.126, "2.13"},
+		{"abc", "abc"},
+		{true, "true"},
+	}
+
+	for _, test := range tests {
+		if got := formatExprValue(test.value); got != test.want {
+			t.Errorf("formatExprValue(%#v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
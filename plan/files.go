@@ -0,0 +1,32 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Profile, rule and waiver files
+//
+// Files ending in .json are read as JSON, anything else as YAML with
+// gopkg.in/yaml.v2. Both are decoded into the same structs and are strict:
+// keys that are not a field of the struct are an error, as are values of the
+// wrong type, e.g. a quoted number for a parameter. Checks on the values
+// themselves are done after decoding so they are the same for both formats.
+//
+// Values starting with { or [ must be quoted in YAML, e.g.
+//
+//	cause: "{{rows}} rows"
+
+// Decode a JSON or YAML file into v, depending on the extension of filename
+func unmarshalFile(filename string, data []byte, v interface{}) error {
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.DisallowUnknownFields()
+		return d.Decode(v)
+	}
+	return yaml.UnmarshalStrict(data, v)
+}
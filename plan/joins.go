@@ -0,0 +1,254 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Join analysis
+//
+// The first child of a join is the outer side and the second the inner side.
+// For a Hash Join the inner side is the Hash node, which builds the hash
+// table from its child, and the outer side probes it:
+//
+//	->  Hash Join
+//	      Hash Cond: a.id = b.id
+//	      ->  Seq Scan on a       outer, probe side
+//	      ->  Hash                inner, build side
+//	            ->  Seq Scan on b
+//
+// The checks in this file report:
+//   - cartesian-join: Nested Loops without any join condition
+//   - nested-loop-rescan: inner sides of a Nested Loop read in full once per
+//     outer row, i.e. not through an index using the outer row
+//   - hash-build-larger: inner Hash Joins building the hash table on the
+//     larger side
+//   - merge-join-sorts: Merge Joins sorting both inputs
+//   - join-key-cast: join keys compared with a cast on either side
+//
+// Sizes are rows x width per segment, using the actual rows of EXPLAIN
+// ANALYZE output when they are available.
+
+// Return the outer child of a join, nil for other nodes
+func (n *Node) OuterChild() *Node {
+	if n.Category != CategoryJoin || len(n.SubNodes) < 1 {
+		return nil
+	}
+	return n.SubNodes[0]
+}
+
+// Return the inner child of a join, nil for other nodes.
+// For a Hash Join this is the Hash node.
+func (n *Node) InnerChild() *Node {
+	if n.Category != CategoryJoin || len(n.SubNodes) < 2 {
+		return nil
+	}
+	return n.SubNodes[1]
+}
+
+// Rows per segment, actual if available else estimated
+func (n *Node) rowsPerSegment() float64 {
+	if n.ActualRowsPerSeg.Valid {
+		return n.ActualRowsPerSeg.Value
+	}
+	return float64(n.Rows)
+}
+
+// Bytes per segment, actual if available else estimated
+func (n *Node) bytesPerSegment() ByteSize {
+	return ByteSize(n.rowsPerSegment() * float64(n.Width))
+}
+
+// True if the node or any node below it has a condition line
+func hasConditionBelow(n *Node, names ...string) bool {
+	found := false
+	n.Walk(func(d *Node) error {
+		for _, name := range names {
+			if d.Condition(name) != "" {
+				found = true
+			}
+		}
+		return nil
+	}, nil)
+	return found
+}
+
+// Follow Materialize nodes down to the node producing the rows
+func skipMaterialize(n *Node) *Node {
+	for n.IsType(NodeTypeMaterialize) && len(n.SubNodes) == 1 {
+		n = n.SubNodes[0]
+	}
+	return n
+}
+
+// Describe a join key, e.g. "a.id::numeric = b.id"
+func (k JoinKey) String() string {
+	left := k.Left.String()
+	if k.LeftCast != "" {
+		left += "::" + k.LeftCast
+	}
+	right := k.Right.String()
+	if k.RightCast != "" {
+		right += "::" + k.RightCast
+	}
+	return left + " = " + right
+}
+
+var joinChecks = []NodeCheck{
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "cartesian-join",
+			Name:          "checkNodeCartesianJoin",
+			Description:   "Nested Loop without a join condition",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryJoins,
+			Severity:      SeverityWarning,
+			Documentation: "The Nested Loop has no Join Filter and the inner side does not use an index on the outer rows, so every row is joined with every other row. This usually comes from a missing join condition.",
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeNestedLoop) == false || n.InnerChild() == nil {
+				return
+			}
+			if n.Condition("Join Filter") != "" || hasConditionBelow(n.InnerChild(), "Index Cond", "Recheck Cond") {
+				return
+			}
+
+			outer := n.OuterChild().rowsPerSegment()
+			inner := skipMaterialize(n.InnerChild()).rowsPerSegment()
+			if outer <= 1 || inner <= 1 {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("Nested Loop without a join condition joins %.0f x %.0f rows per segment", outer, inner),
+				Resolution: "Check the query joins all tables, a missing join condition produces a cartesian product"})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "nested-loop-rescan",
+			Name:          "checkNodeNestedLoopRescan",
+			Description:   "Inner side of a Nested Loop read many times",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryJoins,
+			Severity:      SeverityWarning,
+			Documentation: "The inner side of the Nested Loop is read in full for every outer row, nested_loop_rescans times or more. Without an index lookup on the outer row this grows with outer x inner rows; a Hash Join reads each side once.",
+			Parameters:    []string{"nested_loop_rescans"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeNestedLoop) == false || n.InnerChild() == nil {
+				return
+			}
+			inner := n.InnerChild()
+			if hasConditionBelow(inner, "Index Cond", "Recheck Cond") {
+				return
+			}
+
+			rescans := int64(n.OuterChild().rowsPerSegment())
+			if inner.Scans.Valid {
+				rescans = inner.Scans.Value
+			}
+			if rescans < f.Params.Int("nested_loop_rescans") {
+				return
+			}
+
+			source := skipMaterialize(inner)
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("Inner side %s (#%d) of %.0f rows, %s, is read %d times", source.Operator, source.Id, source.rowsPerSegment(), source.bytesPerSegment(), rescans),
+				Resolution: "Check the join condition can use a Hash Join, or an index on the inner side"})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "hash-build-larger",
+			Name:          "checkNodeHashBuildLarger",
+			Description:   "Hash Join building the hash table on the larger side",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryJoins,
+			Severity:      SeverityWarning,
+			Documentation: "An inner Hash Join should build the hash table on the smaller side. Here the build (inner) side is hash_build_ratio times larger than the probe (outer) side, which uses more memory and can spill. The optimizer chose it from wrong row estimates.",
+			Parameters:    []string{"hash_build_ratio", "join_min_rows"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeHashJoin) == false || n.JoinType != JoinTypeInner || n.InnerChild() == nil {
+				return
+			}
+			probe := n.OuterChild()
+			build := n.InnerChild()
+			if build.IsType(NodeTypeHash) && len(build.SubNodes) == 1 {
+				build = build.SubNodes[0]
+			}
+
+			if build.rowsPerSegment() < f.Params.Float("join_min_rows") {
+				return
+			}
+			if float64(build.bytesPerSegment()) < float64(probe.bytesPerSegment())*f.Params.Float("hash_build_ratio") {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause: fmt.Sprintf("Hash table built on %.0f rows (%s) and probed with %.0f rows (%s) per segment",
+					build.rowsPerSegment(), build.bytesPerSegment(), probe.rowsPerSegment(), probe.bytesPerSegment()),
+				Resolution:  "Check the statistics of both sides so the smaller side is hashed",
+				Remediation: analyzeRemediation(build, probe)})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "merge-join-sorts",
+			Name:          "checkNodeMergeJoinSorts",
+			Description:   "Merge Join sorting both inputs",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryJoins,
+			Severity:      SeverityWarning,
+			Documentation: "Both inputs of the Merge Join are sorted for the join. Sorting both sides is usually slower than a Hash Join, which Greenplum prefers unless enable_mergejoin is on.",
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeMergeJoin) == false || n.InnerChild() == nil {
+				return
+			}
+			outer := skipMaterialize(n.OuterChild())
+			inner := skipMaterialize(n.InnerChild())
+			if outer.IsType(NodeTypeSort) == false || inner.IsType(NodeTypeSort) == false {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("Both inputs are sorted for the Merge Join, %.0f rows (%s) and %.0f rows (%s) per segment", outer.rowsPerSegment(), outer.bytesPerSegment(), inner.rowsPerSegment(), inner.bytesPerSegment()),
+				Resolution: "Check if a Hash Join would be cheaper, e.g. with enable_mergejoin off"})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "join-key-cast",
+			Name:          "checkNodeJoinKeyCast",
+			Description:   "Join keys compared with a cast",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryJoins,
+			Severity:      SeverityWarning,
+			Documentation: "A join key is cast before comparing, because the columns have different types or the query casts them. Every row is converted, the distribution of the tables can not be used and the row estimate of the join is less accurate.",
+		},
+		Exec: func(n *Node, f *Findings) {
+			cast := []string{}
+			for _, key := range n.JoinKeys() {
+				if key.LeftCast != "" || key.RightCast != "" {
+					cast = append(cast, key.String())
+				}
+			}
+			if len(cast) == 0 {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("Join keys compared with a cast: %s", strings.Join(cast, ", ")),
+				Resolution: "Use the same data type for the joined columns"})
+		}},
+}
+
+func init() {
+	for _, c := range joinChecks {
+		RegisterNodeCheck(c)
+	}
+}
@@ -0,0 +1,104 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJoinChildren(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain18.txt")
+	join := e.Nodes[1]
+	if join.OuterChild() != e.Nodes[2] || join.InnerChild() != e.Nodes[4] || e.Nodes[4].IsType(NodeTypeHash) == false {
+		t.Errorf("Hash Join outer #%d and inner #%d, want #2 and the Hash #4", join.OuterChild().Id, join.InnerChild().Id)
+	}
+	if e.Nodes[2].OuterChild() != nil || e.Nodes[2].InnerChild() != nil {
+		t.Errorf("Redistribute Motion has join children")
+	}
+
+	if got := join.Condition("Hash Cond"); got != "b.col2 = a.col1" {
+		t.Errorf("Hash Cond = %q", got)
+	}
+	if got := join.Condition("Join Filter"); got != "" {
+		t.Errorf("Join Filter = %q", got)
+	}
+}
+
+// Warnings of the join checks on testdata with the default thresholds
+func TestJoinChecksTestdata(t *testing.T) {
+	got := testdataWarnings(t, "cartesian-join", "nested-loop-rescan", "hash-build-larger", "merge-join-sorts", "join-key-cast")
+	compareTestdataWarnings(t, got, map[string][]string{
+		// The build side below the Redistribute Motion has 5500000 rows per
+		// segment, the probe side 2750
+		"explain05.txt": {"#1 hash-build-larger"},
+		// Nested Loops for the subqueries without a correlated index lookup
+		"explain08.txt": {"#0 cartesian-join"},
+		"explain09.txt": {"#0 cartesian-join", "#0 nested-loop-rescan", "#1 cartesian-join"},
+		"explain10.txt": {"#1 cartesian-join"},
+		// str_cd::text = str_cd::text and other varchar keys cast to text
+		"explain11.txt": {"#7 join-key-cast"},
+		// The Materialize is rescanned 77284 times
+		"explain12.txt": {"#7 nested-loop-rescan", "#8 join-key-cast"},
+		"explain14.txt": {"#7 join-key-cast", "#13 join-key-cast", "#18 join-key-cast", "#25 join-key-cast", "#40 join-key-cast"},
+		// imp."time"::date = adwd_imp.date_dt
+		"explain15.txt": {"#5 join-key-cast", "#7 join-key-cast", "#9 join-key-cast", "#11 join-key-cast"},
+		"explain16.txt": {"#12 join-key-cast", "#38 join-key-cast"},
+	})
+}
+
+func TestJoinCheckCauses(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"hash-build-larger"}})
+	w := f.NodeWarnings[e.Nodes[1]]
+	want := "Hash table built on 5500000 rows (42.0 MB) and probed with 2750 rows (21.5 KB) per segment"
+	if len(w) != 1 || w[0].Cause != want || strings.Join(w[0].Remediation, " ") != "ANALYZE sales;" {
+		t.Errorf("hash-build-larger warnings = %+v, want %q", w, want)
+	}
+
+	// The build side must be hash_build_ratio times larger
+	p := NewParams()
+	p.Set("hash_build_ratio", 3000)
+	if f := e.CheckWithConfig(CheckConfig{Enabled: []string{"hash-build-larger"}, Params: p}); len(f.NodeWarnings) != 0 {
+		t.Errorf("hash-build-larger reported with a ratio of 3000")
+	}
+
+	e = loadTestExplain(t, "../testdata/explain12.txt")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"nested-loop-rescan"}})
+	want = "Inner side Broadcast Motion 40:40 (#15) of 1 rows, 4 B, is read 77284 times"
+	if w := f.NodeWarnings[e.Nodes[7]]; len(w) != 1 || w[0].Cause != want {
+		t.Errorf("nested-loop-rescan warnings = %+v, want %q", w, want)
+	}
+
+	e = loadTestExplain(t, "../testdata/explain15.txt")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"join-key-cast"}})
+	want = "Join keys compared with a cast: imp.\"time\"::date = adwd_imp.date_dt"
+	if w := f.NodeWarnings[e.Nodes[5]]; len(w) != 1 || w[0].Cause != want {
+		t.Errorf("join-key-cast warnings = %+v, want %q", w, want)
+	}
+
+	// The same cast on both sides is reported, keys without a cast are not
+	e = loadTestExplain(t, "../testdata/explain12.txt")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"join-key-cast"}})
+	want = "Join keys compared with a cast: dt.str_cd::text = hd.str_cd::text, "
+	if w := f.NodeWarnings[e.Nodes[8]]; len(w) != 1 || strings.HasPrefix(w[0].Cause, want) == false || strings.Contains(w[0].Cause, "recorded_time") {
+		t.Errorf("explain12 join-key-cast warnings = %+v", w)
+	}
+}
+
+// explain18 with the Hash Join changed to a Merge Join of two sorted inputs
+func TestMergeJoinSorts(t *testing.T) {
+	e := loadEditedExplain(t, "../testdata/explain18.txt",
+		"->  Hash Join  (cost", "->  Merge Join  (cost",
+		"Hash Cond: b.col2 = a.col1", "Merge Cond: b.col2 = a.col1",
+		"->  Redistribute Motion 2:2  (slice1; segments: 2)  (cost", "->  Sort  (cost",
+		"->  Hash  (cost", "->  Sort  (cost")
+
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"merge-join-sorts"}})
+	w := f.NodeWarnings[e.Nodes[1]]
+	if len(w) != 1 || len(f.NodeWarnings) != 1 || strings.HasPrefix(w[0].Cause, "Both inputs are sorted for the Merge Join") == false {
+		t.Errorf("merge-join-sorts warnings = %+v", f.NodeWarnings)
+	}
+
+	if keys := e.Nodes[1].JoinKeys(); len(keys) != 1 || keys[0].Left != (ColumnRef{"b", "col2"}) {
+		t.Errorf("Merge Cond join keys = %+v", keys)
+	}
+}
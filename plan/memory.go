@@ -0,0 +1,181 @@
+package plan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Memory and spill analysis
+//
+// EXPLAIN ANALYZE reports memory at three levels:
+//
+//	Node       Work_mem used:  127501K bytes avg, 127501K bytes max (seg0). Workfile: (2 spilling, 0 reused)
+//	           Work_mem wanted: 171875K bytes avg, 171875K bytes max (seg0) to lessen workfile I/O affecting 2 workers.
+//	           (seg0)     Wrote 54032K bytes to inner workfile.
+//	Slice      (slice2)  * Executor memory: 205132K bytes avg x 2 workers, 205136K bytes max (seg0).  Work_mem: 127501K bytes max, 171875K bytes wanted.
+//	Statement  Memory used: 128000K bytes
+//	           Memory wanted: 172775K bytes
+//
+// The spill-files check reports each operator that spilled with the bytes it
+// wrote and the work_mem it used and wanted. The checks in this file report:
+//   - statement-mem: the statement wanted more memory than statement_mem
+//     gave it, with the statement_mem needed to avoid spilling
+//   - memory-constrained-slice: slices marked with "*", where operators
+//     got less work_mem than they wanted
+//   - peak-memory: the segment using the most memory in any slice, when it
+//     uses more than peak_memory_mb
+
+// Parse the memory of each slice from the slice statistics
+func (e *Explain) SliceMemory() []SliceStat {
+	stats := []SliceStat{}
+	for _, line := range e.SliceStats {
+		m := patterns["SLICESTATS_1"].FindStringSubmatch(line)
+		if len(m) != 3 {
+			continue
+		}
+		stat := SliceStat{Name: m[1]}
+		if b, err := ParseByteSize(m[2] + "K"); err == nil {
+			stat.MemoryAvg = b
+			stat.MemoryMax = b
+		}
+		stat.Constrained = strings.HasPrefix(strings.TrimSpace(line[strings.Index(line, ")")+1:]), "*")
+
+		if m := patterns["SLICESTATS_2"].FindStringSubmatch(line); len(m) == 4 {
+			stat.Workers, _ = strconv.ParseInt(m[1], 10, 64)
+			if b, err := ParseByteSize(m[2] + "K"); err == nil {
+				stat.MemoryMax = b
+			}
+			stat.Segment = m[3]
+		}
+		if m := patterns["SLICESTATS_3"].FindStringSubmatch(line); len(m) == 2 {
+			stat.WorkMem, _ = ParseByteSize(m[1] + "K")
+		}
+		if m := patterns["SLICESTATS_4"].FindStringSubmatch(line); len(m) == 2 {
+			stat.WorkMemWanted, _ = ParseByteSize(m[1] + "K")
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// Describe what a spilling node wrote and the work_mem it used and wanted,
+// e.g. ", 52.8 MB written to workfiles, work_mem 124.5 MB used, 167.8 MB wanted"
+func spillDetails(n *Node) string {
+	details := ""
+	if n.WorkfileBytes.Valid {
+		details += fmt.Sprintf(", %s written to workfiles", n.WorkfileBytes)
+	}
+	if n.MaxMem.Valid {
+		details += fmt.Sprintf(", work_mem %s used", n.MaxMem)
+		if n.WorkMemWanted.Valid {
+			details += fmt.Sprintf(", %s wanted", n.WorkMemWanted)
+		}
+	}
+	return details
+}
+
+// Describe where the peak memory was used, e.g. "200.3 MB on seg0 in slice 2"
+func peakMemoryLocation(s Summary) string {
+	location := s.PeakMemory.String()
+	if s.PeakMemorySegment != "" {
+		location += " on " + s.PeakMemorySegment
+	}
+	if s.PeakMemorySlice.Valid {
+		location += fmt.Sprintf(" in slice %d", s.PeakMemorySlice.Value)
+	}
+	return location
+}
+
+var memoryChecks = []ExplainCheck{
+	ExplainCheck{
+		CheckInfo: CheckInfo{
+			Id:            "statement-mem",
+			Name:          "checkExplainStatementMem",
+			Description:   "Statement wanted more memory than it was given",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryMemory,
+			Severity:      SeverityWarning,
+			Documentation: "The statement statistics report more memory wanted than used, so operators spilled to workfiles or worked with less memory than they needed. Setting statement_mem to the memory wanted avoids the spilling, as long as the resource queue or group allows it.",
+		},
+		Exec: func(e *Explain, f *Findings) {
+			if e.MemoryUsed.Valid == false || e.MemoryWanted.Valid == false || e.MemoryWanted.Value <= e.MemoryUsed.Value {
+				return
+			}
+			s := Summary{}
+			e.summarizeMemory(&s)
+			peak := ""
+			if s.PeakMemory.Valid {
+				peak = fmt.Sprintf(", peak memory %s", peakMemoryLocation(s))
+			}
+			f.AddWarning(Warning{
+				Cause:       fmt.Sprintf("Statement used %s of memory and wanted %s%s", e.MemoryUsed, e.MemoryWanted, peak),
+				Resolution:  fmt.Sprintf("Set statement_mem to at least %s for this query", e.MemoryWanted),
+				Remediation: statementMemRemediation(e, nil)})
+		}},
+	ExplainCheck{
+		CheckInfo: CheckInfo{
+			Id:            "memory-constrained-slice",
+			Name:          "checkExplainMemoryConstrainedSlice",
+			Description:   "Slice marked as memory-constrained",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryMemory,
+			Severity:      SeverityInfo,
+			Documentation: "The slice statistics mark a slice with \"*\" when its operators got less work_mem than they wanted. The operators of the slice spilled or used a slower algorithm.",
+		},
+		Exec: func(e *Explain, f *Findings) {
+			for _, stat := range e.SliceMemory() {
+				if stat.Constrained == false {
+					continue
+				}
+				location := ""
+				if stat.Segment != "" {
+					location = " on " + stat.Segment
+				}
+				workMem := ""
+				if stat.WorkMem > 0 {
+					workMem = fmt.Sprintf(", work_mem %s used", stat.WorkMem)
+					if stat.WorkMemWanted > 0 {
+						workMem += fmt.Sprintf(", %s wanted", stat.WorkMemWanted)
+					}
+				}
+				f.AddWarning(Warning{
+					Cause:       fmt.Sprintf("Slice %s is memory-constrained, executor memory %s max%s%s", strings.TrimPrefix(stat.Name, "slice"), stat.MemoryMax, location, workMem),
+					Resolution:  "Increase statement_mem so the operators of the slice get the work_mem they want",
+					Remediation: statementMemRemediation(e, nil)})
+			}
+		}},
+	ExplainCheck{
+		CheckInfo: CheckInfo{
+			Id:            "peak-memory",
+			Name:          "checkExplainPeakMemory",
+			Description:   "Segment using a lot of memory",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryMemory,
+			Severity:      SeverityInfo,
+			Documentation: "A segment used more than peak_memory_mb in one slice. Concurrent queries share the memory of the segment host, so a single segment using much more than the others can run out of memory first.",
+			Parameters:    []string{"peak_memory_mb"},
+		},
+		Exec: func(e *Explain, f *Findings) {
+			s := Summary{}
+			e.summarizeMemory(&s)
+			if s.PeakMemory.Valid == false || s.PeakMemory.Value < ByteSize(f.Params.Float("peak_memory_mb")*float64(Megabyte)) {
+				return
+			}
+			f.AddWarning(Warning{
+				Cause:      fmt.Sprintf("Peak memory %s", peakMemoryLocation(s)),
+				Resolution: "Check the memory of the segment against the memory available to the query"})
+		}},
+}
+
+func init() {
+	for _, c := range memoryChecks {
+		RegisterExplainCheck(c)
+	}
+}
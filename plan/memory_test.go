@@ -0,0 +1,89 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSliceMemoryExplain05(t *testing.T) {
+	stats := loadTestExplain(t, "../testdata/explain05.txt").SliceMemory()
+	if len(stats) != 3 {
+		t.Fatalf("%d slices, want 3", len(stats))
+	}
+	// (slice2)  * Executor memory: 205132K bytes avg x 2 workers, 205136K bytes max (seg0).  Work_mem: 127501K bytes max, 171875K bytes wanted.
+	s := stats[2]
+	if s.Name != "slice2" || s.Workers != 2 || s.MemoryAvg != 205132*Kilobyte || s.MemoryMax != 205136*Kilobyte || s.Segment != "seg0" {
+		t.Errorf("slice2 = %+v", s)
+	}
+	if s.WorkMem != 127501*Kilobyte || s.WorkMemWanted != 171875*Kilobyte || s.Constrained == false {
+		t.Errorf("slice2 work_mem = %+v", s)
+	}
+	// The coordinator slice has no workers
+	if s := stats[0]; s.Segment != "" || s.Workers != 0 || s.Constrained {
+		t.Errorf("slice0 = %+v", s)
+	}
+}
+
+func TestWorkfileBytes(t *testing.T) {
+	tests := []struct {
+		filename string
+		id       int
+		want     OptionalBytes
+	}{
+		// Wrote 54032K bytes to inner workfile, 16K bytes to outer workfile
+		{"../testdata/explain05.txt", 1, validBytes(54048 * Kilobyte)},
+		{"../testdata/explain18.txt", 1, validBytes(9232 * Kilobyte)},
+		{"../testdata/explain05.txt", 6, OptionalBytes{}},
+	}
+
+	for _, test := range tests {
+		n := loadTestExplain(t, test.filename).Nodes[test.id]
+		if n.WorkfileBytes != test.want {
+			t.Errorf("%s: %s wrote %s, want %s", test.filename, n.Operator, n.WorkfileBytes, test.want)
+		}
+	}
+}
+
+// Warnings of the memory checks on testdata with the default thresholds
+func TestMemoryChecksTestdata(t *testing.T) {
+	got := testdataWarnings(t, "spill-files", "statement-mem", "memory-constrained-slice", "peak-memory")
+	compareTestdataWarnings(t, got, map[string][]string{
+		"explain05.txt": {"#1 spill-files", "statement-mem", "memory-constrained-slice"},
+		"explain18.txt": {"#1 spill-files", "statement-mem", "memory-constrained-slice"},
+	})
+}
+
+func TestMemoryCheckCauses(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"spill-files", "statement-mem", "memory-constrained-slice"}})
+
+	want := "Total 2 spilling segments found, 52.8 MB written to workfiles, work_mem 124.5 MB used, 167.8 MB wanted"
+	if w := f.NodeWarnings[e.Nodes[1]]; len(w) != 1 || w[0].Cause != want {
+		t.Errorf("spill-files warnings = %+v, want %q", w, want)
+	}
+
+	causes := []string{
+		"Statement used 125.0 MB of memory and wanted 168.7 MB, peak memory 200.3 MB on seg0 in slice 2",
+		"Slice 2 is memory-constrained, executor memory 200.3 MB max on seg0, work_mem 124.5 MB used, 167.8 MB wanted",
+	}
+	if len(f.Warnings) != len(causes) {
+		t.Fatalf("warnings = %+v", f.Warnings)
+	}
+	for i, w := range f.Warnings {
+		if w.Cause != causes[i] || strings.Join(w.Remediation, " ") != "SET statement_mem = '169MB';" {
+			t.Errorf("warning %d = %+v, want %q", i, w, causes[i])
+		}
+	}
+
+	// Peak memory is only reported above peak_memory_mb
+	p := NewParams()
+	p.Set("peak_memory_mb", 200)
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"peak-memory"}, Params: p})
+	if len(f.Warnings) != 1 || f.Warnings[0].Cause != "Peak memory 200.3 MB on seg0 in slice 2" {
+		t.Errorf("peak-memory warnings = %+v", f.Warnings)
+	}
+	p.Set("peak_memory_mb", 201)
+	if f := e.CheckWithConfig(CheckConfig{Enabled: []string{"peak-memory"}, Params: p}); len(f.Warnings) != 0 {
+		t.Errorf("peak-memory reported below the threshold")
+	}
+}
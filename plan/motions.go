@@ -0,0 +1,256 @@
+package plan
+
+import (
+	"fmt"
+)
+
+// Motion analysis
+//
+// Motions move rows between segments over the interconnect. The volume a
+// motion moves is estimated from the Rows and Width of the Motion node:
+//
+//	Redistribute Motion N:M  rows x width x N   Rows is per sending segment
+//	Broadcast Motion N:M     rows x width x M   Every receiver gets all rows
+//	Gather Motion N:1        rows x width       Rows is the total gathered
+//
+// For EXPLAIN ANALYZE output the actual volume uses the rows received in
+// total (Avg x workers, or the rows at the destination) instead.
+//
+// The checks in this file use the volume to report:
+//   - motion-volume: Redistribute and Broadcast motions moving a lot of data
+//   - broadcast-large: Broadcasts of large relations, rows x segments
+//   - redistribute-chain: data redistributed twice without being joined or
+//     aggregated in between
+//   - motion-rescanned: motions below a Materialize that is rescanned, by
+//     the rows x width of the Materialize times the number of scans
+//   - gather-large: Gather Motions pulling large results to the coordinator
+
+// Estimated bytes moved by a Motion over the interconnect
+func (n *Node) MotionBytes() OptionalBytes {
+	senders, receivers := n.MotionSegments()
+	if senders.Valid == false {
+		return OptionalBytes{}
+	}
+
+	rows := float64(n.Rows)
+	switch n.Type {
+	case NodeTypeBroadcastMotion:
+		rows = rows * float64(receivers.Value)
+	case NodeTypeGatherMotion:
+	default:
+		rows = rows * float64(senders.Value)
+	}
+	return validBytes(ByteSize(rows * float64(n.Width)))
+}
+
+// Actual bytes moved by a Motion, only valid for EXPLAIN ANALYZE output
+func (n *Node) MotionActualBytes() OptionalBytes {
+	if n.Category != CategoryMotion {
+		return OptionalBytes{}
+	}
+	rows := 0.0
+	if n.AvgRows.Valid && n.Workers.Valid {
+		rows = n.AvgRows.Value * float64(n.Workers.Value)
+	} else if n.ActualRows.Valid {
+		rows = n.ActualRows.Value
+	} else {
+		return OptionalBytes{}
+	}
+	return validBytes(ByteSize(rows * float64(n.Width)))
+}
+
+// Describe the volume of a Motion, e.g. "an estimated 1.2 GB (actual 3.4 GB)"
+func motionVolume(n *Node) string {
+	volume := fmt.Sprintf("an estimated %s", n.MotionBytes())
+	if actual := n.MotionActualBytes(); actual.Valid {
+		volume += fmt.Sprintf(" (actual %s)", actual)
+	}
+	return volume
+}
+
+// Largest of the estimated and actual volume
+func motionMaxBytes(n *Node) ByteSize {
+	bytes := n.MotionBytes().Value
+	if actual := n.MotionActualBytes(); actual.Valid && actual.Value > bytes {
+		bytes = actual.Value
+	}
+	return bytes
+}
+
+// Find the Redistribute Motion below n, looking through nodes that pass rows
+// on unchanged. Nil if a join, aggregate or scan comes first.
+func redistributeBelow(n *Node) *Node {
+	for c := n; len(c.SubNodes) == 1; {
+		c = c.SubNodes[0]
+		if c.IsType(NodeTypeRedistributeMotion, NodeTypeExplicitRedistributeMotion) {
+			return c
+		}
+		if c.IsType(NodeTypeResult, NodeTypeSubqueryScan, NodeTypeMaterialize, NodeTypeSort) == false {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Number of times a Materialize is read: the scans it reports, or the
+// estimated outer rows when it is the inner side of a Nested Loop
+func rescanCount(n *Node) int64 {
+	if n.Scans.Valid {
+		return n.Scans.Value
+	}
+	p := n.Parent
+	if p != nil && p.IsType(NodeTypeNestedLoop) && len(p.SubNodes) > 1 && p.SubNodes[0] != n {
+		return p.SubNodes[0].Rows
+	}
+	return 1
+}
+
+var motionChecks = []NodeCheck{
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "motion-volume",
+			Name:          "checkNodeMotionVolume",
+			Description:   "Redistribute or Broadcast Motion moving a large volume of data",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryInterconnect,
+			Severity:      SeverityWarning,
+			Documentation: "The motion sends more than motion_volume_mb over the interconnect, estimated as rows x width x sending segments. Distributing the tables on the join columns avoids moving the data.",
+			Parameters:    []string{"motion_volume_mb"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeRedistributeMotion, NodeTypeExplicitRedistributeMotion, NodeTypeBroadcastMotion) == false {
+				return
+			}
+			if motionMaxBytes(n) < ByteSize(f.Params.Float("motion_volume_mb")*float64(Megabyte)) {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("%s moves %s over the interconnect", n.Operator, motionVolume(n)),
+				Resolution: "Check the distribution keys of the tables match the join columns"})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "broadcast-large",
+			Name:          "checkNodeBroadcastLarge",
+			Description:   "Broadcast Motion of a large relation",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryInterconnect,
+			Severity:      SeverityWarning,
+			Documentation: "A broadcast sends every row to every segment, so it only pays off for small relations. A large broadcast usually means the row count was underestimated, or a Redistribute Motion would be cheaper.",
+			Parameters:    []string{"broadcast_rows"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeBroadcastMotion) == false {
+				return
+			}
+			_, receivers := n.MotionSegments()
+			if receivers.Valid == false {
+				return
+			}
+
+			// Rows of a Broadcast are what each segment receives
+			rows := float64(n.Rows)
+			if n.ActualRowsPerSeg.Valid && n.ActualRowsPerSeg.Value > rows {
+				rows = n.ActualRowsPerSeg.Value
+			}
+			total := rows * float64(receivers.Value)
+			if total < f.Params.Float("broadcast_rows") {
+				return
+			}
+
+			// Refresh the statistics of the broadcast table in case it is underestimated
+			f.AddNodeWarning(n, Warning{
+				Cause:       fmt.Sprintf("Broadcast of %.0f rows to %d segments, %.0f rows and %s over the interconnect", rows, receivers.Value, total, motionVolume(n)),
+				Resolution:  "Check the row estimate of the broadcast side, redistributing both sides may be cheaper",
+				Remediation: analyzeRemediation(n)})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "redistribute-chain",
+			Name:          "checkNodeRedistributeChain",
+			Description:   "Data redistributed twice in a row",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryInterconnect,
+			Severity:      SeverityWarning,
+			Documentation: "A Redistribute Motion directly receives the output of another Redistribute Motion, so the same rows cross the interconnect twice. This often comes from GROUP BY or DISTINCT on different columns than the join, or from casts on the keys.",
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeRedistributeMotion, NodeTypeExplicitRedistributeMotion) == false {
+				return
+			}
+			below := redistributeBelow(n)
+			if below == nil {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("Rows are redistributed again right after %s (#%d), moving %s a second time", below.Operator, below.Id, motionVolume(n)),
+				Resolution: "Check the grouping and join columns match, and that the keys are not cast"})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "motion-rescanned",
+			Name:          "checkNodeMotionRescanned",
+			Description:   "Motion below a Materialize that is rescanned",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryInterconnect,
+			Severity:      SeverityWarning,
+			Documentation: "A Motion can not be rescanned so its rows are kept in a Materialize, which is read again for every outer row of a Nested Loop. Large materialized motions spill to disk and are read many times. Reported when the Materialize is read for more than rescanned_motion_mb in total.",
+			Parameters:    []string{"rescanned_motion_mb"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeMaterialize) == false || len(n.SubNodes) != 1 || n.SubNodes[0].Category != CategoryMotion {
+				return
+			}
+			scans := rescanCount(n)
+			if scans < 2 {
+				return
+			}
+			read := ByteSize(float64(n.Rows) * float64(n.Width) * float64(scans))
+			if read < ByteSize(f.Params.Float("rescanned_motion_mb")*float64(Megabyte)) {
+				return
+			}
+
+			motion := n.SubNodes[0]
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("%s (#%d) moving %s is materialized and read %d times, %s per segment", motion.Operator, motion.Id, motionVolume(motion), scans, read),
+				Resolution: "Check why a Nested Loop was chosen, a Hash Join avoids the rescans"})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "gather-large",
+			Name:          "checkNodeGatherLarge",
+			Description:   "Gather Motion pulling a large result to the coordinator",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryInterconnect,
+			Severity:      SeverityWarning,
+			Documentation: "All rows of a Gather Motion go through the single coordinator process. Large results are better aggregated on the segments, or written in parallel with CREATE TABLE AS or a writable external table.",
+			Parameters:    []string{"gather_volume_mb"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			if n.IsType(NodeTypeGatherMotion) == false {
+				return
+			}
+			if motionMaxBytes(n) < ByteSize(f.Params.Float("gather_volume_mb")*float64(Megabyte)) {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("Gather Motion pulls %d rows, %s, to the coordinator", n.Rows, motionVolume(n)),
+				Resolution: "Aggregate or filter on the segments, or write the result in parallel"})
+		}},
+}
+
+func init() {
+	for _, c := range motionChecks {
+		RegisterNodeCheck(c)
+	}
+}
@@ -0,0 +1,129 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMotionBytes(t *testing.T) {
+	tests := []struct {
+		filename string
+		id       int
+		estimate OptionalBytes
+		actual   OptionalBytes
+	}{
+		// Redistribute: rows per sending segment x width x senders
+		{"../testdata/explain11.txt", 4, validBytes(3182885 * 70 * 40), validBytes(ByteSize(71467.9 * 40 * 70))},
+		// Broadcast: every receiver gets all rows
+		{"../testdata/explain17.txt", 5, validBytes(509103 * 41 * 32), OptionalBytes{}},
+		// Gather: the rows are the total gathered
+		{"../testdata/explain18.txt", 0, validBytes(1003438952 * 16), validBytes(1000000 * 16)},
+		// Avg x workers received
+		{"../testdata/explain05.txt", 6, validBytes(1 * 8 * 2), validBytes(5500000 * 2 * 8)},
+		{"../testdata/explain01.txt", 0, validBytes(8), OptionalBytes{}},
+		// Not a motion
+		{"../testdata/explain05.txt", 1, OptionalBytes{}, OptionalBytes{}},
+	}
+
+	for _, test := range tests {
+		n := loadTestExplain(t, test.filename).Nodes[test.id]
+		if n.MotionBytes() != test.estimate || n.MotionActualBytes() != test.actual {
+			t.Errorf("%s: %s moves %s (actual %s), want %s (actual %s)", test.filename, n.Operator,
+				n.MotionBytes(), n.MotionActualBytes(), test.estimate, test.actual)
+		}
+	}
+}
+
+func TestRescanCount(t *testing.T) {
+	tests := []struct {
+		filename string
+		id       int
+		want     int64
+	}{
+		{"../testdata/explain22.txt", 7, 96},     // Scans reported by EXPLAIN ANALYZE
+		{"../testdata/explain10.txt", 7, 281},    // Estimated outer rows of the Nested Loop
+		{"../testdata/explain09.txt", 5, 315844}, // Estimated outer rows of the Nested Loop
+		{"../testdata/explain16.txt", 33, 1},     // Not below a Nested Loop
+		{"../testdata/explain05.txt", 6, 1},      // Not a Materialize
+	}
+
+	for _, test := range tests {
+		n := loadTestExplain(t, test.filename).Nodes[test.id]
+		if got := rescanCount(n); got != test.want {
+			t.Errorf("%s: node #%d %s read %d times, want %d", test.filename, n.Id, n.Operator, got, test.want)
+		}
+	}
+}
+
+// Warnings of the motion checks on testdata with the default thresholds
+func TestMotionChecksTestdata(t *testing.T) {
+	got := testdataWarnings(t, "motion-volume", "broadcast-large", "redistribute-chain", "motion-rescanned", "gather-large")
+	compareTestdataWarnings(t, got, map[string][]string{
+		"explain09.txt": {"#5 motion-rescanned"},
+		"explain11.txt": {"#0 gather-large", "#4 motion-volume"},
+		"explain15.txt": {
+			"#0 motion-volume", "#2 motion-volume", "#4 motion-volume", "#6 motion-volume",
+			"#8 motion-volume", "#8 broadcast-large", "#10 motion-volume", "#10 broadcast-large",
+		},
+		"explain17.txt": {"#5 broadcast-large"},
+		"explain18.txt": {"#0 gather-large"},
+	})
+}
+
+func TestMotionCheckCauses(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain17.txt")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"broadcast-large"}})
+	w := f.NodeWarnings[e.Nodes[5]]
+	want := "Broadcast of 509103 rows to 32 segments, 16291296 rows and an estimated 637.0 MB over the interconnect"
+	if len(w) != 1 || w[0].Cause != want {
+		t.Fatalf("broadcast-large warnings = %+v, want %q", w, want)
+	}
+	// d_customer_account and account_sk_index are both below the Broadcast
+	if len(w[0].Remediation) != 0 {
+		t.Errorf("remediation for several tables = %v", w[0].Remediation)
+	}
+
+	e = loadTestExplain(t, "../testdata/explain12.txt")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"motion-count"}})
+	if len(f.Warnings) != 1 || strings.HasPrefix(f.Warnings[0].Cause, "Found 5 Redistribute/Broadcast motions moving an estimated ") == false {
+		t.Errorf("motion-count warnings = %+v", f.Warnings)
+	}
+
+	// The distribution keys suggested are those of distribution-key
+	e = loadTestExplain(t, "../testdata/explain16.txt")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"motion-count"}})
+	if len(f.Warnings) != 1 || strings.Join(f.Warnings[0].Remediation, " ") != "ALTER TABLE pa_expenditure_items_all SET DISTRIBUTED BY (project_id);" {
+		t.Errorf("explain16 motion-count warnings = %+v", f.Warnings)
+	}
+	// explain17 moves d_date and d_customer_account, less than distribution_min_mb
+	e = loadTestExplain(t, "../testdata/explain17.txt")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"motion-count"}})
+	if len(f.Warnings) != 1 || len(f.Warnings[0].Remediation) != 0 {
+		t.Errorf("explain17 motion-count warnings = %+v", f.Warnings)
+	}
+
+	// Lower thresholds report smaller motions
+	p := NewParams()
+	p.Set("motion_volume_mb", 300)
+	e = loadTestExplain(t, "../testdata/explain12.txt")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"motion-volume"}, Params: p})
+	if len(f.NodeWarnings) != 3 {
+		t.Errorf("motion-volume above 300 MB reported on %d nodes, want 3", len(f.NodeWarnings))
+	}
+}
+
+// A Redistribute Motion directly below another is reported on the upper one
+func TestRedistributeChain(t *testing.T) {
+	e := loadEditedExplain(t, "../testdata/explain18.txt",
+		"->  Seq Scan on bigtable b  (cost=0.00..11119.18 rows=500859 width=8)",
+		"->  Redistribute Motion 2:2  (slice0; segments: 2)  (cost=0.00..11119.18 rows=500859 width=8)")
+
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"redistribute-chain"}})
+	upper, lower := e.Nodes[2], e.Nodes[3]
+	if redistributeBelow(upper) != lower || len(f.NodeWarnings) != 1 || len(f.NodeWarnings[upper]) != 1 {
+		t.Fatalf("redistribute-chain warnings = %+v", f.NodeWarnings)
+	}
+	if w := f.NodeWarnings[upper][0]; strings.Contains(w.Cause, "after Redistribute Motion 2:2 (#3)") == false {
+		t.Errorf("cause = %q", w.Cause)
+	}
+}
@@ -0,0 +1,395 @@
+package plan
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Type of node, derived from the free text Operator
+type NodeType int
+
+const (
+	NodeTypeUnknown NodeType = iota
+
+	// Scans
+	NodeTypeSeqScan
+	NodeTypeTableScan
+	NodeTypeAppendOnlyScan
+	NodeTypeAppendOnlyColumnarScan
+	NodeTypeParquetScan
+	NodeTypeDynamicTableScan
+	NodeTypeIndexScan
+	NodeTypeIndexOnlyScan
+	NodeTypeDynamicIndexScan
+	NodeTypeBitmapIndexScan
+	NodeTypeBitmapHeapScan
+	NodeTypeExternalScan
+	NodeTypeFunctionScan
+	NodeTypeSubqueryScan
+	NodeTypeValuesScan
+	NodeTypeSharedScan
+	NodeTypeCteScan
+	NodeTypeWorkTableScan
+
+	// Joins
+	NodeTypeHashJoin
+	NodeTypeNestedLoop
+	NodeTypeMergeJoin
+
+	// Motions
+	NodeTypeGatherMotion
+	NodeTypeRedistributeMotion
+	NodeTypeExplicitRedistributeMotion
+	NodeTypeBroadcastMotion
+
+	// Aggregates
+	NodeTypeAggregate
+	NodeTypeHashAggregate
+	NodeTypeGroupAggregate
+	NodeTypeWindow
+
+	NodeTypeSort
+	NodeTypeMaterialize
+	NodeTypeResult
+
+	// DML
+	NodeTypeInsert
+	NodeTypeUpdate
+	NodeTypeDelete
+	NodeTypeSplit
+
+	// Everything else
+	NodeTypeHash
+	NodeTypeAppend
+	NodeTypeSequence
+	NodeTypePartitionSelector
+	NodeTypeLimit
+	NodeTypeUnique
+	NodeTypeSetOp
+	NodeTypeAssert
+)
+
+// Broad category of a node
+type NodeCategory int
+
+const (
+	CategoryOther NodeCategory = iota
+	CategoryScan
+	CategoryJoin
+	CategoryMotion
+	CategoryAggregate
+	CategorySort
+	CategoryMaterialize
+	CategoryResult
+	CategoryDML
+)
+
+// Type of join, only set for join nodes
+type JoinType int
+
+const (
+	JoinTypeNone JoinType = iota
+	JoinTypeInner
+	JoinTypeLeft
+	JoinTypeRight
+	JoinTypeFull
+	JoinTypeSemi
+	JoinTypeAnti
+)
+
+// How a scan node reads its data, only set for scan nodes
+type ScanMethod int
+
+const (
+	ScanMethodNone ScanMethod = iota
+	ScanMethodSequential
+	ScanMethodIndex
+	ScanMethodIndexOnly
+	ScanMethodBitmapIndex
+	ScanMethodBitmapHeap
+	ScanMethodExternal
+	ScanMethodFunction
+	ScanMethodSubquery
+	ScanMethodValues
+	ScanMethodShared
+)
+
+type nodeTypeInfo struct {
+	Name       string
+	Category   NodeCategory
+	ScanMethod ScanMethod
+}
+
+var (
+	nodeTypes = map[NodeType]nodeTypeInfo{
+		NodeTypeUnknown: {"Unknown", CategoryOther, ScanMethodNone},
+
+		NodeTypeSeqScan:                {"Seq Scan", CategoryScan, ScanMethodSequential},
+		NodeTypeTableScan:              {"Table Scan", CategoryScan, ScanMethodSequential},
+		NodeTypeAppendOnlyScan:         {"Append-only Scan", CategoryScan, ScanMethodSequential},
+		NodeTypeAppendOnlyColumnarScan: {"Append-only Columnar Scan", CategoryScan, ScanMethodSequential},
+		NodeTypeParquetScan:            {"Parquet table Scan", CategoryScan, ScanMethodSequential},
+		NodeTypeDynamicTableScan:       {"Dynamic Table Scan", CategoryScan, ScanMethodSequential},
+		NodeTypeIndexScan:              {"Index Scan", CategoryScan, ScanMethodIndex},
+		NodeTypeIndexOnlyScan:          {"Index Only Scan", CategoryScan, ScanMethodIndexOnly},
+		NodeTypeDynamicIndexScan:       {"Dynamic Index Scan", CategoryScan, ScanMethodIndex},
+		NodeTypeBitmapIndexScan:        {"Bitmap Index Scan", CategoryScan, ScanMethodBitmapIndex},
+		NodeTypeBitmapHeapScan:         {"Bitmap Heap Scan", CategoryScan, ScanMethodBitmapHeap},
+		NodeTypeExternalScan:           {"External Scan", CategoryScan, ScanMethodExternal},
+		NodeTypeFunctionScan:           {"Function Scan", CategoryScan, ScanMethodFunction},
+		NodeTypeSubqueryScan:           {"Subquery Scan", CategoryScan, ScanMethodSubquery},
+		NodeTypeValuesScan:             {"Values Scan", CategoryScan, ScanMethodValues},
+		NodeTypeSharedScan:             {"Shared Scan", CategoryScan, ScanMethodShared},
+		NodeTypeCteScan:                {"CTE Scan", CategoryScan, ScanMethodShared},
+		NodeTypeWorkTableScan:          {"WorkTable Scan", CategoryScan, ScanMethodShared},
+
+		NodeTypeHashJoin:   {"Hash Join", CategoryJoin, ScanMethodNone},
+		NodeTypeNestedLoop: {"Nested Loop", CategoryJoin, ScanMethodNone},
+		NodeTypeMergeJoin:  {"Merge Join", CategoryJoin, ScanMethodNone},
+
+		NodeTypeGatherMotion:               {"Gather Motion", CategoryMotion, ScanMethodNone},
+		NodeTypeRedistributeMotion:         {"Redistribute Motion", CategoryMotion, ScanMethodNone},
+		NodeTypeExplicitRedistributeMotion: {"Explicit Redistribute Motion", CategoryMotion, ScanMethodNone},
+		NodeTypeBroadcastMotion:            {"Broadcast Motion", CategoryMotion, ScanMethodNone},
+
+		NodeTypeAggregate:      {"Aggregate", CategoryAggregate, ScanMethodNone},
+		NodeTypeHashAggregate:  {"HashAggregate", CategoryAggregate, ScanMethodNone},
+		NodeTypeGroupAggregate: {"GroupAggregate", CategoryAggregate, ScanMethodNone},
+		NodeTypeWindow:         {"Window", CategoryAggregate, ScanMethodNone},
+
+		NodeTypeSort:        {"Sort", CategorySort, ScanMethodNone},
+		NodeTypeMaterialize: {"Materialize", CategoryMaterialize, ScanMethodNone},
+		NodeTypeResult:      {"Result", CategoryResult, ScanMethodNone},
+
+		NodeTypeInsert: {"Insert", CategoryDML, ScanMethodNone},
+		NodeTypeUpdate: {"Update", CategoryDML, ScanMethodNone},
+		NodeTypeDelete: {"Delete", CategoryDML, ScanMethodNone},
+		NodeTypeSplit:  {"Split", CategoryDML, ScanMethodNone},
+
+		NodeTypeHash:              {"Hash", CategoryOther, ScanMethodNone},
+		NodeTypeAppend:            {"Append", CategoryOther, ScanMethodNone},
+		NodeTypeSequence:          {"Sequence", CategoryOther, ScanMethodNone},
+		NodeTypePartitionSelector: {"Partition Selector", CategoryOther, ScanMethodNone},
+		NodeTypeLimit:             {"Limit", CategoryOther, ScanMethodNone},
+		NodeTypeUnique:            {"Unique", CategoryOther, ScanMethodNone},
+		NodeTypeSetOp:             {"SetOp", CategoryOther, ScanMethodNone},
+		NodeTypeAssert:            {"Assert", CategoryOther, ScanMethodNone},
+	}
+
+	// Matched in order against the start of the Operator so more specific
+	// patterns must come first. Case insensitive and tolerant of extra
+	// whitespace so minor wording changes between versions still match.
+	nodeTypePatterns = []struct {
+		re       *regexp.Regexp
+		nodeType NodeType
+	}{
+		{regexp.MustCompile(`(?i)^Dynamic\s+(Table|Seq)\s+Scan`), NodeTypeDynamicTableScan},
+		{regexp.MustCompile(`(?i)^Dynamic\s+Index\s+Scan`), NodeTypeDynamicIndexScan},
+		{regexp.MustCompile(`(?i)^Bitmap\s+Index\s+Scan`), NodeTypeBitmapIndexScan},
+		{regexp.MustCompile(`(?i)^(Dynamic\s+)?Bitmap\s+.*Scan`), NodeTypeBitmapHeapScan},
+		{regexp.MustCompile(`(?i)^Index\s+Only\s+Scan`), NodeTypeIndexOnlyScan},
+		{regexp.MustCompile(`(?i)^Index\s+Scan`), NodeTypeIndexScan},
+		{regexp.MustCompile(`(?i)^Seq\s+Scan`), NodeTypeSeqScan},
+		{regexp.MustCompile(`(?i)^Table\s+Scan`), NodeTypeTableScan},
+		{regexp.MustCompile(`(?i)^Append-only\s+Columnar\s+Scan`), NodeTypeAppendOnlyColumnarScan},
+		{regexp.MustCompile(`(?i)^Append-only\s+Scan`), NodeTypeAppendOnlyScan},
+		{regexp.MustCompile(`(?i)^Parquet\s+table\s+Scan`), NodeTypeParquetScan},
+		{regexp.MustCompile(`(?i)^External\s+Scan`), NodeTypeExternalScan},
+		{regexp.MustCompile(`(?i)^Function\s+Scan`), NodeTypeFunctionScan},
+		{regexp.MustCompile(`(?i)^Subquery\s+Scan`), NodeTypeSubqueryScan},
+		{regexp.MustCompile(`(?i)^Values\s+Scan`), NodeTypeValuesScan},
+		{regexp.MustCompile(`(?i)^Shared\s+Scan`), NodeTypeSharedScan},
+		{regexp.MustCompile(`(?i)^CTE\s+Scan`), NodeTypeCteScan},
+		{regexp.MustCompile(`(?i)^WorkTable\s+Scan`), NodeTypeWorkTableScan},
+
+		{regexp.MustCompile(`(?i)^Hash\b.*\bJoin`), NodeTypeHashJoin},
+		{regexp.MustCompile(`(?i)^Nested\s+Loop`), NodeTypeNestedLoop},
+		{regexp.MustCompile(`(?i)^Merge\b.*\bJoin`), NodeTypeMergeJoin},
+
+		{regexp.MustCompile(`(?i)^Gather\s+Motion`), NodeTypeGatherMotion},
+		{regexp.MustCompile(`(?i)^Explicit\s+Redistribute\s+Motion`), NodeTypeExplicitRedistributeMotion},
+		{regexp.MustCompile(`(?i)^Redistribute\s+Motion`), NodeTypeRedistributeMotion},
+		{regexp.MustCompile(`(?i)^Broadcast\s+Motion`), NodeTypeBroadcastMotion},
+
+		{regexp.MustCompile(`(?i)^HashAggregate`), NodeTypeHashAggregate},
+		{regexp.MustCompile(`(?i)^GroupAggregate`), NodeTypeGroupAggregate},
+		{regexp.MustCompile(`(?i)^Aggregate`), NodeTypeAggregate},
+		{regexp.MustCompile(`(?i)^Window`), NodeTypeWindow},
+
+		{regexp.MustCompile(`(?i)^Sort\b`), NodeTypeSort},
+		{regexp.MustCompile(`(?i)^Materialize`), NodeTypeMaterialize},
+		{regexp.MustCompile(`(?i)^Result`), NodeTypeResult},
+
+		{regexp.MustCompile(`(?i)^Insert\b`), NodeTypeInsert},
+		{regexp.MustCompile(`(?i)^Update\b`), NodeTypeUpdate},
+		{regexp.MustCompile(`(?i)^Delete\b`), NodeTypeDelete},
+		{regexp.MustCompile(`(?i)^Split\b`), NodeTypeSplit},
+
+		{regexp.MustCompile(`(?i)^Hash$`), NodeTypeHash},
+		{regexp.MustCompile(`(?i)^Append$`), NodeTypeAppend},
+		{regexp.MustCompile(`(?i)^Sequence`), NodeTypeSequence},
+		{regexp.MustCompile(`(?i)^Partition\s+Selector`), NodeTypePartitionSelector},
+		{regexp.MustCompile(`(?i)^Limit`), NodeTypeLimit},
+		{regexp.MustCompile(`(?i)^Unique`), NodeTypeUnique},
+		{regexp.MustCompile(`(?i)^(Hash)?SetOp`), NodeTypeSetOp},
+		{regexp.MustCompile(`(?i)^Assert`), NodeTypeAssert},
+	}
+
+	// Used for operators not recognised above
+	nodeCategoryFallbacks = []struct {
+		re       *regexp.Regexp
+		category NodeCategory
+	}{
+		{regexp.MustCompile(`(?i)\bMotion\b`), CategoryMotion},
+		{regexp.MustCompile(`(?i)\b(Join|Loop)\b`), CategoryJoin},
+		{regexp.MustCompile(`(?i)\bScan\b`), CategoryScan},
+		{regexp.MustCompile(`(?i)Agg`), CategoryAggregate},
+	}
+
+	joinTypePatterns = []struct {
+		re       *regexp.Regexp
+		joinType JoinType
+	}{
+		{regexp.MustCompile(`(?i)\bAnti\b`), JoinTypeAnti},
+		{regexp.MustCompile(`(?i)\b(Semi|EXISTS)\b`), JoinTypeSemi},
+		{regexp.MustCompile(`(?i)\bLeft\b`), JoinTypeLeft},
+		{regexp.MustCompile(`(?i)\bRight\b`), JoinTypeRight},
+		{regexp.MustCompile(`(?i)\bFull\b`), JoinTypeFull},
+	}
+
+	categoryNames = map[NodeCategory]string{
+		CategoryOther:       "other",
+		CategoryScan:        "scan",
+		CategoryJoin:        "join",
+		CategoryMotion:      "motion",
+		CategoryAggregate:   "aggregate",
+		CategorySort:        "sort",
+		CategoryMaterialize: "materialize",
+		CategoryResult:      "result",
+		CategoryDML:         "dml",
+	}
+
+	joinTypeNames = map[JoinType]string{
+		JoinTypeNone:  "",
+		JoinTypeInner: "inner",
+		JoinTypeLeft:  "left",
+		JoinTypeRight: "right",
+		JoinTypeFull:  "full",
+		JoinTypeSemi:  "semi",
+		JoinTypeAnti:  "anti",
+	}
+
+	scanMethodNames = map[ScanMethod]string{
+		ScanMethodNone:        "",
+		ScanMethodSequential:  "sequential",
+		ScanMethodIndex:       "index",
+		ScanMethodIndexOnly:   "index only",
+		ScanMethodBitmapIndex: "bitmap index",
+		ScanMethodBitmapHeap:  "bitmap heap",
+		ScanMethodExternal:    "external",
+		ScanMethodFunction:    "function",
+		ScanMethodSubquery:    "subquery",
+		ScanMethodValues:      "values",
+		ScanMethodShared:      "shared",
+	}
+)
+
+func (t NodeType) String() string {
+	return nodeTypes[t].Name
+}
+
+func (c NodeCategory) String() string {
+	return categoryNames[c]
+}
+
+func (j JoinType) String() string {
+	return joinTypeNames[j]
+}
+
+func (s ScanMethod) String() string {
+	return scanMethodNames[s]
+}
+
+// Types are converted to their names in JSON
+func (t NodeType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (c NodeCategory) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (j JoinType) MarshalText() ([]byte, error) {
+	return []byte(j.String()), nil
+}
+
+func (s ScanMethod) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Check if the node is any of the given types
+func (n *Node) IsType(types ...NodeType) bool {
+	for _, t := range types {
+		if n.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Populate Type, Category, JoinType and ScanMethod from the Operator
+func classifyNode(n *Node) {
+	n.Type = NodeTypeUnknown
+	n.Category = CategoryOther
+	n.JoinType = JoinTypeNone
+	n.ScanMethod = ScanMethodNone
+
+	for _, p := range nodeTypePatterns {
+		if p.re.MatchString(n.Operator) {
+			n.Type = p.nodeType
+			break
+		}
+	}
+
+	if n.Type != NodeTypeUnknown {
+		n.Category = nodeTypes[n.Type].Category
+		n.ScanMethod = nodeTypes[n.Type].ScanMethod
+	} else {
+		for _, f := range nodeCategoryFallbacks {
+			if f.re.MatchString(n.Operator) {
+				n.Category = f.category
+				break
+			}
+		}
+	}
+
+	if n.Category == CategoryJoin {
+		n.JoinType = JoinTypeInner
+		for _, p := range joinTypePatterns {
+			if p.re.MatchString(n.Operator) {
+				n.JoinType = p.joinType
+				break
+			}
+		}
+	}
+}
+
+// Look up a NodeType by name, e.g. "Hash Join" (case insensitive)
+func ParseNodeType(name string) (NodeType, bool) {
+	for t, info := range nodeTypes {
+		if t != NodeTypeUnknown && strings.EqualFold(info.Name, name) {
+			return t, true
+		}
+	}
+	return NodeTypeUnknown, false
+}
+
+// Look up a NodeCategory by name, e.g. "motion" (case insensitive)
+func ParseCategory(name string) (NodeCategory, bool) {
+	for c, n := range categoryNames {
+		if strings.EqualFold(n, name) {
+			return c, true
+		}
+	}
+	return CategoryOther, false
+}
@@ -0,0 +1,127 @@
+package plan
+
+import (
+	"testing"
+)
+
+func TestClassifyNode(t *testing.T) {
+	tests := []struct {
+		operator   string
+		nodeType   NodeType
+		category   NodeCategory
+		joinType   JoinType
+		scanMethod ScanMethod
+	}{
+		{"Seq Scan on sales", NodeTypeSeqScan, CategoryScan, JoinTypeNone, ScanMethodSequential},
+		{"Dynamic Table Scan on sales (dynamic scan id: 1)", NodeTypeDynamicTableScan, CategoryScan, JoinTypeNone, ScanMethodSequential},
+		{"Dynamic Seq Scan on sales", NodeTypeDynamicTableScan, CategoryScan, JoinTypeNone, ScanMethodSequential},
+		{"Index Scan using sales_pkey on sales", NodeTypeIndexScan, CategoryScan, JoinTypeNone, ScanMethodIndex},
+		{"Index Only Scan using sales_pkey on sales", NodeTypeIndexOnlyScan, CategoryScan, JoinTypeNone, ScanMethodIndexOnly},
+		{"Bitmap Index Scan on account_sk_index", NodeTypeBitmapIndexScan, CategoryScan, JoinTypeNone, ScanMethodBitmapIndex},
+		{"Bitmap Heap Scan on account", NodeTypeBitmapHeapScan, CategoryScan, JoinTypeNone, ScanMethodBitmapHeap},
+		{"Bitmap Append-Only Row-Oriented Scan on account", NodeTypeBitmapHeapScan, CategoryScan, JoinTypeNone, ScanMethodBitmapHeap},
+		{"Append-only Columnar Scan on facts", NodeTypeAppendOnlyColumnarScan, CategoryScan, JoinTypeNone, ScanMethodSequential},
+		{"External Scan on ext_sales", NodeTypeExternalScan, CategoryScan, JoinTypeNone, ScanMethodExternal},
+		{"Hash Join", NodeTypeHashJoin, CategoryJoin, JoinTypeInner, ScanMethodNone},
+		{"Hash Left Join", NodeTypeHashJoin, CategoryJoin, JoinTypeLeft, ScanMethodNone},
+		{"Hash EXISTS Join", NodeTypeHashJoin, CategoryJoin, JoinTypeSemi, ScanMethodNone},
+		{"Hash Left Anti Semi Join", NodeTypeHashJoin, CategoryJoin, JoinTypeAnti, ScanMethodNone},
+		{"Nested Loop Left Join", NodeTypeNestedLoop, CategoryJoin, JoinTypeLeft, ScanMethodNone},
+		{"Merge Full Join", NodeTypeMergeJoin, CategoryJoin, JoinTypeFull, ScanMethodNone},
+		{"Gather Motion 2:1  ", NodeTypeGatherMotion, CategoryMotion, JoinTypeNone, ScanMethodNone},
+		{"Explicit Redistribute Motion 2:2", NodeTypeExplicitRedistributeMotion, CategoryMotion, JoinTypeNone, ScanMethodNone},
+		{"Broadcast  Motion 1:2", NodeTypeBroadcastMotion, CategoryMotion, JoinTypeNone, ScanMethodNone},
+		{"HashAggregate", NodeTypeHashAggregate, CategoryAggregate, JoinTypeNone, ScanMethodNone},
+		{"Sort", NodeTypeSort, CategorySort, JoinTypeNone, ScanMethodNone},
+		{"Sorted Something", NodeTypeUnknown, CategoryOther, JoinTypeNone, ScanMethodNone},
+		{"Hash", NodeTypeHash, CategoryOther, JoinTypeNone, ScanMethodNone},
+		{"HashSetOp Intersect", NodeTypeSetOp, CategoryOther, JoinTypeNone, ScanMethodNone},
+		{"Insert", NodeTypeInsert, CategoryDML, JoinTypeNone, ScanMethodNone},
+
+		// Unknown operators still get a category where the text is clear
+		{"Redistribute-ish Motion", NodeTypeUnknown, CategoryMotion, JoinTypeNone, ScanMethodNone},
+		{"Future Join", NodeTypeUnknown, CategoryJoin, JoinTypeInner, ScanMethodNone},
+		{"Sample Scan on sales", NodeTypeUnknown, CategoryScan, JoinTypeNone, ScanMethodNone},
+	}
+
+	for _, test := range tests {
+		n := &Node{Operator: test.operator}
+		classifyNode(n)
+		if n.Type != test.nodeType || n.Category != test.category || n.JoinType != test.joinType || n.ScanMethod != test.scanMethod {
+			t.Errorf("classifyNode(%q) = %s, %s, %q, %q, want %s, %s, %q, %q", test.operator,
+				n.Type, n.Category, n.JoinType, n.ScanMethod,
+				test.nodeType, test.category, test.joinType, test.scanMethod)
+		}
+	}
+}
+
+// Every operator in testdata is a known type
+func TestClassifyTestdata(t *testing.T) {
+	counts := map[NodeCategory]int{}
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+		for _, n := range e.Nodes {
+			if n.Type == NodeTypeUnknown {
+				t.Errorf("%s: node #%d %q not classified", filename, n.Id, n.Operator)
+			}
+			counts[n.Category]++
+		}
+	}
+	for _, c := range []NodeCategory{CategoryScan, CategoryJoin, CategoryMotion, CategoryAggregate, CategorySort} {
+		if counts[c] == 0 {
+			t.Errorf("no %s nodes found in testdata", c)
+		}
+	}
+
+	// explain17 scans an append-only table through a bitmap index
+	e := loadTestExplain(t, "../testdata/explain17.txt")
+	types := map[NodeType]bool{}
+	for _, n := range e.Nodes {
+		types[n.Type] = true
+	}
+	if types[NodeTypeBitmapHeapScan] == false || types[NodeTypeBitmapIndexScan] == false {
+		t.Errorf("explain17 bitmap scans not classified: %v", types)
+	}
+}
+
+// Bitmap scans on append-only tables are checked for an estimate of 1 row
+// as both bitmap scan types are classified
+func TestEstimatedRowsBitmapScans(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain17.txt")
+	f := runNodeCheck(t, e, "checkNodeEstimatedRows")
+
+	bitmaps := 0
+	for _, n := range e.Nodes {
+		if n.IsType(NodeTypeBitmapHeapScan, NodeTypeBitmapIndexScan) == false {
+			continue
+		}
+		bitmaps++
+		if len(f.NodeWarnings[n]) != 1 {
+			t.Errorf("node #%d %s estimating %d rows has %d warnings, want 1", n.Id, n.Operator, n.Rows, len(f.NodeWarnings[n]))
+		}
+	}
+	if bitmaps != 4 {
+		t.Errorf("found %d bitmap scans in explain17, want 4", bitmaps)
+	}
+}
+
+// Only scans of a child partition are reported, not scans with an alias
+// naming one. explain09 fell back to the legacy planner, which scans the
+// children of the root partition.
+func TestOrcaChildPartitionScan(t *testing.T) {
+	compareTestdataWarnings(t, testdataWarnings(t, "orca-child-partition-scan"), map[string][]string{
+		"explain11.txt": {"#9 orca-child-partition-scan", "#13 orca-child-partition-scan"},
+	})
+
+	e := loadEditedExplain(t, "../testdata/explain11.txt",
+		"->  Table Scan on mst_cal  (cost", "->  Table Scan on mst_cal cal_1_prt_2  (cost")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"orca-child-partition-scan"}})
+	if len(f.NodeWarnings) != 2 {
+		t.Errorf("orca-child-partition-scan reported on %d nodes, want 2", len(f.NodeWarnings))
+	}
+	for n := range f.NodeWarnings {
+		if n.Object == "mst_cal" {
+			t.Errorf("node #%d %s reported", n.Id, n.Operator)
+		}
+	}
+}
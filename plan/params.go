@@ -0,0 +1,256 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Check parameters
+//
+// Thresholds used by the checks are named parameters listed in PARAMETERS
+// with their defaults. The defaults of the enable_ GUCs are kept separately
+// in ENABLEGUCDEFAULTS. Both can be changed in a profile file:
+//
+//	# YAML
+//	parameters:
+//	  partition_scan_count: 500
+//	  slice_count: 300
+//	enable_gucs:
+//	  enable_nestloop: on
+//
+//	// JSON
+//	{"parameters": {"partition_scan_count": 500}, "enable_gucs": {"enable_nestloop": "on"}}
+//
+// Both formats are read as described in files.go and validated the same
+// way: parameters must be known numbers and the names in enable_gucs must
+// start with "enable_".
+//
+// Single values can be overridden with "name=value", where a name starting
+// with "enable_" sets the default of that GUC.
+
+// A named threshold
+type Parameter struct {
+	Name        string
+	Description string
+	Default     float64
+}
+
+var (
+	PARAMETERS = []Parameter{
+		Parameter{"partition_scan_count", "Number of partitions selected or scanned to report", 100},
+		Parameter{"partition_scan_percent", "Percentage of all partitions selected or scanned to report", 25},
+		Parameter{"data_skew_min_rows", "Rows a node must produce before checking for data skew", 10000},
+		Parameter{"motion_count", "Number of Redistribute/Broadcast motions to report", 5},
+		Parameter{"slice_count", "Number of slices above which to report", 100},
+		Parameter{"misestimate_factor", "Factor between estimated and actual rows to report", 100},
+		Parameter{"misestimate_min_rows", "Estimated or actual rows per segment a misestimated node must have to report", 1000},
+		Parameter{"motion_volume_mb", "MB moved by a Redistribute or Broadcast Motion to report", 1024},
+		Parameter{"broadcast_rows", "Rows sent to all segments by a Broadcast Motion to report", 10000000},
+		Parameter{"rescanned_motion_mb", "MB read from a materialized Motion over all rescans to report", 1024},
+		Parameter{"gather_volume_mb", "MB pulled to the coordinator by a Gather Motion to report", 1024},
+		Parameter{"nested_loop_rescans", "Times the inner side of a Nested Loop is read to report", 10000},
+		Parameter{"hash_build_ratio", "Factor the build side of a Hash Join is larger than the probe side to report", 2},
+		Parameter{"join_min_rows", "Rows per segment the build side of a Hash Join must have to report", 10000},
+		Parameter{"distribution_min_mb", "MB moved for joins before suggesting a distribution key", 100},
+		Parameter{"peak_memory_mb", "MB of memory used by a segment in a slice to report", 1024},
+		Parameter{"sort_agg_min_rows", "Rows per segment an aggregate or sort must process to report", 10000},
+		Parameter{"agg_groups_factor", "Factor the actual groups of an aggregate exceed the estimate to report", 10},
+		Parameter{"agg_reduction_factor", "Factor an aggregate must reduce redistributed rows by to suggest two phases", 10},
+		Parameter{"sort_width", "Row width in bytes of a sort to report", 1000},
+		Parameter{"coordinator_rows", "Rows a sort, aggregate or join on the coordinator must process to report", 1000000},
+		Parameter{"coordinator_time_percent", "Percentage of the runtime spent on the coordinator to report", 25},
+		Parameter{"any_array_size", "Values in an IN list or = ANY array to report", 100},
+		Parameter{"skew_factor", "Skew coefficient, max / avg across segments, to report", 2},
+		Parameter{"skew_min_ms", "Estimated ms segments wait for a skewed segment to report", 1000},
+		Parameter{"skew_min_memory_mb", "MB of memory the skewed segment must use to report", 100},
+		Parameter{"straggler_min_nodes", "Skewed nodes the same segment must cause to report it as a straggler", 3},
+		Parameter{"partition_default_percent", "Percentage of the rows scanned in a default partition to report", 10},
+		Parameter{"partition_estimate_factor", "Factor partitions scanned differ from partitions selected to report", 2},
+		Parameter{"stale_statistics_factor", "Factor between estimated and actual rows of a scan to report the statistics of its table as stale", 10},
+	}
+
+	// Default values of the enable_ GUCs.
+	// http://gpdb.docs.pivotal.io/4340/guc_config-topic3.html
+	ENABLEGUCDEFAULTS = map[string]string{
+		"enable_bitmapscan": "on",
+		"enable_groupagg":   "on",
+		"enable_hashagg":    "on",
+		"enable_hashjoin":   "on",
+		"enable_indexscan":  "on",
+		"enable_seqscan":    "on",
+		"enable_sort":       "on",
+		"enable_tidscan":    "on",
+		"enable_nestloop":   "off",
+		"enable_mergejoin":  "off",
+	}
+)
+
+// Parameter values used for a run of the checks
+type Params struct {
+	Values     map[string]float64
+	EnableGucs map[string]string
+}
+
+// Layout of a profile file
+type profile struct {
+	Parameters map[string]float64 `json:"parameters" yaml:"parameters"`
+	EnableGucs map[string]string  `json:"enable_gucs" yaml:"enable_gucs"`
+}
+
+// Create params with the default values
+func NewParams() *Params {
+	p := &Params{
+		Values:     map[string]float64{},
+		EnableGucs: map[string]string{},
+	}
+	for _, param := range PARAMETERS {
+		p.Values[param.Name] = param.Default
+	}
+	for name, value := range ENABLEGUCDEFAULTS {
+		p.EnableGucs[name] = value
+	}
+	return p
+}
+
+// Create params with the defaults overridden by a profile file.
+// Files ending in .json are read as JSON, anything else as YAML.
+func LoadParams(filename string) (*Params, error) {
+	p := NewParams()
+	if err := p.LoadFile(filename); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Find a parameter by name
+func LookupParameter(name string) (Parameter, bool) {
+	for _, param := range PARAMETERS {
+		if param.Name == name {
+			return param, true
+		}
+	}
+	return Parameter{}, false
+}
+
+// Copy the params so they can be overridden without changing the original
+func (p *Params) Clone() *Params {
+	c := &Params{
+		Values:     map[string]float64{},
+		EnableGucs: map[string]string{},
+	}
+	for name, value := range p.Values {
+		c.Values[name] = value
+	}
+	for name, value := range p.EnableGucs {
+		c.EnableGucs[name] = value
+	}
+	return c
+}
+
+// Value of a parameter, 0 if the parameter does not exist
+func (p *Params) Float(name string) float64 {
+	value, ok := p.Values[name]
+	if ok == false {
+		logDebugf("Unknown parameter \"%s\"\n", name)
+	}
+	return value
+}
+
+// Value of a parameter as an integer
+func (p *Params) Int(name string) int64 {
+	return int64(p.Float(name))
+}
+
+// Change a parameter
+func (p *Params) Set(name string, value float64) error {
+	if _, ok := LookupParameter(name); ok == false {
+		return errors.New(fmt.Sprintf("Unknown parameter \"%s\"", name))
+	}
+	p.Values[name] = value
+	return nil
+}
+
+// Change a parameter or enable_ GUC default from text
+func (p *Params) SetString(name string, value string) error {
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(name, "enable_") {
+		p.EnableGucs[name] = value
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Invalid value \"%s\" for parameter \"%s\"", value, name))
+	}
+	return p.Set(name, f)
+}
+
+// Apply an override in the form "name=value"
+func (p *Params) SetOverride(override string) error {
+	parts := strings.SplitN(override, "=", 2)
+	if len(parts) != 2 {
+		return errors.New(fmt.Sprintf("Invalid parameter \"%s\", expected name=value", override))
+	}
+	return p.SetString(parts[0], parts[1])
+}
+
+// Apply a profile file
+func (p *Params) LoadFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var prof profile
+	if err = unmarshalFile(filename, data, &prof); err == nil {
+		err = p.apply(prof)
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("Unable to load profile %s: %s", filename, err))
+	}
+	return nil
+}
+
+// Apply the values of a profile, in name order so the first error is the
+// same every time
+func (p *Params) apply(prof profile) error {
+	names := []string{}
+	for name := range prof.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := p.Set(name, prof.Parameters[name]); err != nil {
+			return err
+		}
+	}
+
+	names = []string{}
+	for name := range prof.EnableGucs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.HasPrefix(name, "enable_") == false {
+			return errors.New(fmt.Sprintf("\"%s\" is not an enable_ GUC", name))
+		}
+		p.EnableGucs[name] = prof.EnableGucs[name]
+	}
+	return nil
+}
+
+// Names of the enable_ GUCs with a default, sorted
+func (p *Params) EnableGucNames() []string {
+	names := []string{}
+	for name := range p.EnableGucs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,160 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewParams(t *testing.T) {
+	p := NewParams()
+	for _, param := range PARAMETERS {
+		if p.Float(param.Name) != param.Default {
+			t.Errorf("%s = %v, want default %v", param.Name, p.Float(param.Name), param.Default)
+		}
+	}
+	if p.Int("motion_count") != 5 {
+		t.Errorf("motion_count = %d, want 5", p.Int("motion_count"))
+	}
+	if len(p.EnableGucs) != len(ENABLEGUCDEFAULTS) || p.EnableGucs["enable_nestloop"] != "off" {
+		t.Errorf("enable_ GUC defaults = %v", p.EnableGucs)
+	}
+	if names := p.EnableGucNames(); len(names) != len(ENABLEGUCDEFAULTS) || names[0] != "enable_bitmapscan" {
+		t.Errorf("EnableGucNames() = %v", names)
+	}
+	if p.Float("no_such_param") != 0 {
+		t.Errorf("unknown parameter = %v, want 0", p.Float("no_such_param"))
+	}
+}
+
+func TestParamsSetOverride(t *testing.T) {
+	tests := []struct {
+		override string
+		err      string
+	}{
+		{"motion_count=3", ""},
+		{" slice_count = 2.5 ", ""},
+		{"enable_nestloop=on", ""},
+		{"motion_count", "Invalid parameter \"motion_count\", expected name=value"},
+		{"motion_count=many", "Invalid value \"many\" for parameter \"motion_count\""},
+		{"no_such_param=1", "Unknown parameter \"no_such_param\""},
+	}
+
+	p := NewParams()
+	for _, test := range tests {
+		err := p.SetOverride(test.override)
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("SetOverride(%q) = %v, want %q", test.override, err, test.err)
+		}
+	}
+	if p.Int("motion_count") != 3 || p.Float("slice_count") != 2.5 || p.EnableGucs["enable_nestloop"] != "on" {
+		t.Errorf("overrides not applied: %v %v", p.Values, p.EnableGucs)
+	}
+}
+
+// Changing a clone leaves the original untouched
+func TestParamsClone(t *testing.T) {
+	p := NewParams()
+	c := p.Clone()
+	c.Set("motion_count", 1)
+	c.SetString("enable_hashjoin", "off")
+	if p.Int("motion_count") != 5 || p.EnableGucs["enable_hashjoin"] != "on" {
+		t.Errorf("changing the clone changed the original")
+	}
+}
+
+func TestLoadParams(t *testing.T) {
+	yamlProfile := `# Thresholds for a large cluster
+parameters:  # thresholds
+  motion_count: 10
+  # a comment line in the section
+  slice_count: 300 # trailing comment
+enable_gucs:
+  enable_nestloop: on
+  enable_mergejoin: 'off'
+`
+	jsonProfile := `{"parameters": {"motion_count": 10, "slice_count": 300}, "enable_gucs": {"enable_nestloop": "on", "enable_mergejoin": "off"}}`
+
+	for _, filename := range []string{
+		writeTestFile(t, "profile.yaml", yamlProfile),
+		writeTestFile(t, "profile.JSON", jsonProfile),
+	} {
+		p, err := LoadParams(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Int("motion_count") != 10 || p.Int("slice_count") != 300 || p.EnableGucs["enable_nestloop"] != "on" || p.EnableGucs["enable_mergejoin"] != "off" {
+			t.Errorf("%s: loaded %v %v", filename, p.Values, p.EnableGucs)
+		}
+		// Values not in the profile keep their default
+		if p.Int("data_skew_min_rows") != 10000 || p.EnableGucs["enable_hashjoin"] != "on" {
+			t.Errorf("%s: defaults lost: %v %v", filename, p.Values, p.EnableGucs)
+		}
+	}
+}
+
+// JSON and YAML profiles are validated the same way
+func TestLoadParamsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"unknown.json", `{"parameters": {"no_such_param": 1}}`, "Unknown parameter \"no_such_param\""},
+		{"unknown.yaml", "parameters:\n  no_such_param: 1\n", "Unknown parameter \"no_such_param\""},
+		{"guc.json", `{"enable_gucs": {"nestloop": "on"}}`, "\"nestloop\" is not an enable_ GUC"},
+		{"guc.yaml", "enable_gucs:\n  nestloop: on\n", "\"nestloop\" is not an enable_ GUC"},
+		{"invalid.json", `{"parameters": {"motion_count": "many"}}`, "cannot unmarshal string"},
+		{"invalid.yaml", "parameters:\n  motion_count: many\n", "line 2: cannot unmarshal !!str `many`"},
+		{"quoted.yaml", "parameters:\n  motion_count: \"10\"\n", "line 2: cannot unmarshal !!str `10`"},
+		{"section.json", `{"settings": {"motion_count": 1}}`, "unknown field \"settings\""},
+		{"section.yaml", "settings:\n  motion_count: 1\n", "line 1: field settings not found"},
+		{"outside.yaml", "  motion_count: 1\n", "line 1: field motion_count not found"},
+	}
+
+	for _, test := range tests {
+		filename := writeTestFile(t, test.name, test.content)
+		_, err := LoadParams(filename)
+		if err == nil || strings.HasPrefix(err.Error(), "Unable to load profile "+filename) == false || strings.Contains(err.Error(), test.err) == false {
+			t.Errorf("%s: LoadParams() = %v, want %q", test.name, err, test.err)
+		}
+	}
+
+	if _, err := LoadParams("../testdata/no-such-profile.yaml"); err == nil {
+		t.Errorf("LoadParams found a missing file")
+	}
+}
+
+// Checks use the thresholds of the params they are run with
+func TestCheckWithParams(t *testing.T) {
+	count := func(f *Findings, id string) int {
+		found := 0
+		for _, w := range f.Warnings {
+			if w.CheckId == id {
+				found++
+			}
+		}
+		return found
+	}
+
+	// explain11 has 4 Redistribute and Broadcast motions
+	e := loadTestExplain(t, "../testdata/explain11.txt")
+	if f := e.CheckWithConfig(CheckConfig{}); count(f, "motion-count") != 0 {
+		t.Errorf("motion-count reported with the default of 5")
+	}
+	p := NewParams()
+	p.Set("motion_count", 4)
+	if f := e.CheckWithConfig(CheckConfig{Params: p}); count(f, "motion-count") != 1 {
+		t.Errorf("motion-count not reported with a threshold of 4")
+	}
+
+	// explain07 sets enable_hashjoin=off and enable_indexscan=off
+	e = loadTestExplain(t, "../testdata/explain07.txt")
+	if f := e.CheckWithConfig(CheckConfig{}); count(f, "enable-guc-non-default") != 2 {
+		t.Errorf("enable-guc-non-default reported %d times, want 2", count(f, "enable-guc-non-default"))
+	}
+	p = NewParams()
+	p.SetOverride("enable_hashjoin=off")
+	if f := e.CheckWithConfig(CheckConfig{Params: p}); count(f, "enable-guc-non-default") != 1 {
+		t.Errorf("enable-guc-non-default reported for a changed default")
+	}
+}
@@ -0,0 +1,459 @@
+package plan
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Partition elimination
+//
+// The legacy planner scans each child partition that was not eliminated
+// under an Append, named after the parent table and the partition at each
+// level:
+//
+//	->  Append
+//	      ->  Seq Scan on sales_1_prt_outlying_years s
+//	      ->  Seq Scan on sales_1_prt_2 s
+//
+// ORCA scans all partitions with one Dynamic Table Scan. A Partition
+// Selector with the same dynamic scan id chooses the partitions, either from
+// constants when the plan starts or from the rows of the other side of a
+// join while it runs:
+//
+//	->  Sequence                                             static
+//	      ->  Partition Selector for sales (dynamic scan id: 1)
+//	            Filter: year = 2015
+//	            Partitions selected:  1 (out of 100)
+//	      ->  Dynamic Table Scan on sales (dynamic scan id: 1)
+//
+//	->  Hash Join                                            dynamic
+//	      ->  Dynamic Table Scan on sales (dynamic scan id: 1)
+//	      ->  Hash
+//	            ->  Partition Selector for sales (dynamic scan id: 1)
+//	                  ->  Seq Scan on dates
+//
+// PartitionedTables() groups the scans, child partitions and selectors per
+// partitioned table. A table scanned more than once, e.g. in a self join, is
+// selected independently by each scan, so elimination and partition counts
+// are kept per selection: the scans and selectors with the same dynamic scan
+// id, or the child partitions under the same Append for the legacy planner.
+// The partitions selected are the Append children or the "Partitions
+// selected" of the selectors, and the partitions scanned come from the
+// "Partitions scanned" of the Dynamic Table Scans or the children that ran in
+// EXPLAIN ANALYZE output. The total is only known from ORCA.
+//
+// Default partitions can not be eliminated by the legacy planner and are
+// recognised by their name, e.g. sales_1_prt_outlying_years or
+// sales_1_prt_other.
+
+// Kinds of partition elimination of a table
+const (
+	EliminationStatic  = "static"  // Partitions chosen when the plan starts
+	EliminationDynamic = "dynamic" // Partitions chosen from the rows of a join
+	EliminationNone    = "none"    // All partitions scanned
+)
+
+// Partitions chosen by one scan of a partitioned table
+type PartitionSelection struct {
+	ScanId      string // Dynamic scan id, empty for the legacy planner
+	Elimination string
+	Partitions  []string    // Child partitions under the Append, legacy planner only
+	Selected    OptionalInt // Partitions chosen by the plan
+	Scanned     OptionalInt // Partitions scanned per segment, EXPLAIN ANALYZE only
+	Total       OptionalInt // Partitions of the table, ORCA only
+	Scans       []*Node     `json:"-"` // Child partition and Dynamic scans
+	Selectors   []*Node     `json:"-"`
+}
+
+// Scans and partition elimination of a partitioned table
+type PartitionedTable struct {
+	Table      string
+	Partitions []string // Child partitions in the plan, legacy planner only
+	Defaults   []string // Default partitions in the plan
+	Selections []PartitionSelection
+	Scans      []*Node `json:"-"` // Child partition and Dynamic scans
+	Selectors  []*Node `json:"-"`
+}
+
+var (
+	partitionLevelPattern   = regexp.MustCompile(`_[0-9]+_prt_`)
+	defaultPartitionPattern = regexp.MustCompile(`(?i)^(default|other|outlying|extra|others)`)
+	dynamicScanIdPattern    = regexp.MustCompile(`\(dynamic scan id: ([0-9]+)\)`)
+)
+
+// Return the dynamic scan id of a Dynamic Scan or Partition Selector, empty
+// for other nodes
+func (n *Node) DynamicScanId() string {
+	if m := dynamicScanIdPattern.FindStringSubmatch(n.Operator); len(m) == 2 {
+		return m[1]
+	}
+	return ""
+}
+
+// True if a child partition is a default partition at any level, e.g.
+// sales_1_prt_outlying_years
+func isDefaultPartition(name string) bool {
+	for _, level := range partitionLevelPattern.Split(name, -1)[1:] {
+		if defaultPartitionPattern.MatchString(level) {
+			return true
+		}
+	}
+	return false
+}
+
+// True if a Partition Selector chooses partitions from the rows of its input
+func isDynamicSelector(n *Node) bool {
+	return n.IsType(NodeTypePartitionSelector) && len(n.SubNodes) > 0
+}
+
+// Group the scans of partitioned tables per table, in plan order
+func (e *Explain) PartitionedTables() []PartitionedTable {
+	tables := []*PartitionedTable{}
+	byTable := map[string]*PartitionedTable{}
+	selections := map[*PartitionedTable][]*PartitionSelection{}
+	bySelection := map[string]*PartitionSelection{}
+	table := func(name string) *PartitionedTable {
+		t, ok := byTable[lastIdentifier(name)]
+		if ok == false {
+			t = &PartitionedTable{Table: name}
+			byTable[lastIdentifier(name)] = t
+			tables = append(tables, t)
+		}
+		return t
+	}
+	// Selections are keyed by dynamic scan id, or by the Append of the
+	// child partitions
+	selection := func(t *PartitionedTable, scanId string, key string) *PartitionSelection {
+		key = lastIdentifier(t.Table) + "/" + key
+		s, ok := bySelection[key]
+		if ok == false {
+			s = &PartitionSelection{ScanId: scanId}
+			bySelection[key] = s
+			selections[t] = append(selections[t], s)
+		}
+		return s
+	}
+
+	for _, n := range e.Nodes {
+		if n.IsType(NodeTypePartitionSelector) {
+			if m := partitionSelectorOf.FindStringSubmatch(n.Operator); len(m) == 2 {
+				t := table(m[1])
+				t.Selectors = append(t.Selectors, n)
+				s := selection(t, n.DynamicScanId(), "id "+n.DynamicScanId())
+				s.Selectors = append(s.Selectors, n)
+			}
+		} else if n.IsType(NodeTypeDynamicTableScan, NodeTypeDynamicIndexScan) {
+			t := table(n.TableName())
+			t.Scans = append(t.Scans, n)
+			s := selection(t, n.DynamicScanId(), "id "+n.DynamicScanId())
+			s.Scans = append(s.Scans, n)
+		} else if n.Category == CategoryScan && partitionChildSuffix.MatchString(n.Object) {
+			t := table(n.TableName())
+			t.Scans = append(t.Scans, n)
+			if containsString(t.Partitions, n.Object) == false {
+				t.Partitions = append(t.Partitions, n.Object)
+				if isDefaultPartition(n.Object) {
+					t.Defaults = append(t.Defaults, n.Object)
+				}
+			}
+			parent := -1
+			if a := partitionAppend(n); a != nil {
+				parent = a.Id
+			}
+			s := selection(t, "", fmt.Sprintf("node %d", parent))
+			s.Scans = append(s.Scans, n)
+			if containsString(s.Partitions, n.Object) == false {
+				s.Partitions = append(s.Partitions, n.Object)
+			}
+		}
+	}
+
+	result := []PartitionedTable{}
+	for _, t := range tables {
+		for _, s := range selections[t] {
+			s.summarize()
+			t.Selections = append(t.Selections, *s)
+		}
+		result = append(result, *t)
+	}
+	return result
+}
+
+// Work out the elimination and partition counts from the scans and
+// selectors. With several scans or selectors the largest counts are used.
+func (s *PartitionSelection) summarize() {
+	scanned := []string{}
+	analyzed := false
+	for _, n := range s.Scans {
+		if n.PartScanned.Valid && n.PartScanned.Value >= s.Scanned.Value {
+			s.Scanned = n.PartScanned
+			s.Total = n.PartScannedTotal
+		}
+		if n.IsAnalyzed {
+			analyzed = true
+			if containsString(scanned, n.Object) == false {
+				scanned = append(scanned, n.Object)
+			}
+		}
+	}
+	for _, n := range s.Selectors {
+		if n.PartSelected.Valid && n.PartSelected.Value >= s.Selected.Value {
+			s.Selected = n.PartSelected
+			s.Total = n.PartSelectedTotal
+		}
+	}
+
+	// Legacy planner, the children in the plan are the partitions left
+	if len(s.Partitions) > 0 {
+		s.Selected = validInt(int64(len(s.Partitions)))
+		if analyzed && s.Scanned.Valid == false {
+			s.Scanned = validInt(int64(len(scanned)))
+		}
+		s.Elimination = EliminationStatic
+		return
+	}
+
+	switch {
+	case len(s.Selectors) == 0:
+		s.Elimination = EliminationNone
+	case s.Total.Valid && s.Selected.Valid && s.Selected.Value >= s.Total.Value:
+		s.Elimination = EliminationNone
+	default:
+		s.Elimination = EliminationStatic
+		for _, n := range s.Selectors {
+			if isDynamicSelector(n) {
+				s.Elimination = EliminationDynamic
+			}
+		}
+	}
+}
+
+// Return the Append above a child partition scan, which may be wrapped in a
+// Result, or nil if there is none
+func partitionAppend(n *Node) *Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.IsType(NodeTypeAppend) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Return the node to attach a warning about the selection to: the Append of
+// the child partitions, the Dynamic Scan or the Partition Selector
+func (s PartitionSelection) node() *Node {
+	if len(s.Scans) > 0 {
+		if a := partitionAppend(s.Scans[0]); len(s.Partitions) > 0 && a != nil {
+			return a
+		}
+		return s.Scans[0]
+	}
+	if len(s.Selectors) > 0 {
+		return s.Selectors[0]
+	}
+	return nil
+}
+
+// Describe the partitions of a selection, e.g.
+// "static elimination, 1 of 100 partitions selected, 1 scanned"
+func (s PartitionSelection) String() string {
+	r := s.Elimination + " elimination"
+	if s.Elimination == EliminationNone {
+		r = "no elimination"
+	}
+	if s.Selected.Valid {
+		if s.Total.Valid {
+			r += fmt.Sprintf(", %d of %d partitions selected", s.Selected.Value, s.Total.Value)
+		} else {
+			r += fmt.Sprintf(", %s selected", plural(int(s.Selected.Value), "partition"))
+		}
+	}
+	if s.Scanned.Valid {
+		r += fmt.Sprintf(", %d scanned", s.Scanned.Value)
+	}
+	return r
+}
+
+// Name a selection of the table, e.g. "sales (dynamic scan id 1)"
+func (t PartitionedTable) describeSelection(s PartitionSelection) string {
+	if s.ScanId != "" {
+		return fmt.Sprintf("%s (dynamic scan id %s)", t.Table, s.ScanId)
+	}
+	return t.Table
+}
+
+// Name the default partitions, or count them when there are several
+func (t PartitionedTable) describeDefaults() string {
+	if len(t.Defaults) == 1 {
+		return "default partition " + t.Defaults[0]
+	}
+	return plural(len(t.Defaults), "default partition")
+}
+
+// Describe the partitions of a table, e.g.
+// "sales: static elimination, 1 of 100 partitions selected, 1 scanned" or
+// "sales: scan id 1 static elimination, ...; scan id 2 no elimination, ..."
+func (t PartitionedTable) String() string {
+	s := t.Table + ":"
+	for i, selection := range t.Selections {
+		if i > 0 {
+			s += ";"
+		}
+		if len(t.Selections) > 1 {
+			if selection.ScanId != "" {
+				s += " scan id " + selection.ScanId
+			} else {
+				s += fmt.Sprintf(" scan %d", i+1)
+			}
+		}
+		s += " " + selection.String()
+	}
+	if len(t.Defaults) > 0 {
+		s += ", " + t.describeDefaults()
+	}
+	return s
+}
+
+var partitionChecks = []ExplainCheck{
+	ExplainCheck{
+		CheckInfo: CheckInfo{
+			Id:            "partition-scans",
+			Name:          "checkExplainPartitionScans",
+			Description:   "Number of partition scans greater than 100 or 25%",
+			CreatedAt:     "2016-05-31",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryPartitioning,
+			Severity:      SeverityWarning,
+			Documentation: "Many partitions or a large share of them are scanned. Partition elimination did not work, check the predicates on the partition key.",
+			Parameters:    []string{"partition_scan_count", "partition_scan_percent"},
+		},
+		Exec: func(e *Explain, f *Findings) {
+			for _, t := range e.PartitionedTables() {
+				for _, s := range t.Selections {
+					// Partitions scanned when known, else the partitions selected
+					count, verb := s.Selected, "selected"
+					if s.Scanned.Valid {
+						count, verb = s.Scanned, "scanned"
+					}
+					n := s.node()
+					if count.Valid == false || n == nil {
+						continue
+					}
+
+					w := Warning{Resolution: "Check if partitions can be eliminated"}
+					switch {
+					case count.Value == 0:
+						w = Warning{
+							Cause:      fmt.Sprintf("%s: zero partitions %s", t.describeSelection(s), verb),
+							Resolution: "Review query"}
+					case s.Total.Valid && s.Total.Value > 0 && count.Value*100/s.Total.Value >= f.Params.Int("partition_scan_percent"):
+						w.Cause = fmt.Sprintf("%s: %d%% (%d out of %d) partitions %s", t.describeSelection(s), count.Value*100/s.Total.Value, count.Value, s.Total.Value, verb)
+					case count.Value >= f.Params.Int("partition_scan_count"):
+						w.Cause = fmt.Sprintf("%s: %s %s", t.describeSelection(s), plural(int(count.Value), "partition"), verb)
+					default:
+						continue
+					}
+					if len(s.Partitions) > 0 {
+						w.Remediation = optimizerRemediation(f.Explain)
+					}
+					f.AddNodeWarning(n, w)
+				}
+			}
+		}},
+	ExplainCheck{
+		CheckInfo: CheckInfo{
+			Id:            "partition-default-scanned",
+			Name:          "checkExplainPartitionDefaultScanned",
+			Description:   "Default partition holding many of the rows scanned",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryPartitioning,
+			Severity:      SeverityWarning,
+			Documentation: "A default (outlying) partition is scanned and is estimated to hold partition_default_percent or more of the rows of the table scanned. Default partitions can not be eliminated, so rows that belong in a partition of their own are read by every query.",
+			Parameters:    []string{"partition_default_percent"},
+		},
+		Exec: func(e *Explain, f *Findings) {
+			for _, t := range e.PartitionedTables() {
+				if len(t.Defaults) == 0 {
+					continue
+				}
+				total := 0.0
+				defaults := 0.0
+				for _, n := range t.Scans {
+					total += n.rowsPerSegment()
+					if containsString(t.Defaults, n.Object) {
+						defaults += n.rowsPerSegment()
+					}
+				}
+				if total <= 0 || defaults*100/total < f.Params.Float("partition_default_percent") {
+					continue
+				}
+				f.AddWarning(Warning{
+					Cause:      fmt.Sprintf("The %s of %s holds %.0f%% of the rows scanned, %.0f of %.0f rows per segment", t.describeDefaults(), t.Table, defaults*100/total, defaults, total),
+					Resolution: "Add partitions for the rows in the default partition so they can be eliminated"})
+			}
+		}},
+	ExplainCheck{
+		CheckInfo: CheckInfo{
+			Id:            "partition-estimate",
+			Name:          "checkExplainPartitionEstimate",
+			Description:   "Partitions scanned differ from the partitions selected",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryPartitioning,
+			Severity:      SeverityWarning,
+			Documentation: "The number of partitions scanned differs by partition_estimate_factor or more from the partitions selected when planning. The cost of the plan was based on the wrong partitions; with dynamic elimination it means the join selected more or fewer partitions than estimated.",
+			Parameters:    []string{"partition_estimate_factor"},
+		},
+		Exec: func(e *Explain, f *Findings) {
+			for _, t := range e.PartitionedTables() {
+				for _, s := range t.Selections {
+					if s.Selected.Valid == false || s.Scanned.Valid == false {
+						continue
+					}
+					if qError(float64(s.Selected.Value), float64(s.Scanned.Value)) < f.Params.Float("partition_estimate_factor") {
+						continue
+					}
+					f.AddWarning(Warning{
+						Cause:       fmt.Sprintf("%s: %s selected but %d scanned", t.describeSelection(s), plural(int(s.Selected.Value), "partition"), s.Scanned.Value),
+						Resolution:  "Check the statistics of the partitioned table and the tables it is joined with",
+						Remediation: []string{analyzeStatement(t.Table)}})
+				}
+			}
+		}},
+	ExplainCheck{
+		CheckInfo: CheckInfo{
+			Id:            "dynamic-scan-no-selector",
+			Name:          "checkExplainDynamicScanNoSelector",
+			Description:   "Dynamic Table Scan without a Partition Selector",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryPartitioning,
+			Severity:      SeverityWarning,
+			Documentation: "No Partition Selector has the dynamic scan id of the Dynamic Table Scan, so no partitions are eliminated and all of them are scanned.",
+		},
+		Exec: func(e *Explain, f *Findings) {
+			for _, t := range e.PartitionedTables() {
+				for _, s := range t.Selections {
+					if s.ScanId == "" || len(s.Selectors) > 0 {
+						continue
+					}
+					for _, n := range s.Scans {
+						f.AddNodeWarning(n, Warning{
+							Cause:      fmt.Sprintf("No Partition Selector for dynamic scan id %s, all partitions of %s are scanned", s.ScanId, t.Table),
+							Resolution: "Filter or join on the partition key so partitions can be eliminated"})
+					}
+				}
+			}
+		}},
+}
+
+func init() {
+	for _, c := range partitionChecks {
+		RegisterExplainCheck(c)
+	}
+}
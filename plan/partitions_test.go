@@ -0,0 +1,141 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDynamicScanId(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+	tests := []struct {
+		id   int
+		want string
+	}{
+		{3, "1"}, // Partition Selector for sales (dynamic scan id: 1)
+		{4, "1"}, // Dynamic Table Scan on sales (dynamic scan id: 1)
+		{9, "2"},
+		{1, ""},
+	}
+
+	for _, test := range tests {
+		if got := e.Nodes[test.id].DynamicScanId(); got != test.want {
+			t.Errorf("%s dynamic scan id = %q, want %q", e.Nodes[test.id].Operator, got, test.want)
+		}
+	}
+}
+
+func TestIsDefaultPartition(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"sales_1_prt_outlying_years", true},
+		{"mi_asset_position_1_prt_outlying_years_2_prt_2", true},
+		{"mi_asset_position_1_prt_2_prt_other_months", false},
+		{"mi_asset_position_1_prt_2_2_prt_other_months", true},
+		{"sales_1_prt_16", false},
+		{"outlying_years", false},
+	}
+
+	for _, test := range tests {
+		if got := isDefaultPartition(test.name); got != test.want {
+			t.Errorf("isDefaultPartition(%q) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPartitionedTables(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     []string
+	}{
+		{"../testdata/explain01.txt", []string{"sales: static elimination, 1 of 100 partitions selected"}},
+		{"../testdata/explain02.txt", []string{"sales: static elimination, 1 of 100 partitions selected, 1 scanned"}},
+		// Legacy planner, the Append children are the partitions selected
+		{"../testdata/explain03.txt", []string{"sales: static elimination, 2 partitions selected, default partition sales_1_prt_outlying_years"}},
+		{"../testdata/explain04.txt", []string{"sales: static elimination, 2 partitions selected, 2 scanned, default partition sales_1_prt_outlying_years"}},
+		// sales is scanned twice, the Partition Selector of the Hash side
+		// selects all partitions
+		{"../testdata/explain05.txt", []string{"sales: scan id 1 static elimination, 1 of 100 partitions selected, 1 scanned; scan id 2 no elimination, 100 of 100 partitions selected, 100 scanned"}},
+		{"../testdata/explain12.txt", []string{
+			"trn_purch_detail: static elimination, 1 partition selected, 1 scanned",
+			"trn_purch_header: static elimination, 1 partition selected, 1 scanned",
+		}},
+		{"../testdata/explain19.txt", []string{"mi_asset_position: static elimination, 1196 partitions selected, 1196 scanned, 104 default partitions"}},
+		{"../testdata/explain18.txt", []string{}},
+	}
+
+	for _, test := range tests {
+		got := []string{}
+		for _, table := range loadTestExplain(t, test.filename).PartitionedTables() {
+			got = append(got, table.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: partitioned tables = %q, want %q", test.filename, got, test.want)
+		}
+	}
+
+	// Both Dynamic Table Scans and Partition Selectors of explain05 are
+	// grouped under the table, and per dynamic scan id under the selections
+	tables := loadTestExplain(t, "../testdata/explain05.txt").PartitionedTables()
+	if len(tables[0].Scans) != 2 || len(tables[0].Selectors) != 2 {
+		t.Errorf("sales has %d scans and %d selectors, want 2 and 2", len(tables[0].Scans), len(tables[0].Selectors))
+	}
+	for i, s := range tables[0].Selections {
+		if s.ScanId != []string{"1", "2"}[i] || len(s.Scans) != 1 || len(s.Selectors) != 1 {
+			t.Errorf("sales selection %d has scan id %q, %d scans and %d selectors", i, s.ScanId, len(s.Scans), len(s.Selectors))
+		}
+	}
+	if s := loadTestExplain(t, "../testdata/explain20.txt").Summarize(5, nil); len(s.PartitionedTables) != 1 || s.PartitionedTables[0].Table != "sales" {
+		t.Errorf("summary partitioned tables = %v", s.PartitionedTables)
+	}
+}
+
+// Warnings of the partition checks on testdata with the default thresholds
+func TestPartitionChecksTestdata(t *testing.T) {
+	got := testdataWarnings(t, "partition-scans", "partition-default-scanned", "partition-estimate", "dynamic-scan-no-selector")
+	compareTestdataWarnings(t, got, map[string][]string{
+		"explain03.txt": {"partition-default-scanned"},
+		// The Dynamic Table Scans of the Hash side scan all partitions
+		"explain05.txt": {"#9 partition-scans"},
+		"explain07.txt": {"#9 partition-scans"},
+		// The Append of all 100 partitions
+		"explain09.txt": {"#7 partition-scans", "partition-default-scanned"},
+		"explain19.txt": {"#3 partition-scans"},
+		"explain20.txt": {"#3 partition-scans"},
+	})
+}
+
+func TestPartitionCheckCauses(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain09.txt")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"partition-default-scanned"}})
+	want := "The default partition sales_1_prt_outlying_years of sales holds 94% of the rows scanned, 2476236 of 2646714 rows per segment"
+	if len(f.Warnings) != 1 || f.Warnings[0].Cause != want {
+		t.Errorf("partition-default-scanned warnings = %+v, want %q", f.Warnings, want)
+	}
+
+	// Only the scan of sales without elimination is reported
+	e = loadTestExplain(t, "../testdata/explain05.txt")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"partition-scans"}})
+	want = "sales (dynamic scan id 2): 100% (100 out of 100) partitions scanned"
+	if w := f.NodeWarnings[e.Nodes[9]]; len(f.NodeWarnings) != 1 || len(w) != 1 || w[0].Cause != want {
+		t.Errorf("partition-scans warnings = %+v, want %q", f.NodeWarnings, want)
+	}
+
+	// explain02 with more partitions selected than scanned
+	e = loadEditedExplain(t, "../testdata/explain02.txt",
+		"Partitions selected:  1 (out of 100)", "Partitions selected:  3 (out of 100)")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"partition-estimate"}})
+	if len(f.Warnings) != 1 || f.Warnings[0].Cause != "sales (dynamic scan id 1): 3 partitions selected but 1 scanned" || strings.Join(f.Warnings[0].Remediation, " ") != "ANALYZE sales;" {
+		t.Errorf("partition-estimate warnings = %+v", f.Warnings)
+	}
+
+	// explain05 with the selector of the Hash side for another scan
+	e = loadEditedExplain(t, "../testdata/explain05.txt",
+		"Partition Selector for sales (dynamic scan id: 2)", "Partition Selector for sales (dynamic scan id: 3)")
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"dynamic-scan-no-selector"}})
+	want = "No Partition Selector for dynamic scan id 2, all partitions of sales are scanned"
+	if w := f.NodeWarnings[e.Nodes[9]]; len(f.NodeWarnings) != 1 || len(w) != 1 || w[0].Cause != want {
+		t.Errorf("dynamic-scan-no-selector warnings = %+v, want %q", f.NodeWarnings, want)
+	}
+}
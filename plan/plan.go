@@ -18,9 +18,14 @@ type Node struct {
 
 	// Variables parsed from EXPLAIN
 	Operator    string
-	Object      string // Name of index or table. Only exists for some nodes
-	ObjectType  string // TABLE, INDEX, etc...
-	Slice       int64
+	Type        NodeType     // Operator classified in to a known type
+	Category    NodeCategory // Scan, Join, Motion, etc...
+	JoinType    JoinType     // Inner, Left, Semi, etc... Only set for join nodes
+	ScanMethod  ScanMethod   // Sequential, Index, etc... Only set for scan nodes
+	Object      string       // Name of index or table. Only exists for some nodes
+	ObjectType  string       // TABLE, INDEX, etc...
+	Slice       OptionalInt  // Only set on nodes labelled with a slice, i.e. Motions
+	Segments    OptionalInt  // Number of segments running the slice, only set with Slice
 	StartupCost float64
 	TotalCost   float64
 	NodeCost    float64
@@ -29,26 +34,39 @@ type Node struct {
 	Width       int64
 
 	// Variables parsed from EXPLAIN ANALYZE
-	ActualRows        float64
-	AvgRows           float64
-	Workers           int64
-	MaxRows           float64
-	MaxSeg            string
-	Scans             int64
-	MsFirst           float64
-	MsEnd             float64
-	MsOffset          float64
-	MsNode            float64
+	// Values not present in the plan have Valid set to false
+	ActualRows        OptionalCount
+	AvgRows           OptionalCount
+	Workers           OptionalInt
+	MaxRows           OptionalCount
+	MaxSeg            string // Empty if not present
+	Scans             OptionalInt
+	MsFirst           OptionalDuration
+	MsEnd             OptionalDuration
+	MsOffset          OptionalDuration
+	MsNode            OptionalDuration // Time spent in this node excluding children, see timing.go
 	MsPrct            float64
-	AvgMem            float64
-	MaxMem            float64
-	ExecMemLine       float64
-	SpillFile         int64
-	SpillReuse        int64
-	PartSelected      int64
-	PartSelectedTotal int64
-	PartScanned       int64
-	PartScannedTotal  int64
+	StartTime         OptionalDuration // When the node started relative to the start of the query
+	ActualRowsPerSeg  OptionalCount    // Actual rows per segment and scan, comparable with Rows
+	QError            OptionalFloat    // Estimate error factor, see estimates.go
+	EndTime           OptionalDuration // When the node finished relative to the start of the query
+	AvgMem            OptionalBytes
+	MaxMem            OptionalBytes
+	WorkMemWanted     OptionalBytes // Max Work_mem wanted to avoid spilling
+	ExecMemLine       OptionalBytes
+	SpillFile         OptionalInt
+	SpillReuse        OptionalInt
+	WorkfileBytes     OptionalBytes // Bytes written to workfiles by the segment reporting the batches
+	WorkMemSeg        string        // Segment using MaxMem, empty if not present
+	ExecMemAvg        OptionalBytes
+	ExecMemMax        OptionalBytes
+	ExecMemSeg        string        // Segment using ExecMemMax, empty if not present
+	Skew              OptionalFloat // Skew coefficient across segments, see skew.go
+	SkewSegment       string        // Segment causing the Skew
+	PartSelected      OptionalInt
+	PartSelectedTotal OptionalInt
+	PartScanned       OptionalInt
+	PartScannedTotal  OptionalInt
 	Filter            string
 
 	// Contains all the text lines below each node
@@ -58,11 +76,21 @@ type Node struct {
 	SubNodes []*Node
 	SubPlans []*Plan
 
+	// Populated in BuildTree() to locate the node in the tree
+	Id     int    // Sequential position of the node in the plan text
+	Path   string // Position in the tree, e.g. "0.1.2" is the 3rd child of the 2nd child of the top node
+	Depth  int    // Number of nodes above this node
+	Parent *Node  `json:"-"` // Parent node, nil for the top node
+	Plan   *Plan  `json:"-"` // Set if this node is the TopNode of a plan
+
 	// Populated with any warning for the node
 	Warnings []Warning
 
 	// Flag to detect if we are looking at EXPLAIN or EXPLAIN ANALYZE output
 	IsAnalyzed bool
+
+	// Populated in Analyze() if the node is on the critical path
+	OnCriticalPath bool
 }
 
 // Each plan has a top node
@@ -75,34 +103,38 @@ type Plan struct {
 
 // Warnings get added to the overall Explain object or a Node object
 type Warning struct {
-	Cause      string // What caused the warning
-	Resolution string // What should be done to resolve it
-}
-
-type NodeCheck struct {
-	Name        string
-	Description string
-	CreatedAt   string
-	Scope       []string
-	Exec        func(*Node)
+	Cause       string   // What caused the warning
+	Resolution  string   // What should be done to resolve it
+	CheckId     string   // Id of the check that produced the warning
+	Severity    Severity // Defaults to the severity of the check
+	Node        *Node    `json:"-"` // Node the warning refers to, nil for the overall EXPLAIN output
+	Waiver      *Waiver  // Set if the warning was suppressed, see waivers.go
+	Remediation []string // SQL statements resolving the warning, see remediation.go
 }
 
-type ExplainCheck struct {
-	Name        string
-	Description string
-	CreatedAt   string
-	Scope       []string
-	Exec        func(*Explain)
+// Findings holds the warnings produced by a single run of the checks.
+// Every call to Check() returns a fresh Findings so the same parsed
+// Explain can be checked multiple times without duplicating warnings.
+type Findings struct {
+	Warnings     []Warning           // Warnings for the overall EXPLAIN output
+	NodeWarnings map[*Node][]Warning // Warnings for each node
+	Params       *Params             // Thresholds used by the checks
+	Explain      *Explain            `json:"-"` // Explain being checked
+	Suppressed   []Warning           // Warnings matching a waiver, in plan order
+
+	check *CheckInfo // Check currently running, used to label warnings
 }
 
 // Slice stats parsed from EXPLAIN ANALYZE output
 type SliceStat struct {
 	Name          string
-	MemoryAvg     int64
+	MemoryAvg     ByteSize
 	Workers       int64
-	MemoryMax     int64
-	WorkMem       int64
-	WorkMemWanted int64
+	MemoryMax     ByteSize
+	Segment       string // Segment using MemoryMax, empty for the coordinator slice
+	WorkMem       ByteSize
+	WorkMemWanted ByteSize
+	Constrained   bool // Marked with "*" as the slice wanted more work_mem than it got
 }
 
 // GUCs are parsed so can do checks for specific settings
@@ -116,16 +148,30 @@ type Explain struct {
 	Nodes           []*Node // All nodes get added here
 	Plans           []*Plan // All plans get added here
 	SliceStats      []string
-	MemoryUsed      int64
-	MemoryWanted    int64
+	MemoryUsed      OptionalBytes
+	MemoryWanted    OptionalBytes
 	Settings        []Setting
 	Optimizer       string
 	OptimizerStatus string
-	Runtime         float64
+	Runtime         OptionalDuration
+	Query           string // SQL captured from the psql prompt lines, empty if not present
+
+	// Populated in Analyze() for EXPLAIN ANALYZE output, see critical.go
+	CriticalPath      []CriticalPathStep
+	CriticalPathStart OptionalDuration // When the last node on the critical path started
 
 	// Populated with any warning for the overall EXPLAIN output
 	Warnings []Warning
 
+	// Populated with warnings suppressed by a waiver, see waivers.go
+	Suppressed []Warning
+
+	// Populated in Analyze() with signs of a damaged plan, see consistency.go
+	Diagnostics []Diagnostic
+
+	// Populated in ApplyFindings(), see summary.go
+	Summary Summary
+
 	lines        []string
 	lineOffset   int
 	planFinished bool
@@ -135,9 +181,12 @@ var (
 	logDebug bool
 
 	patterns = map[string]*regexp.Regexp{
-		"NODE":    regexp.MustCompile(`(.*) \((cost=(.*)\.\.(.*) ){0,1}rows=(.*) width=(.*)\)`),
-		"SLICE":   regexp.MustCompile(`(.*)  \(slice([0-9]*)`),
-		"SUBPLAN": regexp.MustCompile(` SubPlan `),
+		"NODE":     regexp.MustCompile(`(.*) \((cost=(.*)\.\.(.*) ){0,1}rows=(.*) width=(.*)\)`),
+		"SLICE":    regexp.MustCompile(`(.*) +\(slice([0-9]*)`),
+		"SEGMENTS": regexp.MustCompile(`segments: ([0-9]+)`),
+		"MOTION":   regexp.MustCompile(`Motion ([0-9]+):([0-9]+)`),
+		"SUBPLAN":  regexp.MustCompile(` SubPlan `),
+		"PROMPT":   regexp.MustCompile(`^[^\s=]+([=\-'"(])[#>] ?(.*)$`),
 
 		"SLICESTATS":   regexp.MustCompile(` Slice statistics:`),
 		"SLICESTATS_1": regexp.MustCompile(`\((slice[0-9]{1,})\).*Executor memory: ([0-9]{1,})K bytes`),
@@ -162,13 +211,19 @@ var (
 	// ------------------------------------------------------------
 	NODECHECKS = []NodeCheck{
 		NodeCheck{
-			"checkNodeEstimatedRows",
-			"Scan node with estimated rows equal to 1",
-			"2016-05-24",
-			[]string{"orca", "legacy"},
-			func(n *Node) {
-				re := regexp.MustCompile(`(Dynamic Table|Table|Parquet table|Bitmap Index|Bitmap Append-Only Row-Oriented|Seq) Scan`)
-				if re.MatchString(n.Operator) {
+			CheckInfo: CheckInfo{
+				Id:            "estimated-rows-one",
+				Name:          "checkNodeEstimatedRows",
+				Description:   "Scan node with estimated rows equal to 1",
+				CreatedAt:     "2016-05-24",
+				Scope:         []string{"orca", "legacy"},
+				Dialects:      []string{DialectGreenplum, DialectHawq},
+				Category:      CheckCategoryStatistics,
+				Severity:      SeverityWarning,
+				Documentation: "An estimate of 1 row on a scan usually means the table or index has never been analyzed. The optimizer then chooses join orders and motions that are far from optimal.",
+			},
+			Exec: func(n *Node, f *Findings) {
+				if n.IsType(NodeTypeDynamicTableScan, NodeTypeTableScan, NodeTypeParquetScan, NodeTypeBitmapIndexScan, NodeTypeBitmapHeapScan, NodeTypeSeqScan) {
 					if n.Rows == 1 {
 						warningAction := ""
 						// Preformat the string here
@@ -180,174 +235,167 @@ var (
 
 						// If EXPLAIN ANALYZE output then have to check further
 						if n.IsAnalyzed == true {
-							if n.ActualRows > 1 || n.AvgRows > 1 {
-								n.Warnings = append(n.Warnings, Warning{
-									"Actual rows is higher than estimated rows",
-									fmt.Sprintf("Need to run %s \"%s\"", warningAction, n.Object)})
+							if n.ActualRows.Value > 1 || n.AvgRows.Value > 1 {
+								f.AddNodeWarning(n, Warning{
+									Cause:       "Actual rows is higher than estimated rows",
+									Resolution:  fmt.Sprintf("Need to run %s \"%s\"", warningAction, n.Object),
+									Remediation: statisticsRemediation(n)})
 							}
 							// Else just flag as a potential not analyzed table
 						} else {
-							n.Warnings = append(n.Warnings, Warning{
-								"Estimated rows is 1",
-								fmt.Sprintf("May need to run %s \"%s\"", warningAction, n.Object)})
+							f.AddNodeWarning(n, Warning{
+								Cause:       "Estimated rows is 1",
+								Resolution:  fmt.Sprintf("May need to run %s \"%s\"", warningAction, n.Object),
+								Remediation: statisticsRemediation(n)})
 						}
 					}
 				}
 			}},
 		NodeCheck{
-			"checkNodeNestedLoop",
-			"Nested Loops",
-			"2016-05-23",
-			[]string{"orca", "legacy"},
-			func(n *Node) {
-				re := regexp.MustCompile(`Nested Loop`)
-				if re.MatchString(n.Operator) {
-					n.Warnings = append(n.Warnings, Warning{
-						"Nested Loop",
-						"Review query"})
-				}
-			}},
-		NodeCheck{
-			"checkNodeSpilling",
-			"Spill files",
-			"2016-05-31",
-			[]string{"orca", "legacy"},
-			func(n *Node) {
-				if n.SpillFile >= 1 {
-					n.Warnings = append(n.Warnings, Warning{
-						fmt.Sprintf("Total %d spilling segments found", n.SpillFile),
-						"Review query"})
+			CheckInfo: CheckInfo{
+				Id:            "spill-files",
+				Name:          "checkNodeSpilling",
+				Description:   "Spill files",
+				CreatedAt:     "2016-05-31",
+				Scope:         []string{"orca", "legacy"},
+				Dialects:      []string{DialectGreenplum, DialectHawq},
+				Category:      CheckCategoryMemory,
+				Severity:      SeverityWarning,
+				Documentation: "The operator did not have enough memory and wrote to workfiles on disk. Increasing statement_mem or reducing the rows processed avoids the extra I/O.",
+			},
+			Exec: func(n *Node, f *Findings) {
+				if n.SpillFile.Value >= 1 {
+					f.AddNodeWarning(n, Warning{
+						Cause:       fmt.Sprintf("Total %d spilling segments found%s", n.SpillFile.Value, spillDetails(n)),
+						Resolution:  "Increase statement_mem or reduce the rows processed by the operator",
+						Remediation: statementMemRemediation(f.Explain, n)})
 				}
 			}},
 		NodeCheck{
-			"checkNodeScans",
-			"Node looping multiple times",
-			"2016-05-31",
-			[]string{"orca", "legacy"},
-			func(n *Node) {
-				if n.Scans > 1 {
-					n.Warnings = append(n.Warnings, Warning{
-						fmt.Sprintf("This node is executed %d times", n.Scans),
-						"Review query"})
+			CheckInfo: CheckInfo{
+				Id:            "node-rescanned",
+				Name:          "checkNodeScans",
+				Description:   "Node looping multiple times",
+				CreatedAt:     "2016-05-31",
+				Scope:         []string{"orca", "legacy"},
+				Dialects:      []string{DialectGreenplum, DialectHawq},
+				Category:      CheckCategoryExecution,
+				Severity:      SeverityWarning,
+				Documentation: "The node was executed many times, usually as the inner side of a Nested Loop or in a SubPlan. Its time is multiplied by the number of scans.",
+			},
+			Exec: func(n *Node, f *Findings) {
+				if n.Scans.Value > 1 {
+					f.AddNodeWarning(n, Warning{
+						Cause:      fmt.Sprintf("This node is executed %d times", n.Scans.Value),
+						Resolution: "Review query"})
 				}
 			}},
 		NodeCheck{
-			"checkNodePartitionScans",
-			"Number of partition scans greater than 100 or 25%%",
-			"2016-05-31",
-			[]string{"orca", "legacy"},
-			func(n *Node) {
-				partitionThreshold := int64(100)
-				partitionPrctThreshold := int64(25)
-
-				// Planner
-				re := regexp.MustCompile(`Append`)
-				if re.MatchString(n.Operator) {
-					// Warn if the Append node has more than 100 subnodes
-					if int64(len(n.SubNodes)) >= partitionThreshold {
-						n.Warnings = append(n.Warnings, Warning{
-							fmt.Sprintf("Detected %d partition scans", len(n.SubNodes)),
-							"Check if partitions can be eliminated"})
-					}
-				}
-
-				// ORCA
-
-				// SELECTED
-				re = regexp.MustCompile(`Partition Selector`)
-				if re.MatchString(n.Operator) && n.PartSelected > -1 {
-					// Warn if selected partitions is great than 100
-					if n.PartSelected >= partitionThreshold {
-						n.Warnings = append(n.Warnings, Warning{
-							fmt.Sprintf("Detected %d partition scans", n.PartSelected),
-							"Check if partitions can be eliminated"})
-					}
-
-					// Warn if selected partitons is 0, may be an issue
-					if n.PartSelected == 0 {
-						n.Warnings = append(n.Warnings, Warning{
-							"Zero partitions selected",
-							"Review query"})
-						// Also warn if greater than 25% of total partitions were selected.
-						// I just chose 25% for now... may need to be adjusted to a more reasonable value
-					} else if (n.PartSelected * 100 / n.PartSelectedTotal) >= partitionPrctThreshold {
-						n.Warnings = append(n.Warnings, Warning{
-							fmt.Sprintf("%d%% (%d out of %d) partitions selected", (n.PartSelected * 100 / n.PartSelectedTotal), n.PartSelected, n.PartSelectedTotal),
-							"Check if partitions can be eliminated"})
-					}
-				}
-
-				// SCANNED
-				re = regexp.MustCompile(`Dynamic Table Scan`)
-				if re.MatchString(n.Operator) && n.PartScanned > -1 {
-					// Warn if scanned partitions is great than 100
-					if n.PartScanned >= partitionThreshold {
-						n.Warnings = append(n.Warnings, Warning{
-							fmt.Sprintf("Detected %d partition scans", n.PartScanned),
-							"Check if partitions can be eliminated"})
-					}
-
-					// Warn if scanned partitons is 0, may be an issue
-					if n.PartScanned == 0 {
-						n.Warnings = append(n.Warnings, Warning{
-							"Zero partitions scanned",
-							"Review query"})
-						// Also warn if greater than 25% of total partitions were scanned.
-						// I just chose 25% for now... may need to be adjusted to a more reasonable value
-					} else if (n.PartScanned * 100 / n.PartScannedTotal) >= partitionPrctThreshold {
-						n.Warnings = append(n.Warnings, Warning{
-							fmt.Sprintf("%d%% (%d out of %d) partitions scanned", (n.PartScanned * 100 / n.PartScannedTotal), n.PartScanned, n.PartScannedTotal),
-							"Check if partitions can be eliminated"})
-					}
-				}
-			}},
-		NodeCheck{
-			"checkNodeDataSkew",
-			"Data skew",
-			"2016-06-02",
-			[]string{"orca", "legacy"},
-			func(n *Node) {
-				threshold := 10000.0
+			CheckInfo: CheckInfo{
+				Id:            "data-skew",
+				Name:          "checkNodeDataSkew",
+				Description:   "Data skew",
+				CreatedAt:     "2016-06-02",
+				Scope:         []string{"orca", "legacy"},
+				Dialects:      []string{DialectGreenplum, DialectHawq},
+				Category:      CheckCategorySkew,
+				Severity:      SeverityWarning,
+				Documentation: "One segment processed far more rows than the others so the query runs at the speed of that segment. Review the distribution key of the tables involved.",
+				Parameters:    []string{"data_skew_min_rows"},
+			},
+			Exec: func(n *Node, f *Findings) {
+				threshold := f.Params.Float("data_skew_min_rows")
 
 				// Only proceed if over threshold
-				if n.ActualRows >= threshold || n.AvgRows >= threshold {
+				if n.ActualRows.Value >= threshold || n.AvgRows.Value >= threshold {
 					// Handle AvgRows
-					if n.AvgRows > 0 {
+					if n.AvgRows.Value > 0 {
 						// A segment has more than 50% of all rows
 						// Only do this if workers > 2 otherwise this situation will report skew:
 						//     Rows out:  Avg 500000.0 rows x 2 workers.  Max 500001 rows (seg0)
 						// but seg0 only has 1 extra row
-						if (n.MaxRows > (n.AvgRows * float64(n.Workers) / 2.0)) && n.Workers > 2 {
-							n.Warnings = append(n.Warnings, Warning{
-								fmt.Sprintf("Data skew on segment %s", n.MaxSeg),
-								"Review query"})
+						if (n.MaxRows.Value > (n.AvgRows.Value * float64(n.Workers.Value) / 2.0)) && n.Workers.Value > 2 {
+							f.AddNodeWarning(n, Warning{
+								Cause:      fmt.Sprintf("Data skew on segment %s", n.MaxSeg),
+								Resolution: "Review query"})
 						}
 						// Handle ActualRows
 						// If ActualRows is set and MaxSeg is set then this
 						// segment has the highest rows
-					} else if n.ActualRows > 0 && n.MaxSeg != "-" {
-						n.Warnings = append(n.Warnings, Warning{
-							fmt.Sprintf("Data skew on segment %s", n.MaxSeg),
-							"Review query"})
+					} else if n.ActualRows.Value > 0 && n.MaxSeg != "" {
+						f.AddNodeWarning(n, Warning{
+							Cause:      fmt.Sprintf("Data skew on segment %s", n.MaxSeg),
+							Resolution: "Review query"})
 					}
 				}
 			}},
 		NodeCheck{
-			"checkNodeFilterWithFunction",
-			"Filter clause using function",
-			"2016-06-06",
-			[]string{"orca", "legacy"},
+			CheckInfo: CheckInfo{
+				Id:            "filter-function",
+				Name:          "checkNodeFilterWithFunction",
+				Description:   "Filter clause using function",
+				CreatedAt:     "2016-06-06",
+				Scope:         []string{"orca", "legacy"},
+				Dialects:      []string{DialectGreenplum, DialectHawq},
+				Category:      CheckCategoryPredicates,
+				Severity:      SeverityInfo,
+				Documentation: "A function applied to a column in a filter is evaluated for every row and prevents the use of indexes and partition elimination.",
+			},
 			// Example:
 			//     upper(brief_status::text) = ANY ('{SIGNED,BRIEF,PROPO}'::text[])
 			//
-			func(n *Node) {
+			Exec: func(n *Node, f *Findings) {
 				re := regexp.MustCompile(`\S+\(.*\) `)
 
 				if re.MatchString(n.Filter) {
-					n.Warnings = append(n.Warnings, Warning{
-						"Filter using function",
-						"Check if function can be avoided"})
+					f.AddNodeWarning(n, Warning{
+						Cause:      "Filter using function",
+						Resolution: "Check if function can be avoided"})
+				}
+			}},
+		NodeCheck{
+			CheckInfo: CheckInfo{
+				Id:            "row-misestimate",
+				Name:          "checkNodeRowMisestimate",
+				Description:   "Actual rows differ from estimated rows by a large factor",
+				CreatedAt:     "2026-10-19",
+				Scope:         []string{"orca", "legacy"},
+				Dialects:      []string{DialectGreenplum, DialectHawq},
+				Category:      CheckCategoryStatistics,
+				Severity:      SeverityWarning,
+				Documentation: "The actual rows per segment differ from the estimate by misestimate_factor or more, and the estimate or the actual rows are misestimate_min_rows or more. Plans built on wrong estimates pick the wrong join and motion types. A misestimate carried up from a child misestimated at least as much in the same direction is only reported on the child.",
+				Parameters:    []string{"misestimate_factor", "misestimate_min_rows"},
+			},
+			Exec: func(n *Node, f *Findings) {
+				if n.QError.Valid == false || n.QError.Value < f.Params.Float("misestimate_factor") {
+					return
+				}
+				minRows := f.Params.Float("misestimate_min_rows")
+				if float64(n.Rows) < minRows && n.ActualRowsPerSeg.Value < minRows {
+					return
+				}
+				if n.inheritsMisestimate() == true {
+					return
 				}
+
+				direction := "overestimated"
+				if n.IsUnderestimated() == true {
+					direction = "underestimated"
+				}
+
+				resolution := "Review statistics and predicates of this node"
+				remediation := []string{}
+				if n.Object != "" {
+					resolution = fmt.Sprintf("Check statistics are up to date on \"%s\"", n.Object)
+				}
+				if table := n.TableName(); table != "" {
+					remediation = append(remediation, analyzeStatement(table))
+				}
+
+				f.AddNodeWarning(n, Warning{
+					Cause:       fmt.Sprintf("Rows %s by %.0fx (estimated %d, actual %s per segment)", direction, n.QError.Value, n.Rows, n.ActualRowsPerSeg),
+					Resolution:  resolution,
+					Remediation: remediation})
 			}},
 	}
 
@@ -356,55 +404,79 @@ var (
 	// ------------------------------------------------------------
 	EXPLAINCHECKS = []ExplainCheck{
 		ExplainCheck{
-			"checkExplainMotionCount",
-			"Number of Broadcast/Redistribute Motion nodes greater than 5",
-			"2016-05-23",
-			[]string{"orca", "legacy"},
-			func(e *Explain) {
-				motionCount := 0
-				motionCountLimit := 5
-
-				re := regexp.MustCompile(`(Broadcast|Redistribute) Motion`)
+			CheckInfo: CheckInfo{
+				Id:            "motion-count",
+				Name:          "checkExplainMotionCount",
+				Description:   "Number of Broadcast/Redistribute Motion nodes greater than 5",
+				CreatedAt:     "2016-05-23",
+				Scope:         []string{"orca", "legacy"},
+				Dialects:      []string{DialectGreenplum, DialectHawq},
+				Category:      CheckCategoryComplexity,
+				Severity:      SeverityInfo,
+				Documentation: "Each Redistribute or Broadcast Motion moves data between segments over the interconnect. Many motions often point to tables distributed on columns not used in joins.",
+				Parameters:    []string{"motion_count", "distribution_min_mb"},
+			},
+			Exec: func(e *Explain, f *Findings) {
+				motionCount := int64(0)
+				motionCountLimit := f.Params.Int("motion_count")
+				motionBytes := ByteSize(0)
 
 				for _, n := range e.Nodes {
-					if re.MatchString(n.Operator) {
+					if n.IsType(NodeTypeBroadcastMotion, NodeTypeRedistributeMotion, NodeTypeExplicitRedistributeMotion) {
 						motionCount++
+						motionBytes += n.MotionBytes().Value
 					}
 				}
 
 				if motionCount >= motionCountLimit {
-					e.Warnings = append(e.Warnings, Warning{
-						fmt.Sprintf("Found %d Redistribute/Broadcast motions", motionCount),
-						"Review query"})
+					f.AddWarning(Warning{
+						Cause:       fmt.Sprintf("Found %d Redistribute/Broadcast motions moving an estimated %s", motionCount, motionBytes),
+						Resolution:  "Review query",
+						Remediation: distributionRemediation(e, f.Params)})
 				}
 			}},
 		ExplainCheck{
-			"checkExplainSliceCount",
-			"Number of slices greater than 100",
-			"2016-05-31",
-			[]string{"orca", "legacy"},
-			func(e *Explain) {
-				sliceCount := 0
-				sliceCountLimit := 100
+			CheckInfo: CheckInfo{
+				Id:            "slice-count",
+				Name:          "checkExplainSliceCount",
+				Description:   "Number of slices greater than 100",
+				CreatedAt:     "2016-05-31",
+				Scope:         []string{"orca", "legacy"},
+				Dialects:      []string{DialectGreenplum, DialectHawq},
+				Category:      CheckCategoryComplexity,
+				Severity:      SeverityWarning,
+				Documentation: "Every slice starts a process on each segment. A large number of slices uses many connections and processes and can exhaust resources.",
+				Parameters:    []string{"slice_count"},
+			},
+			Exec: func(e *Explain, f *Findings) {
+				sliceCount := int64(0)
+				sliceCountLimit := f.Params.Int("slice_count")
 
 				for _, n := range e.Nodes {
-					if n.Slice > -1 {
+					if n.Slice.Valid {
 						sliceCount++
 					}
 				}
 
 				if sliceCount > sliceCountLimit {
-					e.Warnings = append(e.Warnings, Warning{
-						fmt.Sprintf("Found %d slices", sliceCount),
-						"Review query"})
+					f.AddWarning(Warning{
+						Cause:      fmt.Sprintf("Found %d slices", sliceCount),
+						Resolution: "Review query"})
 				}
 			}},
 		ExplainCheck{
-			"checkExplainPlannerFallback",
-			"ORCA fallback to legacy query planner",
-			"2016-05-31",
-			[]string{"orca"},
-			func(e *Explain) {
+			CheckInfo: CheckInfo{
+				Id:            "planner-fallback",
+				Name:          "checkExplainPlannerFallback",
+				Description:   "ORCA fallback to legacy query planner",
+				CreatedAt:     "2016-05-31",
+				Scope:         []string{"orca"},
+				Dialects:      []string{DialectGreenplum, DialectHawq},
+				Category:      CheckCategoryOptimizer,
+				Severity:      SeverityWarning,
+				Documentation: "ORCA is enabled but could not plan the query so the legacy planner was used. The plan may differ from what ORCA would produce.",
+			},
+			Exec: func(e *Explain, f *Findings) {
 				// Settings:  optimizer=on
 				// Optimizer status: legacy query optimizer
 				re := regexp.MustCompile(`legacy query optimizer`)
@@ -412,34 +484,30 @@ var (
 				if re.MatchString(e.OptimizerStatus) {
 					for _, s := range e.Settings {
 						if s.Name == "optimizer" && s.Value == "on" {
-							e.Warnings = append(e.Warnings, Warning{
-								"ORCA enabled but plan was produced by legacy query optimizer",
-								"No Action Required"})
+							f.AddWarning(Warning{
+								Cause:      "ORCA enabled but plan was produced by legacy query optimizer",
+								Resolution: "No Action Required"})
 							break
 						}
 					}
 				}
 			}},
 		ExplainCheck{
-			"checkExplainEnableGucNonDefault",
-			"\"enable_\" GUCs configured with non-default values",
-			"2016-06-06",
-			[]string{"orca", "legacy"},
-			func(e *Explain) {
-				// Default GUC values.
-				// http://gpdb.docs.pivotal.io/4340/guc_config-topic3.html
-				defaults := map[string]string{
-					"enable_bitmapscan": "on",
-					"enable_groupagg":   "on",
-					"enable_hashagg":    "on",
-					"enable_hashjoin":   "on",
-					"enable_indexscan":  "on",
-					"enable_seqscan":    "on",
-					"enable_sort":       "on",
-					"enable_tidscan":    "on",
-					"enable_nestloop":   "off",
-					"enable_mergejoin":  "off",
-				}
+			CheckInfo: CheckInfo{
+				Id:            "enable-guc-non-default",
+				Name:          "checkExplainEnableGucNonDefault",
+				Description:   "\"enable_\" GUCs configured with non-default values",
+				CreatedAt:     "2016-06-06",
+				Scope:         []string{"orca", "legacy"},
+				Dialects:      []string{DialectGreenplum, DialectHawq},
+				Category:      CheckCategorySettings,
+				Severity:      SeverityWarning,
+				Documentation: "An enable_ setting has been changed from its default, which restricts the plans the optimizer can choose. It is usually left over from testing.",
+				Parameters:    []string{"enable_*"},
+			},
+			Exec: func(e *Explain, f *Findings) {
+				// Default GUC values, see ENABLEGUCDEFAULTS
+				defaults := f.Params.EnableGucs
 
 				// Settings:  enable_hashjoin=off; enable_indexscan=off; join_collapse_limit=1; optimizer=on
 				re := regexp.MustCompile(`enable_`)
@@ -449,43 +517,52 @@ var (
 						if value, ok := defaults[s.Name]; ok {
 							// Only report if NOT default value
 							if s.Value != value {
-								e.Warnings = append(e.Warnings, Warning{
-									fmt.Sprintf("\"%s\" GUC has non-default value \"%s\"", s.Name, s.Value),
-									fmt.Sprintf("Check if \"%s\" GUC is required", s.Name)})
+								f.AddWarning(Warning{
+									Cause:       fmt.Sprintf("\"%s\" GUC has non-default value \"%s\"", s.Name, s.Value),
+									Resolution:  fmt.Sprintf("Check if \"%s\" GUC is required", s.Name),
+									Remediation: []string{fmt.Sprintf("SET %s = %s;", s.Name, value)}})
 							}
 						}
 					}
 				}
 			}},
 		ExplainCheck{
-			"checkExplainOrcaChildPartitionScan",
-			"Scan on child partition instead of root partition",
-			"2016-06-08",
-			[]string{"orca"},
-			func(e *Explain) {
-
-				// Skip if using legacy
-				if e.Optimizer != "on" {
+			CheckInfo: CheckInfo{
+				Id:            "orca-child-partition-scan",
+				Name:          "checkExplainOrcaChildPartitionScan",
+				Description:   "Scan on child partition instead of root partition",
+				CreatedAt:     "2016-06-08",
+				Scope:         []string{"orca"},
+				Dialects:      []string{DialectGreenplum, DialectHawq},
+				Category:      CheckCategoryPartitioning,
+				Severity:      SeverityInfo,
+				Documentation: "ORCA plans partitioned tables best when the root partition is queried. Querying a child partition directly is planned as a regular table.",
+			},
+			Exec: func(e *Explain, f *Findings) {
+
+				// Skip if using legacy, or ORCA fell back to legacy which
+				// scans the child partitions of the root partition
+				if e.Optimizer != "on" || strings.Contains(e.OptimizerStatus, "legacy query optimizer") {
 					return
 				}
 
 				// ->  Seq Scan on sales_1_prt_outlying_years s  (cost=0.00..55276.72 rows=2476236 width=8)
 				// ->  Seq Scan on sales_1_prt_2 s  (cost=0.00..38.44 rows=1722 width=8)
-				re := regexp.MustCompile(`_[0-9]+_prt_`)
-
 				for _, n := range e.Nodes {
-					// Check if object name looks like partition
-					if re.MatchString(n.Operator) {
-						n.Warnings = append(n.Warnings, Warning{
-							fmt.Sprintf("Scan on what appears to be a child partition"),
-							fmt.Sprintf("Recommend using root partition when ORCA is enabled")})
+					// Check if the table scanned looks like a partition
+					if n.Category == CategoryScan && partitionLevelPattern.MatchString(n.Object) {
+						f.AddNodeWarning(n, Warning{
+							Cause:      fmt.Sprintf("Scan on what appears to be a child partition"),
+							Resolution: fmt.Sprintf("Recommend using root partition when ORCA is enabled")})
 					}
 				}
 			}},
 	}
 
-	indentDepth  = 4  // Used for printing the plan
-	warningColor = 31 // RED
+	indentDepth       = 4  // Used for printing the plan
+	warningColor      = 31 // RED
+	criticalPathColor = 33 // YELLOW
+	diagnosticColor   = 35 // MAGENTA
 )
 
 func logDebugf(format string, v ...interface{}) {
@@ -525,13 +602,32 @@ func parseNodeExtraInfo(n *Node) error {
 		sliceGroups := patterns["SLICE"].FindStringSubmatch(groups[1])
 		if len(sliceGroups) == 3 {
 			n.Operator = strings.TrimSpace(sliceGroups[1])
-			n.Slice, _ = strconv.ParseInt(strings.TrimSpace(sliceGroups[2]), 10, 64)
+			if s, err := strconv.ParseInt(strings.TrimSpace(sliceGroups[2]), 10, 64); err == nil {
+				n.Slice = validInt(s)
+			}
+
+			// Gather Motion 2:1  (slice1; segments: 2)
+			// Fall back to the number of senders if segments is not shown
+			n.Segments = OptionalInt{}
+			if m := patterns["SEGMENTS"].FindStringSubmatch(groups[1]); len(m) == 2 {
+				if s, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+					n.Segments = validInt(s)
+				}
+			} else if m := patterns["MOTION"].FindStringSubmatch(n.Operator); len(m) == 3 {
+				if s, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+					n.Segments = validInt(s)
+				}
+			}
 			// Else it's just the operator
 		} else {
 			n.Operator = strings.TrimSpace(groups[1])
-			n.Slice = -1
+			n.Slice = OptionalInt{}
+			n.Segments = OptionalInt{}
 		}
 
+		// Classify the operator so checks don't have to match on text
+		classifyNode(n)
+
 		// Try to get object name if this is a scan node
 		// Look for non index scans
 		re := regexp.MustCompile(`(Index ){0,0} Scan (on|using) (\S+)`)
@@ -559,25 +655,31 @@ func parseNodeExtraInfo(n *Node) error {
 		return errors.New("Unable to parse node")
 	}
 
-	// Init everything to -1
-	n.ActualRows = -1
-	n.AvgRows = -1
-	n.Workers = -1
-	n.MaxRows = -1
-	n.MaxSeg = "-"
-	n.Scans = -1
-	n.MsFirst = -1
-	n.MsEnd = -1
-	n.MsOffset = -1
-	n.AvgMem = -1
-	n.MaxMem = -1
-	n.ExecMemLine = -1
-	n.SpillFile = -1
-	n.SpillReuse = -1
-	n.PartSelected = -1
-	n.PartSelectedTotal = -1
-	n.PartScanned = -1
-	n.PartScannedTotal = -1
+	// Init everything to not present
+	n.ActualRows = OptionalCount{}
+	n.AvgRows = OptionalCount{}
+	n.Workers = OptionalInt{}
+	n.MaxRows = OptionalCount{}
+	n.MaxSeg = ""
+	n.Scans = OptionalInt{}
+	n.MsFirst = OptionalDuration{}
+	n.MsEnd = OptionalDuration{}
+	n.MsOffset = OptionalDuration{}
+	n.AvgMem = OptionalBytes{}
+	n.MaxMem = OptionalBytes{}
+	n.WorkMemWanted = OptionalBytes{}
+	n.ExecMemLine = OptionalBytes{}
+	n.SpillFile = OptionalInt{}
+	n.SpillReuse = OptionalInt{}
+	n.WorkfileBytes = OptionalBytes{}
+	n.WorkMemSeg = ""
+	n.ExecMemAvg = OptionalBytes{}
+	n.ExecMemMax = OptionalBytes{}
+	n.ExecMemSeg = ""
+	n.PartSelected = OptionalInt{}
+	n.PartSelectedTotal = OptionalInt{}
+	n.PartScanned = OptionalInt{}
+	n.PartScannedTotal = OptionalInt{}
 	n.Filter = ""
 	n.IsAnalyzed = false
 
@@ -596,8 +698,8 @@ func parseNodeExtraInfo(n *Node) error {
 			m := re.FindStringSubmatch(line)
 			if len(m) == re.NumSubexp()+1 {
 				if s, err := strconv.ParseFloat(m[1], 64); err == nil {
-					n.ActualRows = s
-					logDebugf("ActualRows %f\n", n.ActualRows)
+					n.ActualRows = validCount(s)
+					logDebugf("ActualRows %s\n", n.ActualRows)
 				}
 			}
 
@@ -605,8 +707,8 @@ func parseNodeExtraInfo(n *Node) error {
 			m = re.FindStringSubmatch(line)
 			if len(m) == re.NumSubexp()+1 {
 				if s, err := strconv.ParseFloat(m[1], 64); err == nil {
-					n.ActualRows = s
-					logDebugf("ActualRows %f\n", n.ActualRows)
+					n.ActualRows = validCount(s)
+					logDebugf("ActualRows %s\n", n.ActualRows)
 				}
 			}
 
@@ -614,35 +716,35 @@ func parseNodeExtraInfo(n *Node) error {
 			m = re.FindStringSubmatch(line)
 			if len(m) == re.NumSubexp()+1 {
 				if s, err := strconv.ParseFloat(m[1], 64); err == nil {
-					n.MaxRows = s
-					logDebugf("MaxRows %f\n", n.MaxRows)
+					n.MaxRows = validCount(s)
+					logDebugf("MaxRows %s\n", n.MaxRows)
 				}
 			}
 
 			re = regexp.MustCompile(` (\S+) ms to first row`)
 			m = re.FindStringSubmatch(line)
 			if len(m) == re.NumSubexp()+1 {
-				if s, err := strconv.ParseFloat(m[1], 64); err == nil {
-					n.MsFirst = s
-					logDebugf("MsFirst %f\n", n.MsFirst)
+				if s, err := parseMs(m[1]); err == nil {
+					n.MsFirst = validDuration(s)
+					logDebugf("MsFirst %s\n", n.MsFirst)
 				}
 			}
 
 			re = regexp.MustCompile(` (\S+) ms to end`)
 			m = re.FindStringSubmatch(line)
 			if len(m) == re.NumSubexp()+1 {
-				if s, err := strconv.ParseFloat(m[1], 64); err == nil {
-					n.MsEnd = s
-					logDebugf("MsEnd %f\n", n.MsEnd)
+				if s, err := parseMs(m[1]); err == nil {
+					n.MsEnd = validDuration(s)
+					logDebugf("MsEnd %s\n", n.MsEnd)
 				}
 			}
 
 			re = regexp.MustCompile(`start offset by (\S+) ms`)
 			m = re.FindStringSubmatch(line)
 			if len(m) == re.NumSubexp()+1 {
-				if s, err := strconv.ParseFloat(m[1], 64); err == nil {
-					n.MsOffset = s
-					logDebugf("MsOffset %f\n", n.MsOffset)
+				if s, err := parseMs(m[1]); err == nil {
+					n.MsOffset = validDuration(s)
+					logDebugf("MsOffset %s\n", n.MsOffset)
 				}
 			}
 
@@ -650,8 +752,8 @@ func parseNodeExtraInfo(n *Node) error {
 			m = re.FindStringSubmatch(line)
 			if len(m) == re.NumSubexp()+1 {
 				if s, err := strconv.ParseFloat(m[1], 64); err == nil {
-					n.AvgRows = s
-					logDebugf("AvgRows %f\n", n.AvgRows)
+					n.AvgRows = validCount(s)
+					logDebugf("AvgRows %s\n", n.AvgRows)
 				}
 			}
 
@@ -659,8 +761,8 @@ func parseNodeExtraInfo(n *Node) error {
 			m = re.FindStringSubmatch(line)
 			if len(m) == re.NumSubexp()+1 {
 				if s, err := strconv.ParseInt(m[1], 10, 64); err == nil {
-					n.Workers = s
-					logDebugf("Workers %d\n", n.Workers)
+					n.Workers = validInt(s)
+					logDebugf("Workers %s\n", n.Workers)
 				}
 			}
 
@@ -668,8 +770,8 @@ func parseNodeExtraInfo(n *Node) error {
 			m = re.FindStringSubmatch(line)
 			if len(m) == re.NumSubexp()+1 {
 				if s, err := strconv.ParseInt(m[1], 10, 64); err == nil {
-					n.Scans = s
-					logDebugf("Scans %d\n", n.Scans)
+					n.Scans = validInt(s)
+					logDebugf("Scans %s\n", n.Scans)
 				}
 			}
 
@@ -684,9 +786,9 @@ func parseNodeExtraInfo(n *Node) error {
 			m = re.FindStringSubmatch(line)
 			if len(m) == re.NumSubexp()+1 {
 				if s, err := strconv.ParseFloat(m[1], 64); err == nil {
-					n.MaxRows = s
+					n.MaxRows = validCount(s)
 				}
-				logDebugf("MaxRows %f\n", n.MaxRows)
+				logDebugf("MaxRows %s\n", n.MaxRows)
 
 			} else {
 				// Only execute this if "Max" was not found
@@ -694,9 +796,9 @@ func parseNodeExtraInfo(n *Node) error {
 				m = re.FindStringSubmatch(line)
 				if len(m) == re.NumSubexp()+1 {
 					if s, err := strconv.ParseFloat(m[1], 64); err == nil {
-						n.ActualRows = s
+						n.ActualRows = validCount(s)
 					}
-					logDebugf("ActualRows %f\n", n.ActualRows)
+					logDebugf("ActualRows %s\n", n.ActualRows)
 				}
 			}
 		}
@@ -707,40 +809,79 @@ func parseNodeExtraInfo(n *Node) error {
 			re = regexp.MustCompile(`Work_mem used:\s+(\d+)K bytes avg`)
 			m = re.FindStringSubmatch(line)
 			if len(m) == re.NumSubexp()+1 {
-				if s, err := strconv.ParseFloat(m[1], 64); err == nil {
-					n.AvgMem = s
-					logDebugf("AvgMem %f\n", n.AvgMem)
+				if s, err := ParseByteSize(m[1] + "K"); err == nil {
+					n.AvgMem = validBytes(s)
+					logDebugf("AvgMem %s\n", n.AvgMem)
 				}
 			}
 
-			re = regexp.MustCompile(`\s+(\d+)K bytes max`)
+			re = regexp.MustCompile(`\s+(\d+)K bytes max( \((seg\d+)\))?`)
 			m = re.FindStringSubmatch(line)
 			if len(m) == re.NumSubexp()+1 {
-				if s, err := strconv.ParseFloat(m[1], 64); err == nil {
-					n.MaxMem = s
-					logDebugf("MaxMem %f\n", n.MaxMem)
+				if s, err := ParseByteSize(m[1] + "K"); err == nil {
+					n.MaxMem = validBytes(s)
+					n.WorkMemSeg = m[3]
+					logDebugf("MaxMem %s\n", n.MaxMem)
 				}
 			}
 		}
 
+		// Executor memory:  33589K bytes avg, 33684K bytes max (seg6).
+		re = regexp.MustCompile(`Executor memory:\s+(\d+)K bytes avg,\s+(\d+)K bytes max \((seg\d+)\)`)
+		m = re.FindStringSubmatch(line)
+		if len(m) == re.NumSubexp()+1 {
+			avg, err1 := ParseByteSize(m[1] + "K")
+			max, err2 := ParseByteSize(m[2] + "K")
+			if err1 == nil && err2 == nil {
+				n.ExecMemAvg = validBytes(avg)
+				n.ExecMemMax = validBytes(max)
+				n.ExecMemSeg = m[3]
+				logDebugf("ExecMemAvg %s ExecMemMax %s\n", n.ExecMemAvg, n.ExecMemMax)
+			}
+		}
+
+		// Work_mem wanted: 171875K bytes avg, 171875K bytes max (seg0) to lessen workfile I/O affecting 2 workers.
+		re = regexp.MustCompile(`Work_mem wanted:\s+\d+K bytes avg,\s+(\d+)K bytes max`)
+		m = re.FindStringSubmatch(line)
+		if len(m) == re.NumSubexp()+1 {
+			if s, err := ParseByteSize(m[1] + "K"); err == nil {
+				n.WorkMemWanted = validBytes(s)
+				logDebugf("WorkMemWanted %s\n", n.WorkMemWanted)
+			}
+		}
+
 		// SPILL
 		re = regexp.MustCompile(`\((\d+) spilling,\s+(\d+) reused\)`)
 		m = re.FindStringSubmatch(line)
 		if len(m) == re.NumSubexp()+1 {
-			n.SpillFile, _ = strconv.ParseInt(strings.TrimSpace(m[1]), 10, 64)
-			n.SpillReuse, _ = strconv.ParseInt(strings.TrimSpace(m[2]), 10, 64)
-			logDebugf("SpillFile %d\n", n.SpillFile)
-			logDebugf("SpillReuse %d\n", n.SpillReuse)
+			spillFile, _ := strconv.ParseInt(strings.TrimSpace(m[1]), 10, 64)
+			spillReuse, _ := strconv.ParseInt(strings.TrimSpace(m[2]), 10, 64)
+			n.SpillFile = validInt(spillFile)
+			n.SpillReuse = validInt(spillReuse)
+			logDebugf("SpillFile %s\n", n.SpillFile)
+			logDebugf("SpillReuse %s\n", n.SpillReuse)
+		}
+
+		// (seg0)     Wrote 54032K bytes to inner workfile.
+		re = regexp.MustCompile(`Wrote (\d+)K bytes to .*workfile`)
+		m = re.FindStringSubmatch(line)
+		if len(m) == re.NumSubexp()+1 {
+			if s, err := ParseByteSize(m[1] + "K"); err == nil {
+				n.WorkfileBytes = validBytes(n.WorkfileBytes.Value + s)
+				logDebugf("WorkfileBytes %s\n", n.WorkfileBytes)
+			}
 		}
 
 		// PARTITION SELECTED
 		re = regexp.MustCompile(`Partitions selected:  (\d+) \(out of (\d+)\)`)
 		m = re.FindStringSubmatch(line)
 		if len(m) == re.NumSubexp()+1 {
-			n.PartSelected, _ = strconv.ParseInt(strings.TrimSpace(m[1]), 10, 64)
-			n.PartSelectedTotal, _ = strconv.ParseInt(strings.TrimSpace(m[2]), 10, 64)
-			logDebugf("PartSelectedTotal %d\n", n.PartSelectedTotal)
-			logDebugf("PartSelected %d\n", n.PartSelected)
+			partSelected, _ := strconv.ParseInt(strings.TrimSpace(m[1]), 10, 64)
+			partSelectedTotal, _ := strconv.ParseInt(strings.TrimSpace(m[2]), 10, 64)
+			n.PartSelected = validInt(partSelected)
+			n.PartSelectedTotal = validInt(partSelectedTotal)
+			logDebugf("PartSelectedTotal %s\n", n.PartSelectedTotal)
+			logDebugf("PartSelected %s\n", n.PartSelected)
 		}
 
 		// PARTITION SCANNED
@@ -748,10 +889,11 @@ func parseNodeExtraInfo(n *Node) error {
 		m = re.FindStringSubmatch(line)
 		if len(m) > 0 {
 			partScannedFloat, _ := strconv.ParseFloat(strings.TrimSpace(m[len(m)-2]), 64)
-			n.PartScanned = int64(partScannedFloat)
-			n.PartScannedTotal, _ = strconv.ParseInt(strings.TrimSpace(m[len(m)-1]), 10, 64)
-			logDebugf("PartScannedTotal %d\n", n.PartScannedTotal)
-			logDebugf("PartScanned %d\n", n.PartScanned)
+			partScannedTotal, _ := strconv.ParseInt(strings.TrimSpace(m[len(m)-1]), 10, 64)
+			n.PartScanned = validInt(int64(partScannedFloat))
+			n.PartScannedTotal = validInt(partScannedTotal)
+			logDebugf("PartScannedTotal %s\n", n.PartScannedTotal)
+			logDebugf("PartScanned %s\n", n.PartScanned)
 		}
 
 		// FILTER
@@ -773,7 +915,7 @@ func parseNodeExtraInfo(n *Node) error {
 	//     Show elapsed time just once if they are the same or if we don't have
 	//     any valid elapsed time for first tuple.
 	// So set it here to avoid having to handle it later
-	if n.MsFirst == -1 {
+	if n.MsFirst.Valid == false {
 		n.MsFirst = n.MsEnd
 	}
 
@@ -836,6 +978,21 @@ func (e *Explain) parseSettings(line string) {
 	}
 }
 
+// ------------------------------------------------------------
+// template1=# explain analyze select *
+// template1-# from sales;
+//
+// A new statement starts after "=#" or "=>", other prompts continue it
+func (e *Explain) parsePrompt(line string) {
+	logDebugf("parsePrompt\n")
+	groups := patterns["PROMPT"].FindStringSubmatch(strings.TrimRight(line, " \r"))
+	if groups[1] == "=" {
+		e.Query = groups[2]
+	} else {
+		e.Query += "\n" + groups[2]
+	}
+}
+
 // ------------------------------------------------------------
 // Slice statistics:
 //   (slice0) Executor memory: 2466K bytes.
@@ -865,18 +1022,22 @@ func (e *Explain) parseStatementStats(line string) {
 	logDebugf("parseStatementStats\n")
 	e.planFinished = true
 
-	e.MemoryUsed = -1
-	e.MemoryWanted = -1
+	e.MemoryUsed = OptionalBytes{}
+	e.MemoryWanted = OptionalBytes{}
 
 	for i := e.lineOffset + 1; i < len(e.lines); i++ {
 		if getIndent(e.lines[i]) > 1 {
 			logDebugf(e.lines[i])
 			if patterns["STATEMENTSTATS_USED"].MatchString(e.lines[i]) {
 				groups := patterns["STATEMENTSTATS_USED"].FindStringSubmatch(e.lines[i])
-				e.MemoryUsed, _ = strconv.ParseInt(strings.TrimSpace(groups[1]), 10, 64)
+				if s, err := ParseByteSize(strings.TrimSpace(groups[1]) + "K"); err == nil {
+					e.MemoryUsed = validBytes(s)
+				}
 			} else if patterns["STATEMENTSTATS_WANTED"].MatchString(e.lines[i]) {
 				groups := patterns["STATEMENTSTATS_WANTED"].FindStringSubmatch(e.lines[i])
-				e.MemoryWanted, _ = strconv.ParseInt(strings.TrimSpace(groups[1]), 10, 64)
+				if s, err := ParseByteSize(strings.TrimSpace(groups[1]) + "K"); err == nil {
+					e.MemoryWanted = validBytes(s)
+				}
 			}
 		} else {
 			e.lineOffset = i - 1
@@ -907,10 +1068,10 @@ func (e *Explain) parseRuntime(line string) {
 	e.planFinished = true
 	line = strings.TrimSpace(line)
 	temp := strings.Split(line, " ")
-	if s, err := strconv.ParseFloat(temp[2], 64); err == nil {
-		e.Runtime = s
+	if s, err := parseMs(temp[2]); err == nil {
+		e.Runtime = validDuration(s)
 	}
-	logDebugf("\t%f\n", e.Runtime)
+	logDebugf("\t%s\n", e.Runtime)
 }
 
 // Parse all the lines in to empty structs with only ExtraInfo populated
@@ -961,6 +1122,9 @@ func (e *Explain) parseline(line string) error {
 	if len(strings.TrimSpace(line)) == 0 || strings.Index(line, "QUERY PLAN") > -1 || line[:1] == "-" {
 		logDebugf("SKIPPING\n")
 
+	} else if len(e.Nodes) == 0 && patterns["PROMPT"].MatchString(line) {
+		e.parsePrompt(line)
+
 	} else if patterns["NODE"].MatchString(line) {
 		// Parse a new node
 		newNode := e.createNode(line)
@@ -1106,50 +1270,61 @@ func (e *Explain) BuildTree() {
 		}
 	}
 
+	// Set parent pointers and paths now the tree is complete
+	e.linkTree()
+
 	logDebugf("########## END BUILD TREE ##########\n")
 }
 
+// Calculate the cost and time of the node excluding its children.
+// Time requires StartTime/EndTime which are populated by Analyze()
+// A negative cost is clamped to 0 and reported by Validate(), see consistency.go
 func (n *Node) CalculateSubNodeDiff() {
-	msChild := 0.0
-	costChild := 0.0
-	for _, s := range n.SubNodes {
-		//logDebugf("\tSUBNODE%s", s.Operator)
-		msChild += s.MsEnd
-		costChild += s.TotalCost
-	}
-
-	for _, s := range n.SubPlans {
-		//logDebugf("\tSUBPLANNODE%s", s.TopNode.Operator)
-		costChild += s.TopNode.TotalCost
-	}
-
-	n.MsNode = n.MsEnd - msChild
-	n.NodeCost = n.TotalCost - costChild
-
-	if n.MsNode < 0 {
-		n.MsNode = 0
-	}
+	n.calculateSelfTime()
+	n.NodeCost = n.TotalCost - n.childCost()
 
 	if n.NodeCost < 0 {
 		n.NodeCost = 0
 	}
 }
 
-func (n *Node) CalculatePercentage(totalCost float64, totalMs float64) {
+func (n *Node) CalculatePercentage(totalCost float64, totalTime OptionalDuration) {
 	n.PrctCost = n.NodeCost / totalCost * 100
-	n.MsPrct = n.MsNode / totalMs * 100
+	n.MsPrct = 0
+	if totalTime.Value > 0 {
+		n.MsPrct = float64(n.MsNode.Value) / float64(totalTime.Value) * 100
+	}
 }
 
-// Render node for output to console
+// Render node and all nodes below it for output to console
 func (n *Node) Render(indent int) {
-	indent += 1
+	n.Walk(func(s *Node) error {
+		// SubPlan name is printed above its top node
+		if s != n && s.Plan != nil {
+			indent += 1
+			fmt.Printf("%s%s\n", strings.Repeat(" ", indent*indentDepth), s.Plan.Name)
+		}
+		indent += 1
+		s.renderLine(indent)
+		return nil
+	}, func(s *Node) error {
+		indent -= 1
+		if s != n && s.Plan != nil {
+			indent -= 1
+		}
+		return nil
+	})
+}
+
+// Render a single node for output to console
+func (n *Node) renderLine(indent int) {
 	indentString := strings.Repeat(" ", indent*indentDepth)
 
-	if n.Slice > -1 {
-		fmt.Printf("\n%s   // Slice %d\n", indentString, n.Slice)
+	if n.Slice.Valid {
+		fmt.Printf("\n%s   // Slice %d\n", indentString, n.Slice.Value)
 	}
 
-	fmt.Printf("%s-> %s | startup cost %s | total cost %s | rows %d | width %d\n",
+	fmt.Printf("%s-> %s | startup cost %.2f | total cost %.2f | rows %d | width %d\n",
 		indentString,
 		n.Operator,
 		n.StartupCost,
@@ -1165,18 +1340,14 @@ func (n *Node) Render(indent int) {
 	// Render warnings
 	for _, w := range n.Warnings {
 		fmt.Printf("\x1b[%dm", warningColor)
-		fmt.Printf("%s   WARNING: %s | %s\n", indentString, w.Cause, w.Resolution)
+		fmt.Printf("%s   %s: %s | %s [%s]\n", indentString, strings.ToUpper(w.Severity.String()), w.Cause, w.Resolution, w.CheckId)
 		fmt.Printf("\x1b[%dm", 0)
 	}
 
-	// Render sub nodes
-	for _, s := range n.SubNodes {
-		s.Render(indent)
-	}
-
-	// Render sub plans
-	for _, s := range n.SubPlans {
-		s.Render(indent)
+	if n.OnCriticalPath == true {
+		fmt.Printf("\x1b[%dm", criticalPathColor)
+		fmt.Printf("%s   CRITICAL PATH\n", indentString)
+		fmt.Printf("\x1b[%dm", 0)
 	}
 }
 
@@ -1189,9 +1360,83 @@ func (p *Plan) Render(indent int) {
 	p.TopNode.Render(indent)
 }
 
+// Render summary for output to console
+func (e *Explain) printSummary() {
+	s := e.Summary
+
+	// Diagnostics come first as they affect everything below
+	if len(e.Diagnostics) > 0 {
+		fmt.Printf("\x1b[%dm", diagnosticColor)
+		fmt.Printf("Plan diagnostics: the plan looks damaged or edited, the analysis may be wrong\n")
+		for _, d := range e.Diagnostics {
+			node := ""
+			if d.Node != nil {
+				node = fmt.Sprintf("#%d %s | ", d.Node.Id, d.Node.Operator)
+			}
+			fmt.Printf("\t%s%s [%s]\n", node, d.Message, d.Kind)
+		}
+		fmt.Printf("\x1b[%dm", 0)
+	}
+
+	fmt.Println("Summary:")
+	fmt.Printf("\tHealth score: %d (%s)\n", s.Score, s.Rating())
+	fmt.Printf("\tWarnings: %d (%d critical, %d warning, %d info)\n",
+		s.Warnings,
+		s.WarningsBySeverity[SeverityCritical],
+		s.WarningsBySeverity[SeverityWarning],
+		s.WarningsBySeverity[SeverityInfo])
+	if s.Suppressed > 0 {
+		fmt.Printf("\tSuppressed: %d\n", s.Suppressed)
+	}
+	if fingerprint := e.QueryFingerprint(); fingerprint != "" {
+		fmt.Printf("\tQuery fingerprint: %s\n", fingerprint)
+	}
+	fmt.Printf("\tSlices: %d | Motions: %d\n", s.Slices, s.Motions)
+	if s.SpillNodes > 0 {
+		fmt.Printf("\tSpill: %s\n", s.SpillDescription())
+	}
+	if s.PeakMemory.Valid {
+		fmt.Printf("\tPeak memory: %s in slice %s %s\n", s.PeakMemory, s.PeakMemorySlice, s.PeakMemorySegment)
+	}
+	if s.Skew.SkewedNodes > 0 {
+		fmt.Printf("\tSkew: %s, %s the busiest in %d\n", plural(s.Skew.SkewedNodes, "skewed node"), s.Skew.Straggler, s.Skew.StragglerNodes)
+	}
+	if s.CoordinatorTime.Valid {
+		fmt.Printf("\tCoordinator time: %s (%.0f%% of runtime)\n", s.CoordinatorTime, s.CoordinatorPercent)
+	}
+	if len(s.PartitionedTables) > 0 {
+		fmt.Println("\tPartitioned tables:")
+		for _, t := range s.PartitionedTables {
+			fmt.Printf("\t\t%s\n", t)
+		}
+	}
+	if len(s.TableStatistics) > 0 {
+		fmt.Println("\tTable statistics:")
+		for _, t := range s.TableStatistics {
+			fmt.Printf("\t\t%s\n", t)
+		}
+	}
+	if len(s.TopNodesByTime) > 0 {
+		fmt.Println("\tTop nodes by time:")
+		for _, n := range s.TopNodesByTime {
+			fmt.Printf("\t\t#%d | %s | %s\n", n.Id, n.MsNode, n.Operator)
+		}
+	}
+	if len(s.TopNodesByCost) > 0 {
+		fmt.Println("\tTop nodes by cost:")
+		for _, n := range s.TopNodesByCost {
+			fmt.Printf("\t\t#%d | %.2f | %s\n", n.Id, n.NodeCost, n.Operator)
+		}
+	}
+
+	fmt.Printf("\n")
+}
+
 // Render explain for output to console
 func (e *Explain) PrintPlan() {
 
+	e.printSummary()
+
 	fmt.Println("Plan:")
 	e.Plans[0].TopNode.Render(0)
 
@@ -1199,13 +1444,43 @@ func (e *Explain) PrintPlan() {
 		fmt.Printf("\n")
 		for _, w := range e.Warnings {
 			fmt.Printf("\x1b[%dm", warningColor)
-			fmt.Printf("WARNING: %s | %s\n", w.Cause, w.Resolution)
+			fmt.Printf("%s: %s | %s [%s]\n", strings.ToUpper(w.Severity.String()), w.Cause, w.Resolution, w.CheckId)
 			fmt.Printf("\x1b[%dm", 0)
 		}
 	}
 
 	fmt.Printf("\n")
 
+	if len(e.Suppressed) > 0 {
+		fmt.Println("Suppressed warnings:")
+		for _, w := range e.Suppressed {
+			node := ""
+			if w.Node != nil {
+				node = fmt.Sprintf("#%d %s | ", w.Node.Id, w.Node.Operator)
+			}
+			fmt.Printf("\t%s%s | %s [%s] waived by %s: %s\n", node, w.Cause, w.Resolution, w.CheckId, w.Waiver.Source, w.Waiver.Reason)
+		}
+	}
+
+	if len(e.CriticalPath) > 0 {
+		fmt.Println("Critical path:")
+		for _, s := range e.CriticalPath {
+			fmt.Printf("\tslice %d | %s | %s\n", s.Slice, FormatDuration(s.Duration), s.Node.Operator)
+		}
+		fmt.Printf("\tstarted after %s\n", e.CriticalPathStart)
+		fmt.Println("Critical path time by slice:")
+		for _, s := range e.CriticalPathBySlice() {
+			fmt.Printf("\tslice %d | %s\n", s.Slice, FormatDuration(s.Duration))
+		}
+	}
+
+	if misestimates := e.WorstMisestimates(5); len(misestimates) > 0 {
+		fmt.Println("Worst row estimates:")
+		for _, n := range misestimates {
+			fmt.Printf("\t#%d | q-error %s | estimated %d, actual %s | %s\n", n.Id, n.QError, n.Rows, n.ActualRowsPerSeg, n.Operator)
+		}
+	}
+
 	if len(e.SliceStats) > 0 {
 		fmt.Println("Slice statistics:")
 		for _, stat := range e.SliceStats {
@@ -1213,11 +1488,11 @@ func (e *Explain) PrintPlan() {
 		}
 	}
 
-	if e.MemoryUsed > 0 {
+	if e.MemoryUsed.Valid {
 		fmt.Println("Statement statistics:")
-		fmt.Printf("\tMemory used: %d\n", e.MemoryUsed)
-		if e.MemoryWanted > 0 {
-			fmt.Printf("\tMemory wanted: %d\n", e.MemoryWanted)
+		fmt.Printf("\tMemory used: %s\n", e.MemoryUsed)
+		if e.MemoryWanted.Valid {
+			fmt.Printf("\tMemory wanted: %s\n", e.MemoryWanted)
 		}
 	}
 
@@ -1233,15 +1508,26 @@ func (e *Explain) PrintPlan() {
 		fmt.Printf("\t%s\n", e.OptimizerStatus)
 	}
 
-	if e.Runtime > 0 {
+	if e.Runtime.Valid {
 		fmt.Println("Total runtime:")
-		fmt.Printf("\t%.0f ms\n", e.Runtime)
+		fmt.Printf("\t%s\n", e.Runtime)
+	}
+
+	if script := e.RemediationScript(); script != "" {
+		fmt.Println("Remediation SQL:")
+		for _, line := range strings.Split(strings.TrimRight(script, "\n"), "\n") {
+			fmt.Printf("\t%s\n", line)
+		}
 	}
 
 }
 
-// Main init function
-func (e *Explain) InitPlan(plantext string) error {
+// Parse the plan text in to nodes and plans and build the tree.
+// Any previously parsed state is discarded so Parse can be called again
+// on the same Explain object.
+func (e *Explain) Parse(plantext string) error {
+	// Start from a clean object
+	*e = Explain{}
 
 	// Split the data in to lines
 	e.lines = strings.Split(string(plantext), "\n")
@@ -1283,23 +1569,127 @@ func (e *Explain) InitPlan(plantext string) error {
 		}
 	}
 
-	// Loop again to perform checks
+	return nil
+}
+
+// Calculate the derived values (node cost/time and percentages) for
+// every node. Requires Parse() to have been called first.
+func (e *Explain) Analyze() {
+	// Work out when each node was running so time can be attributed
+	e.calculateTimeline()
+	e.calculateCriticalPath()
+	e.calculateQErrors()
+	e.calculateSkew()
+
 	for _, n := range e.Nodes {
 		n.CalculateSubNodeDiff()
 
 		// Pass in Cost + Time of top node as it should be equal to total
 		n.CalculatePercentage(e.Nodes[0].TotalCost, e.Nodes[0].MsEnd)
+	}
+
+	e.Diagnostics = e.Validate()
+}
+
+// Run all NODECHECKS and EXPLAINCHECKS and return the findings.
+// The Explain object is not modified, use ApplyFindings() to attach
+// the warnings to the nodes for rendering.
+func (e *Explain) Check() *Findings {
+	return e.CheckWith(NODECHECKS, EXPLAINCHECKS)
+}
 
-		// Run Node checks
-		for _, c := range NODECHECKS {
-			c.Exec(n)
+// Run only the given checks and return the findings
+func (e *Explain) CheckWith(nodeChecks []NodeCheck, explainChecks []ExplainCheck) *Findings {
+	return e.checkWithParams(nodeChecks, explainChecks, nil, nil)
+}
+
+// Run the given checks with the parameters, defaults if nil.
+// Warnings matching the waivers or a waiver in the query are suppressed.
+func (e *Explain) checkWithParams(nodeChecks []NodeCheck, explainChecks []ExplainCheck, params *Params, waivers []Waiver) *Findings {
+	f := NewFindings()
+	f.Explain = e
+	if params != nil {
+		f.Params = params
+	}
+
+	// Run Node checks
+	for _, n := range e.Nodes {
+		for _, c := range nodeChecks {
+			f.check = &c.CheckInfo
+			c.Exec(n, f)
 		}
 	}
 
 	// Run Explain checks
-	for _, c := range EXPLAINCHECKS {
-		c.Exec(e)
+	for _, c := range explainChecks {
+		f.check = &c.CheckInfo
+		c.Exec(e, f)
+	}
+
+	f.check = nil
+
+	waivers = append(append([]Waiver{}, waivers...), e.InlineWaivers()...)
+	f.suppress(waivers, e.QueryFingerprint())
+
+	return f
+}
+
+// Replace the warnings on the Explain and its nodes with the findings.
+// Warnings from any previous call are discarded.
+func (e *Explain) ApplyFindings(f *Findings) {
+	e.Warnings = f.Warnings
+	e.Suppressed = f.Suppressed
+	for _, n := range e.Nodes {
+		n.Warnings = f.NodeWarnings[n]
+	}
+	e.Summary = e.Summarize(SummaryTopNodes, f.Params)
+}
+
+// Create empty findings
+func NewFindings() *Findings {
+	return &Findings{
+		NodeWarnings: make(map[*Node][]Warning),
+		Params:       NewParams(),
+	}
+}
+
+// Add a warning for the overall EXPLAIN output
+func (f *Findings) AddWarning(w Warning) {
+	f.Warnings = append(f.Warnings, f.label(w))
+}
+
+// Add a warning for a specific node
+func (f *Findings) AddNodeWarning(n *Node, w Warning) {
+	w.Node = n
+	f.NodeWarnings[n] = append(f.NodeWarnings[n], f.label(w))
+}
+
+// Fill in the check Id and severity from the running check
+func (f *Findings) label(w Warning) Warning {
+	if f.check != nil {
+		if w.CheckId == "" {
+			w.CheckId = f.check.Id
+		}
+		if w.Severity == 0 {
+			w.Severity = f.check.Severity
+		}
+	}
+	if w.Severity == 0 {
+		w.Severity = SeverityWarning
 	}
+	return w
+}
+
+// Main init function
+// Runs Parse() and Analyze(), the checks are run with Check() or
+// CheckWithConfig() so each is run once with the config wanted
+func (e *Explain) InitPlan(plantext string) error {
+	err := e.Parse(plantext)
+	if err != nil {
+		return err
+	}
+
+	e.Analyze()
 
 	return nil
 }
@@ -0,0 +1,219 @@
+package plan
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Parse and analyze a plan from the testdata directory
+func loadTestExplain(t *testing.T, filename string) *Explain {
+	t.Helper()
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Explain{}
+	if err := e.Parse(string(data)); err != nil {
+		t.Fatalf("%s: %s", filename, err)
+	}
+	e.Analyze()
+	return e
+}
+
+// Parse and analyze a plan from the testdata directory after replacing text,
+// given as old and new pairs, to test plans that are not in testdata
+func loadEditedExplain(t *testing.T, filename string, edits ...string) *Explain {
+	t.Helper()
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	for i := 0; i+1 < len(edits); i += 2 {
+		if strings.Contains(text, edits[i]) == false {
+			t.Fatalf("%s: %q not found", filename, edits[i])
+		}
+		text = strings.Replace(text, edits[i], edits[i+1], 1)
+	}
+	e := &Explain{}
+	if err := e.Parse(text); err != nil {
+		t.Fatalf("%s: %s", filename, err)
+	}
+	e.Analyze()
+	return e
+}
+
+// All plans in the testdata directory
+func testExplainFiles(t *testing.T) []string {
+	t.Helper()
+	files, err := filepath.Glob("../testdata/explain*.txt")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no plans found in testdata: %v", err)
+	}
+	return files
+}
+
+// Count the warnings on the nodes and on the overall EXPLAIN output
+func countWarnings(f *Findings) int {
+	count := len(f.Warnings)
+	for _, warnings := range f.NodeWarnings {
+		count += len(warnings)
+	}
+	return count
+}
+
+// Run a single node check on every node of the plan
+func runNodeCheck(t *testing.T, e *Explain, name string) *Findings {
+	t.Helper()
+	for _, c := range NODECHECKS {
+		if c.Name == name {
+			return e.CheckWith([]NodeCheck{c}, nil)
+		}
+	}
+	t.Fatalf("no node check %s", name)
+	return nil
+}
+
+// Write a file in a temporary directory removed after the test
+func writeTestFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// Warnings of the given checks on every plan in testdata with warnings, as
+// "#id check-id" for nodes in plan order and the check Id for the plan
+func testdataWarnings(t *testing.T, checks ...string) map[string][]string {
+	t.Helper()
+	found := map[string][]string{}
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+		f := e.CheckWithConfig(CheckConfig{Enabled: checks})
+		got := []string{}
+		for _, n := range e.Nodes {
+			for _, w := range f.NodeWarnings[n] {
+				got = append(got, fmt.Sprintf("#%d %s", n.Id, w.CheckId))
+			}
+		}
+		for _, w := range f.Warnings {
+			got = append(got, w.CheckId)
+		}
+		if len(got) > 0 {
+			found[filename[strings.LastIndex(filename, "/")+1:]] = got
+		}
+	}
+	return found
+}
+
+// Compare testdataWarnings with the expected warnings of each plan
+func compareTestdataWarnings(t *testing.T, got map[string][]string, want map[string][]string) {
+	t.Helper()
+	for name := range want {
+		if _, ok := got[name]; ok == false {
+			got[name] = nil
+		}
+	}
+	for name, warnings := range got {
+		if strings.Join(warnings, ", ") != strings.Join(want[name], ", ") {
+			t.Errorf("%s: warnings %v, want %v", name, warnings, want[name])
+		}
+	}
+}
+
+func TestParseTestdata(t *testing.T) {
+	tests := []struct {
+		filename string
+		nodes    int
+		analyzed bool
+	}{
+		{"../testdata/explain01.txt", 4, false},
+		{"../testdata/explain05.txt", 10, true},
+		{"../testdata/explain09.txt", 112, false},
+		{"../testdata/explain12.txt", 17, true},
+	}
+
+	for _, test := range tests {
+		e := loadTestExplain(t, test.filename)
+		if len(e.Nodes) != test.nodes {
+			t.Errorf("%s: parsed %d nodes, want %d", test.filename, len(e.Nodes), test.nodes)
+		}
+		if e.Nodes[0].IsAnalyzed != test.analyzed {
+			t.Errorf("%s: IsAnalyzed = %t, want %t", test.filename, e.Nodes[0].IsAnalyzed, test.analyzed)
+		}
+	}
+
+	e := &Explain{}
+	if err := e.Parse("no plan here\n"); err == nil || err.Error() != "Could not find any nodes in plan" {
+		t.Errorf("Parse without nodes error = %v", err)
+	}
+}
+
+// Parsing again discards the previous plan
+func TestParseResets(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain09.txt")
+	data, err := ioutil.ReadFile("../testdata/explain01.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Parse(string(data)); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Nodes) != 4 || len(e.Plans) != 1 {
+		t.Errorf("after parsing again: %d nodes and %d plans, want 4 and 1", len(e.Nodes), len(e.Plans))
+	}
+}
+
+// Checking does not modify the plan and every run returns the same findings
+func TestCheckIsRepeatable(t *testing.T) {
+	total := 0
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+
+		first := e.Check()
+		for _, n := range e.Nodes {
+			if len(n.Warnings) != 0 {
+				t.Errorf("%s: Check() attached warnings to node %q", filename, n.Operator)
+			}
+		}
+		if len(e.Warnings) != 0 {
+			t.Errorf("%s: Check() attached warnings to the plan", filename)
+		}
+
+		second := e.Check()
+		if countWarnings(first) != countWarnings(second) {
+			t.Errorf("%s: first run found %d warnings, second run %d", filename, countWarnings(first), countWarnings(second))
+		}
+		total += countWarnings(first)
+
+		// Applying the findings twice does not duplicate the warnings
+		e.ApplyFindings(first)
+		e.ApplyFindings(second)
+		applied := len(e.Warnings)
+		for _, n := range e.Nodes {
+			applied += len(n.Warnings)
+		}
+		if applied != countWarnings(second) {
+			t.Errorf("%s: %d warnings applied, want %d", filename, applied, countWarnings(second))
+		}
+	}
+	if total == 0 {
+		t.Errorf("no warnings found in testdata")
+	}
+}
+
+// Only the given checks are run
+func TestCheckWith(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain09.txt")
+	if f := e.CheckWith(nil, nil); countWarnings(f) != 0 {
+		t.Errorf("CheckWith no checks found %d warnings", countWarnings(f))
+	}
+	if countWarnings(e.CheckWith(NODECHECKS, nil))+countWarnings(e.CheckWith(nil, EXPLAINCHECKS)) != countWarnings(e.Check()) {
+		t.Errorf("node and explain checks run separately do not add up to Check()")
+	}
+}
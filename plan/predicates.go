@@ -0,0 +1,456 @@
+package plan
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Predicate linting
+//
+// Every condition line of a node is checked, not only Filter:
+//
+//	Filter, One-Time Filter, Index Cond, Recheck Cond, Join Filter,
+//	Hash Cond and Merge Cond
+//
+// The conditions are checked for patterns that stop Greenplum from using
+// indexes, partition elimination or direct dispatch, or that are slow to
+// evaluate for every row:
+//   - predicate-column-cast: columns cast to another type, e.g. id::numeric.
+//     Casts of varchar and char columns to text are how the plan compares
+//     them and are only reported on partition keys. The plan does not show
+//     column types, so casts of other types to text, e.g. int_col::text,
+//     look the same and are missed as well.
+//   - predicate-leading-wildcard: LIKE/ILIKE patterns starting with a
+//     wildcard, which can not use an index
+//   - predicate-large-array: = ANY ('{...}') and <> ALL ('{...}') with
+//     any_array_size elements or more, compared with every row
+//   - predicate-or-partition: OR conditions on partitioned tables where a
+//     branch does not restrict the partition key
+//   - predicate-partition-key: partition keys wrapped in a function, cast or
+//     arithmetic, so partitions can not be eliminated
+//
+// Partition keys are taken from the Filter of the Partition Selector of each
+// table, as ORCA plans show it:
+//
+//	->  Partition Selector for sales (dynamic scan id: 1)
+//	      Filter: year = 2015
+//
+// Plans do not show the distribution key of a table, so expressions on
+// distribution keys can not be told apart and only partition keys are checked.
+//
+// Expressions copied from the plan in to a warning are quoted and shortened
+// by quoteExprs, as they may hold any literal of the query.
+
+// A condition of a node, e.g. "Filter" and "year = 2015"
+type Predicate struct {
+	Kind string
+	Expr string
+}
+
+// Casts the plan adds when comparing varchar and char columns
+var implicitCasts = []string{"text", "bpchar", "character varying", "name"}
+
+var (
+	predicatePattern    = regexp.MustCompile(`^\s*(Filter|One-Time Filter|Index Cond|Recheck Cond|Join Filter|Hash Cond|Merge Cond): (.*)$`)
+	columnCastPattern   = regexp.MustCompile(`(^|[^A-Za-z0-9_$.'"])([A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)?)::([a-z][a-z0-9_ ]*[a-z0-9_](\[\])?)`)
+	likePattern         = regexp.MustCompile(`(\S+) (!?~~\*?) '([%_][^']*)'`)
+	anyArrayPattern     = regexp.MustCompile(`(\S+) (= ANY|<> ALL) \('\{([^}]*)\}'`)
+	partitionSelectorOf = regexp.MustCompile(`^Partition Selector for (\S+)`)
+	identifierPattern   = regexp.MustCompile(`'[^']*'|::[a-z][a-z0-9_ ]*[a-z0-9_]|[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)*\(?`)
+)
+
+// Operators of LIKE and ILIKE as printed in the plan
+var likeOperators = map[string]string{
+	"~~":   "LIKE",
+	"~~*":  "ILIKE",
+	"!~~":  "NOT LIKE",
+	"!~~*": "NOT ILIKE",
+}
+
+// Words in conditions that are not columns
+var predicateKeywords = []string{"AND", "OR", "NOT", "ANY", "ALL", "IS", "NULL", "TRUE", "FALSE", "IN", "SubPlan"}
+
+// Return every condition of the node
+func (n *Node) Predicates() []Predicate {
+	predicates := []Predicate{}
+	for _, line := range n.ExtraInfo[1:] {
+		if m := predicatePattern.FindStringSubmatch(line); len(m) == 3 {
+			predicates = append(predicates, Predicate{Kind: m[1], Expr: strings.TrimSpace(m[2])})
+		}
+	}
+	return predicates
+}
+
+// Split an expression on a separator outside of parentheses and quotes,
+// e.g. the branches of "a = 1 OR (b = 2 AND c = 3)"
+func splitTopLevel(expr string, sep string) []string {
+	parts := []string{}
+	depth := 0
+	quoted := false
+	start := 0
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case expr[i] == '\'':
+			quoted = !quoted
+		case quoted:
+		case expr[i] == '(':
+			depth++
+		case expr[i] == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(expr[i:], sep):
+			parts = append(parts, strings.TrimSpace(expr[start:i]))
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, strings.TrimSpace(expr[start:]))
+}
+
+// Remove the parentheses around a whole expression
+func trimParens(expr string) string {
+	for strings.HasPrefix(expr, "(") && closingParen(expr) == len(expr)-1 {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+	return expr
+}
+
+// Index of the parenthesis closing the one the expression starts with, -1
+// if it is not closed
+func closingParen(expr string) int {
+	depth := 0
+	quoted := false
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case expr[i] == '\'':
+			quoted = !quoted
+		case quoted:
+		case expr[i] == '(':
+			depth++
+		case expr[i] == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Column names referenced in an expression, without the table qualifier
+func predicateColumns(expr string) []string {
+	columns := []string{}
+	for _, token := range identifierPattern.FindAllString(expr, -1) {
+		if strings.HasPrefix(token, "'") || strings.HasPrefix(token, "::") || strings.HasSuffix(token, "(") {
+			continue
+		}
+		if containsString(predicateKeywords, token) {
+			continue
+		}
+		if column := lastIdentifier(token); containsString(columns, column) == false {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// Return the partition keys of each partitioned table by the Filter of its
+// Partition Selector
+func (e *Explain) PartitionKeys() map[string][]string {
+	keys := map[string][]string{}
+	for _, n := range e.Nodes {
+		if n.IsType(NodeTypePartitionSelector) == false {
+			continue
+		}
+		m := partitionSelectorOf.FindStringSubmatch(n.Operator)
+		if len(m) != 2 {
+			continue
+		}
+		for _, column := range predicateColumns(n.Condition("Filter")) {
+			if containsString(keys[m[1]], column) == false {
+				keys[m[1]] = append(keys[m[1]], column)
+			}
+		}
+	}
+	return keys
+}
+
+// Partition keys of the table a scan reads, nil if unknown
+func partitionKeysOf(e *Explain, n *Node) []string {
+	if e == nil || n.Category != CategoryScan || n.TableName() == "" {
+		return nil
+	}
+	for table, keys := range e.PartitionKeys() {
+		if lastIdentifier(table) == lastIdentifier(n.TableName()) {
+			return keys
+		}
+	}
+	return nil
+}
+
+// True if a scan reads a partitioned table, i.e. a child partition or a
+// Dynamic Scan
+func isPartitionScan(n *Node) bool {
+	if n.IsType(NodeTypeDynamicTableScan, NodeTypeDynamicIndexScan) {
+		return true
+	}
+	return n.Category == CategoryScan && partitionChildSuffix.MatchString(n.Object)
+}
+
+// Column casts in an expression, e.g. "id::numeric"
+func columnCasts(expr string) []string {
+	casts := []string{}
+	for _, m := range columnCastPattern.FindAllStringSubmatch(expr, -1) {
+		if containsString(predicateKeywords, m[2]) {
+			continue
+		}
+		if containsString(implicitCasts, strings.TrimSuffix(m[4], "[]")) {
+			continue
+		}
+		cast := m[2] + "::" + m[4]
+		if containsString(casts, cast) == false {
+			casts = append(casts, cast)
+		}
+	}
+	return casts
+}
+
+// Expressions on a key column that can not be matched to the key, e.g.
+// "date_trunc('day'::text, ts)", "year + 1" or "year::text"
+func keyExpressions(expr string, key string) []string {
+	column := `([A-Za-z_][A-Za-z0-9_$]*\.)?` + regexp.QuoteMeta(key) + `\b`
+	patterns := []*regexp.Regexp{
+		regexp.MustCompile(`\b[A-Za-z_][A-Za-z0-9_]*\([^()]*\b` + column + `[^()]*\)`),
+		regexp.MustCompile(`\b` + column + `::[a-z][a-z0-9_ ]*[a-z0-9_]`),
+		regexp.MustCompile(`\b` + column + `\s*[-+*/%]\s*[^\s)]+`),
+		regexp.MustCompile(`[^\s(]+\s*[-+*/%]\s*\b` + column),
+	}
+	found := []string{}
+	for _, re := range patterns {
+		for _, match := range re.FindAllString(expr, -1) {
+			if containsString(found, match) == false {
+				found = append(found, match)
+			}
+		}
+	}
+	return found
+}
+
+// Longest expression quoted in a warning
+const maxQuotedExpr = 100
+
+// Quote expressions copied from the plan for a warning, e.g.
+// "\"id::numeric\", \"code::integer\"". Control characters are escaped and
+// long expressions shortened.
+func quoteExprs(exprs []string) string {
+	quoted := []string{}
+	for _, expr := range exprs {
+		if runes := []rune(expr); len(runes) > maxQuotedExpr {
+			expr = string(runes[:maxQuotedExpr]) + "..."
+		}
+		quoted = append(quoted, strconv.Quote(expr))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Describe the conditions a finding is in, e.g. "Filter" or "Filter, Index Cond"
+func predicateKinds(kinds []string) string {
+	return strings.Join(kinds, ", ")
+}
+
+var predicateChecks = []NodeCheck{
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "predicate-column-cast",
+			Name:          "checkNodePredicateColumnCast",
+			Description:   "Column cast to another type in a condition",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryPredicates,
+			Severity:      SeverityWarning,
+			Documentation: "A column is cast before it is compared, usually because it is compared with a value or column of another type. The cast is evaluated for every row and an index or the partitioning on the column can not be used. Casts to text are left out as the plan shows them on every varchar and char column; as the plan does not show column types, casts of other types to text, e.g. int_col::text, are not reported either.",
+		},
+		Exec: func(n *Node, f *Findings) {
+			casts := []string{}
+			kinds := []string{}
+			for _, p := range n.Predicates() {
+				// Casts between join keys are reported by join-key-cast
+				if p.Kind == "Hash Cond" || p.Kind == "Merge Cond" {
+					continue
+				}
+				for _, cast := range columnCasts(p.Expr) {
+					if containsString(casts, cast) == false {
+						casts = append(casts, cast)
+					}
+					if containsString(kinds, p.Kind) == false {
+						kinds = append(kinds, p.Kind)
+					}
+				}
+			}
+			if len(casts) == 0 {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("%s casts %s", predicateKinds(kinds), quoteExprs(casts)),
+				Resolution: "Compare the column with a value of its own type, or change the column type"})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "predicate-leading-wildcard",
+			Name:          "checkNodePredicateLeadingWildcard",
+			Description:   "LIKE pattern starting with a wildcard",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryPredicates,
+			Severity:      SeverityWarning,
+			Documentation: "A LIKE or ILIKE pattern starting with % or _ has to be matched against every row, no index can be used. Anchoring the pattern at the start, or a trigram index where available, avoids the full scan.",
+		},
+		Exec: func(n *Node, f *Findings) {
+			found := []string{}
+			for _, p := range n.Predicates() {
+				for _, m := range likePattern.FindAllStringSubmatch(p.Expr, -1) {
+					like := fmt.Sprintf("%s %s '%s'", m[1], likeOperators[m[2]], m[3])
+					if containsString(found, like) == false {
+						found = append(found, like)
+					}
+				}
+			}
+			if len(found) == 0 {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("Pattern starting with a wildcard: %s", quoteExprs(found)),
+				Resolution: "Anchor the pattern at the start of the value if possible"})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "predicate-large-array",
+			Name:          "checkNodePredicateLargeArray",
+			Description:   "Large IN list or = ANY array",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryPredicates,
+			Severity:      SeverityWarning,
+			Documentation: "An IN list or = ANY array with any_array_size values or more is compared with every row one value at a time. Loading the values in to a table and joining it lets the optimizer use a Hash Join.",
+			Parameters:    []string{"any_array_size"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			found := []string{}
+			for _, p := range n.Predicates() {
+				for _, m := range anyArrayPattern.FindAllStringSubmatch(p.Expr, -1) {
+					size := len(strings.Split(m[3], ","))
+					if int64(size) < f.Params.Int("any_array_size") {
+						continue
+					}
+					found = append(found, fmt.Sprintf("%s %s of %d values", quoteExprs([]string{m[1]}), m[2], size))
+				}
+			}
+			if len(found) == 0 {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("Large array compared with every row: %s", strings.Join(found, ", ")),
+				Resolution: "Join a table with the values instead of a long IN list"})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "predicate-or-partition",
+			Name:          "checkNodePredicateOrPartition",
+			Description:   "OR condition defeating partition elimination",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryPartitioning,
+			Severity:      SeverityWarning,
+			Documentation: "A partitioned table is filtered with OR and at least one branch does not restrict the partition key, so every partition has to be scanned. When the partition key is not known the OR is reported if all partitions were scanned. Rewriting the query as a UNION ALL of the branches lets each branch eliminate partitions.",
+		},
+		Exec: func(n *Node, f *Findings) {
+			if isPartitionScan(n) == false {
+				return
+			}
+			keys := partitionKeysOf(f.Explain, n)
+			for _, p := range n.Predicates() {
+				// Look for OR in each of the conditions combined with AND
+				branches := []string{}
+				for _, condition := range splitTopLevel(trimParens(p.Expr), " AND ") {
+					if branches = splitTopLevel(trimParens(condition), " OR "); len(branches) > 1 {
+						break
+					}
+				}
+				if len(branches) < 2 {
+					continue
+				}
+
+				unrestricted := ""
+				for _, branch := range branches {
+					restricted := false
+					for _, column := range predicateColumns(branch) {
+						if containsString(keys, column) {
+							restricted = true
+						}
+					}
+					if restricted == false {
+						unrestricted = branch
+						break
+					}
+				}
+				if len(keys) == 0 {
+					// Partition key unknown, only report when nothing was eliminated
+					if n.PartScanned.Valid == false || n.PartScanned.Value < n.PartScannedTotal.Value {
+						continue
+					}
+				} else if unrestricted == "" {
+					continue
+				}
+
+				cause := fmt.Sprintf("%s on a partitioned table combines %s with OR", p.Kind, fmt.Sprintf("%d branches", len(branches)))
+				if len(keys) > 0 {
+					cause += fmt.Sprintf(", %s does not restrict the partition key (%s)", quoteExprs([]string{unrestricted}), quoteExprs(keys))
+				}
+				f.AddNodeWarning(n, Warning{
+					Cause:      cause,
+					Resolution: "Restrict the partition key in every branch, or split the query in to a UNION ALL"})
+				return
+			}
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "predicate-partition-key",
+			Name:          "checkNodePredicatePartitionKey",
+			Description:   "Partition key used in an expression",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryPartitioning,
+			Severity:      SeverityWarning,
+			Documentation: "The partition key is wrapped in a function, cast or arithmetic in the condition. Partitions are only eliminated on conditions comparing the bare key with a value, so every partition has to be scanned.",
+		},
+		Exec: func(n *Node, f *Findings) {
+			found := []string{}
+			for _, key := range partitionKeysOf(f.Explain, n) {
+				for _, p := range n.Predicates() {
+					for _, expr := range keyExpressions(p.Expr, key) {
+						if containsString(found, expr) == false {
+							found = append(found, expr)
+						}
+					}
+				}
+			}
+			if len(found) == 0 {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("Partition key used in an expression: %s", quoteExprs(found)),
+				Resolution: "Compare the bare partition key with a value, e.g. ts >= '2016-01-01' instead of date_trunc('year', ts) = '2016-01-01'"})
+		}},
+}
+
+func init() {
+	for _, c := range predicateChecks {
+		RegisterNodeCheck(c)
+	}
+}
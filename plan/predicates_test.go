@@ -0,0 +1,227 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPredicates(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain17.txt")
+	tests := []struct {
+		id   int
+		want []Predicate
+	}{
+		{3, []Predicate{{"Join Filter", "a13.master_account_sk = a14.account_sk"}}},
+		{19, []Predicate{{"Recheck Cond", "account_id::text = '1-C7-4426'::text"}}},
+		{20, []Predicate{{"Index Cond", "account_id::text = '1-C7-4426'::text"}}},
+		{1, []Predicate{}},
+	}
+
+	for _, test := range tests {
+		got := e.Nodes[test.id].Predicates()
+		if len(got) != len(test.want) {
+			t.Errorf("node #%d predicates = %+v, want %+v", test.id, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("node #%d predicate %d = %+v, want %+v", test.id, i, got[i], test.want[i])
+			}
+		}
+	}
+}
+
+func TestSplitTopLevel(t *testing.T) {
+	tests := []struct {
+		expr string
+		sep  string
+		want []string
+	}{
+		// explain13 Join Filter
+		{"(t1.a % 7) = 0 OR (t2.a % 3) = 0", " OR ", []string{"(t1.a % 7) = 0", "(t2.a % 3) = 0"}},
+		{"a = 1 OR (b = 2 OR c = 3)", " OR ", []string{"a = 1", "(b = 2 OR c = 3)"}},
+		{"a = ' OR ' AND b = 1", " OR ", []string{"a = ' OR ' AND b = 1"}},
+		{"a = 1 AND b = 2", " AND ", []string{"a = 1", "b = 2"}},
+	}
+
+	for _, test := range tests {
+		got := splitTopLevel(test.expr, test.sep)
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("splitTopLevel(%q, %q) = %q, want %q", test.expr, test.sep, got, test.want)
+		}
+	}
+
+	if got := trimParens("((a = 1 OR b = 2))"); got != "a = 1 OR b = 2" {
+		t.Errorf("trimParens = %q", got)
+	}
+	if got := trimParens("(a = 1) OR (b = 2)"); got != "(a = 1) OR (b = 2)" {
+		t.Errorf("trimParens = %q", got)
+	}
+}
+
+func TestPredicateColumns(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"public.sales.year = 2015", []string{"year"}},
+		{"date > '2008-03-01'::date", []string{"date"}},
+		{"upper(brief_status::text) = ANY ('{SIGNED,BRIEF}'::text[])", []string{"brief_status"}},
+		{"(b % 7) = 0 OR (a % 3) = 0", []string{"b", "a"}},
+	}
+
+	for _, test := range tests {
+		got := predicateColumns(test.expr)
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("predicateColumns(%q) = %q, want %q", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestColumnCasts(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"art_network_id::numeric = 1.0", []string{"art_network_id::numeric"}},
+		{"a.id::bigint = b.id::bigint AND a.id::bigint > 0", []string{"a.id::bigint", "b.id::bigint"}},
+		// Casts of constants and the text casts of varchar columns
+		{"recorded_date = '2016-01-21'::date", []string{}},
+		{"language::text = 'US'::text", []string{}},
+	}
+
+	for _, test := range tests {
+		got := columnCasts(test.expr)
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("columnCasts(%q) = %q, want %q", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestKeyExpressions(t *testing.T) {
+	tests := []struct {
+		expr string
+		key  string
+		want []string
+	}{
+		{"date_trunc('day'::text, ts) = '2016-01-01'", "ts", []string{"date_trunc('day'::text, ts)"}},
+		{"year::text = '2015'::text", "year", []string{"year::text"}},
+		{"s.year + 1 = 2016", "year", []string{"s.year + 1"}},
+		{"2016 - year = 1", "year", []string{"2016 - year"}},
+		// The bare key and other columns
+		{"year = 2015 AND years::text = '1'", "year", []string{}},
+	}
+
+	for _, test := range tests {
+		got := keyExpressions(test.expr, test.key)
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("keyExpressions(%q, %q) = %q, want %q", test.expr, test.key, got, test.want)
+		}
+	}
+}
+
+func TestPartitionKeys(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		// Partition Selector for sales (dynamic scan id: 1)
+		//       Filter: public.sales.year = 2015
+		{"../testdata/explain05.txt", "year"},
+		{"../testdata/explain20.txt", "date"},
+		{"../testdata/explain18.txt", ""},
+	}
+
+	for _, test := range tests {
+		e := loadTestExplain(t, test.filename)
+		if got := strings.Join(e.PartitionKeys()["sales"], ", "); got != test.want {
+			t.Errorf("%s: partition keys of sales = %q, want %q", test.filename, got, test.want)
+		}
+	}
+
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+	if isPartitionScan(e.Nodes[4]) == false || isPartitionScan(e.Nodes[1]) {
+		t.Errorf("only the Dynamic Table Scan #4 scans partitions")
+	}
+	if keys := partitionKeysOf(e, e.Nodes[4]); strings.Join(keys, ", ") != "year" {
+		t.Errorf("partition keys of #4 = %q", keys)
+	}
+}
+
+// No condition in testdata is reported with the default thresholds
+func TestPredicateChecksTestdata(t *testing.T) {
+	got := testdataWarnings(t, "predicate-column-cast", "predicate-leading-wildcard", "predicate-large-array", "predicate-or-partition", "predicate-partition-key")
+	compareTestdataWarnings(t, got, map[string][]string{})
+}
+
+func TestQuoteExprs(t *testing.T) {
+	long := strings.Repeat("a", maxQuotedExpr+10)
+	tests := []struct {
+		exprs []string
+		want  string
+	}{
+		{[]string{"id::numeric", "code::integer"}, `"id::numeric", "code::integer"`},
+		{[]string{"name ~~ '%\n%'"}, `"name ~~ '%\n%'"`},
+		{[]string{"a\tb"}, `"a\tb"`},
+		{[]string{long}, `"` + long[:maxQuotedExpr] + `..."`},
+	}
+
+	for _, test := range tests {
+		if got := quoteExprs(test.exprs); got != test.want {
+			t.Errorf("quoteExprs(%q) = %s, want %s", test.exprs, got, test.want)
+		}
+	}
+}
+
+// Each edit adds a condition one predicate check reports
+func TestPredicateChecksEdited(t *testing.T) {
+	tests := []struct {
+		filename string
+		old      string
+		new      string
+		check    string
+		id       int
+		want     string
+	}{
+		{"../testdata/explain15.txt",
+			"Filter: art_network_id = 1", "Filter: art_network_id::numeric = 1.0",
+			"predicate-column-cast", 19, "Filter casts \"art_network_id::numeric\""},
+		{"../testdata/explain16.txt",
+			"Filter: language::text = 'US'::text", "Filter: language::text ~~ '%US'::text",
+			"predicate-leading-wildcard", 25, "Pattern starting with a wildcard: \"language::text LIKE '%US'\""},
+		{"../testdata/explain05.txt",
+			"Filter: year = 2015", "Filter: year = 2015 OR id = 1",
+			"predicate-or-partition", 4, "Filter on a partitioned table combines 2 branches with OR, \"id = 1\" does not restrict the partition key (\"year\")"},
+		{"../testdata/explain05.txt",
+			"Filter: year = 2015", "Filter: year::text = '2015'::text",
+			"predicate-partition-key", 4, "Partition key used in an expression: \"year::text\""},
+	}
+
+	for _, test := range tests {
+		e := loadEditedExplain(t, test.filename, test.old, test.new)
+		f := e.CheckWithConfig(CheckConfig{Enabled: []string{test.check}})
+		w := f.NodeWarnings[e.Nodes[test.id]]
+		if len(f.NodeWarnings) != 1 || len(w) != 1 || w[0].Cause != test.want {
+			t.Errorf("%s: %s warnings = %+v, want %q on node #%d", test.filename, test.check, f.NodeWarnings, test.want, test.id)
+		}
+	}
+
+	// Every branch restricts the partition key
+	e := loadEditedExplain(t, "../testdata/explain05.txt",
+		"Filter: year = 2015", "Filter: (year = 2015 AND id = 2) OR year = 2016")
+	if f := e.CheckWithConfig(CheckConfig{Enabled: []string{"predicate-or-partition"}}); len(f.NodeWarnings) != 0 {
+		t.Errorf("predicate-or-partition warnings = %+v", f.NodeWarnings)
+	}
+}
+
+// explain14 compares data_id with an array of 26 values
+func TestPredicateLargeArray(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain14.txt")
+	p := NewParams()
+	p.Set("any_array_size", 20)
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"predicate-large-array"}, Params: p})
+	want := "Large array compared with every row: \"data_id\" = ANY of 26 values"
+	if w := f.NodeWarnings[e.Nodes[10]]; len(f.NodeWarnings) != 1 || len(w) != 1 || w[0].Cause != want {
+		t.Errorf("predicate-large-array warnings = %+v, want %q", f.NodeWarnings, want)
+	}
+}
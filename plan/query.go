@@ -0,0 +1,185 @@
+package plan
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// Returns true if the node matches
+type NodeFilter func(n *Node) bool
+
+// Return all nodes matching every filter, in plan order
+func (e *Explain) FindNodes(filters ...NodeFilter) []*Node {
+	return FilterNodes(e.Nodes, filters...)
+}
+
+// Return the first node matching every filter or nil if none match
+func (e *Explain) FindNode(filters ...NodeFilter) *Node {
+	found := e.FindNodes(filters...)
+	if len(found) == 0 {
+		return nil
+	}
+	return found[0]
+}
+
+// Return the nodes matching every filter
+func FilterNodes(nodes []*Node, filters ...NodeFilter) []*Node {
+	found := []*Node{}
+	for _, n := range nodes {
+		if matchAll(n, filters) {
+			found = append(found, n)
+		}
+	}
+	return found
+}
+
+func matchAll(n *Node, filters []NodeFilter) bool {
+	for _, f := range filters {
+		if f(n) == false {
+			return false
+		}
+	}
+	return true
+}
+
+// Match nodes of any of the given types
+func ByType(types ...NodeType) NodeFilter {
+	return func(n *Node) bool {
+		return n.IsType(types...)
+	}
+}
+
+// Match nodes in any of the given categories
+func ByCategory(categories ...NodeCategory) NodeFilter {
+	return func(n *Node) bool {
+		for _, c := range categories {
+			if n.Category == c {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Match nodes by object name
+// The pattern can contain shell style wildcards, e.g. "sales_1_prt_*"
+func ByObject(pattern string) NodeFilter {
+	return func(n *Node) bool {
+		if n.Object == "" {
+			return false
+		}
+		matched, err := path.Match(pattern, n.Object)
+		return err == nil && matched
+	}
+}
+
+// Match nodes where the Operator contains the text (case insensitive)
+func ByOperator(text string) NodeFilter {
+	text = strings.ToLower(text)
+	return func(n *Node) bool {
+		return strings.Contains(strings.ToLower(n.Operator), text)
+	}
+}
+
+// Match nodes executing in the given slice
+func BySlice(slice int64) NodeFilter {
+	return func(n *Node) bool {
+		return n.SliceId() == slice
+	}
+}
+
+// Match nodes that have at least one warning
+func WithWarnings() NodeFilter {
+	return func(n *Node) bool {
+		return len(n.Warnings) > 0
+	}
+}
+
+// Match nodes with a total cost greater than or equal to cost
+func MinCost(cost float64) NodeFilter {
+	return func(n *Node) bool {
+		return n.TotalCost >= cost
+	}
+}
+
+// Match nodes with a node cost (excluding children) greater than or equal to cost
+func MinNodeCost(cost float64) NodeFilter {
+	return func(n *Node) bool {
+		return n.NodeCost >= cost
+	}
+}
+
+// Match nodes taking greater than or equal to d to end
+func MinTime(d time.Duration) NodeFilter {
+	return func(n *Node) bool {
+		return n.MsEnd.Valid && n.MsEnd.Value >= d
+	}
+}
+
+// Match nodes with a node time (excluding children) greater than or equal to d
+func MinNodeTime(d time.Duration) NodeFilter {
+	return func(n *Node) bool {
+		return n.MsNode.Valid && n.MsNode.Value >= d
+	}
+}
+
+// Return the slice the node executes in.
+// Only Motion nodes are labelled with a slice in the plan so walk up the
+// tree until one is found. Nodes above the top Motion run in slice 0.
+func (n *Node) SliceId() int64 {
+	for c := n; c != nil; c = c.Parent {
+		if c.Slice.Valid {
+			return c.Slice.Value
+		}
+	}
+	return 0
+}
+
+// Return all nodes above this node, nearest first
+func (n *Node) Ancestors() []*Node {
+	ancestors := []*Node{}
+	for p := n.Parent; p != nil; p = p.Parent {
+		ancestors = append(ancestors, p)
+	}
+	return ancestors
+}
+
+// Return all nodes below this node, including SubPlans, in plan order
+func (n *Node) Descendants() []*Node {
+	descendants := []*Node{}
+	n.Walk(func(d *Node) error {
+		if d != n {
+			descendants = append(descendants, d)
+		}
+		return nil
+	}, nil)
+	return descendants
+}
+
+// Return the other children of this node's parent
+func (n *Node) Siblings() []*Node {
+	siblings := []*Node{}
+	if n.Parent == nil {
+		return siblings
+	}
+	for _, s := range n.Parent.Children() {
+		if s != n {
+			siblings = append(siblings, s)
+		}
+	}
+	return siblings
+}
+
+// Return the text of a condition line of the node, e.g. "Hash Cond" or
+// "Filter", or an empty string if the node does not have one
+func (n *Node) Condition(name string) string {
+	prefix := name + ": "
+	for _, line := range n.ExtraInfo[1:] {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, prefix) {
+			return line[len(prefix):]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,172 @@
+package plan
+
+import (
+	"testing"
+	"time"
+)
+
+// Ids of the nodes
+func nodeIds(nodes []*Node) []int {
+	ids := []int{}
+	for _, n := range nodes {
+		ids = append(ids, n.Id)
+	}
+	return ids
+}
+
+func sameIds(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// explain05 is a Hash Join of two Dynamic Table Scans on sales, the inner
+// side below a Redistribute Motion:
+//
+//	#0 Gather Motion (slice2)
+//	#1   Hash Join
+//	#2     Sequence
+//	#3       Partition Selector
+//	#4       Dynamic Table Scan on sales
+//	#5     Hash
+//	#6       Redistribute Motion (slice1)
+//	#7         Sequence
+//	#8           Partition Selector
+//	#9           Dynamic Table Scan on sales
+func TestFindNodes(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+
+	tests := []struct {
+		name    string
+		filters []NodeFilter
+		want    []int
+	}{
+		{"no filter", nil, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"type", []NodeFilter{ByType(NodeTypeHashJoin)}, []int{1}},
+		{"types", []NodeFilter{ByType(NodeTypeSequence, NodeTypeHash)}, []int{2, 5, 7}},
+		{"category", []NodeFilter{ByCategory(CategoryMotion)}, []int{0, 6}},
+		{"object", []NodeFilter{ByObject("sales")}, []int{4, 9}},
+		{"object wildcard", []NodeFilter{ByObject("sal*")}, []int{4, 9}},
+		{"object no match", []NodeFilter{ByObject("sales_*")}, []int{}},
+		{"operator", []NodeFilter{ByOperator("dynamic scan id: 2")}, []int{8, 9}},
+		{"operator case", []NodeFilter{ByOperator("PARTITION selector")}, []int{3, 8}},
+		{"slice", []NodeFilter{BySlice(1)}, []int{6, 7, 8, 9}},
+		{"slice without nodes", []NodeFilter{BySlice(0)}, []int{}},
+		{"all filters match", []NodeFilter{ByCategory(CategoryScan), BySlice(2)}, []int{4}},
+		{"cost", []NodeFilter{MinCost(862)}, []int{0, 1}},
+		{"node cost", []NodeFilter{ByType(NodeTypePartitionSelector), MinNodeCost(100)}, []int{3, 8}},
+	}
+
+	for _, test := range tests {
+		got := nodeIds(e.FindNodes(test.filters...))
+		if sameIds(got, test.want) == false {
+			t.Errorf("%s: found nodes %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	if n := e.FindNode(ByCategory(CategoryScan)); n == nil || n.Id != 4 {
+		t.Errorf("FindNode returned %v, want node #4", n)
+	}
+	if n := e.FindNode(ByType(NodeTypeNestedLoop)); n != nil {
+		t.Errorf("FindNode returned node #%d, want nil", n.Id)
+	}
+}
+
+// The time filters use the timings of EXPLAIN ANALYZE
+func TestFindNodesByTime(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+
+	if got := nodeIds(e.FindNodes(MinTime(7 * time.Second))); sameIds(got, []int{0, 1}) == false {
+		t.Errorf("MinTime(7s) found %v, want [0 1]", got)
+	}
+	for _, n := range e.FindNodes(MinNodeTime(time.Second)) {
+		if n.MsNode.Value < time.Second {
+			t.Errorf("MinNodeTime(1s) found node #%d with %s", n.Id, n.MsNode)
+		}
+	}
+
+	// Plans without EXPLAIN ANALYZE have no time
+	e = loadTestExplain(t, "../testdata/explain01.txt")
+	if got := e.FindNodes(MinTime(0)); len(got) != 0 {
+		t.Errorf("MinTime(0) found %v in a plan without timings", nodeIds(got))
+	}
+}
+
+func TestWithWarnings(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain09.txt")
+	if got := e.FindNodes(WithWarnings()); len(got) != 0 {
+		t.Errorf("found %d nodes with warnings before applying findings", len(got))
+	}
+
+	f := e.Check()
+	e.ApplyFindings(f)
+	if got := e.FindNodes(WithWarnings()); len(got) != len(f.NodeWarnings) || len(got) == 0 {
+		t.Errorf("found %d nodes with warnings, want %d", len(got), len(f.NodeWarnings))
+	}
+}
+
+// Nodes run in the slice of the nearest Motion above them
+func TestSliceIdTestdata(t *testing.T) {
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+		for _, n := range e.Nodes {
+			want := int64(0)
+			for _, a := range append([]*Node{n}, n.Ancestors()...) {
+				if a.Slice.Valid {
+					want = a.Slice.Value
+					break
+				}
+			}
+			if n.SliceId() != want {
+				t.Errorf("%s: node #%d in slice %d, want %d", filename, n.Id, n.SliceId(), want)
+			}
+		}
+	}
+}
+
+func TestRelatives(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+	scan := e.Nodes[9]
+
+	if got := nodeIds(scan.Ancestors()); sameIds(got, []int{7, 6, 5, 1, 0}) == false {
+		t.Errorf("ancestors of #9 = %v", got)
+	}
+	if got := nodeIds(e.Nodes[5].Descendants()); sameIds(got, []int{6, 7, 8, 9}) == false {
+		t.Errorf("descendants of #5 = %v", got)
+	}
+	if got := nodeIds(scan.Siblings()); sameIds(got, []int{8}) == false {
+		t.Errorf("siblings of #9 = %v", got)
+	}
+	if len(e.Nodes[0].Ancestors()) != 0 || len(e.Nodes[0].Siblings()) != 0 || len(scan.Descendants()) != 0 {
+		t.Errorf("relatives found outside the tree")
+	}
+
+	// Every node is below the top node
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+		if got := len(e.Nodes[0].Descendants()); got != len(e.Nodes)-1 {
+			t.Errorf("%s: top node has %d descendants, want %d", filename, got, len(e.Nodes)-1)
+		}
+	}
+}
+
+func TestParseNodeTypeAndCategory(t *testing.T) {
+	if nt, ok := ParseNodeType("hash join"); ok == false || nt != NodeTypeHashJoin {
+		t.Errorf("ParseNodeType(\"hash join\") = %s, %t", nt, ok)
+	}
+	if _, ok := ParseNodeType("Unknown"); ok {
+		t.Errorf("ParseNodeType(\"Unknown\") found a type")
+	}
+	if c, ok := ParseCategory("Motion"); ok == false || c != CategoryMotion {
+		t.Errorf("ParseCategory(\"Motion\") = %s, %t", c, ok)
+	}
+	if _, ok := ParseCategory("motions"); ok {
+		t.Errorf("ParseCategory(\"motions\") found a category")
+	}
+}
@@ -0,0 +1,322 @@
+package plan
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Remediation SQL
+//
+// Warnings can carry SQL statements that fix or work around the cause in
+// Warning.Remediation, e.g. "ANALYZE public.sales;". Each statement is
+// complete and ends with a semicolon so it can be pasted in to psql.
+// Explain.RemediationScript() collects the statements of all warnings in
+// to one script.
+//
+// Distribution keys are suggested from the columns redistributed tables are
+// joined on, see distribution.go. The join keys are parsed here from the
+// Hash Cond, Merge Cond and Join Filter lines of a join:
+//
+//	Hash Cond: a.col1 = b.col2::numeric
+//
+// and resolved to the scanned tables through the alias in the scan.
+
+// Column referenced in a join condition
+type ColumnRef struct {
+	Qualifier string // Table name or alias as written in the plan
+	Column    string
+}
+
+// Equality between two columns in a join condition
+type JoinKey struct {
+	Left      ColumnRef
+	Right     ColumnRef
+	LeftCast  string // Type the left column is cast to, empty if it is not cast
+	RightCast string
+}
+
+var (
+	joinCondPattern      = regexp.MustCompile(`^\s*(Hash Cond|Merge Cond|Join Filter): (.*)$`)
+	castPattern          = regexp.MustCompile(`::([a-z][a-z0-9_ ]*(\[\])?)`)
+	columnRefPattern     = regexp.MustCompile(`^([A-Za-z_"][A-Za-z0-9_$."]*)\.([A-Za-z_][A-Za-z0-9_$]*|"[^"]+")$`)
+	scanAliasPattern     = regexp.MustCompile(` (on|using) \S+ (\S+)$`)
+	indexTablePattern    = regexp.MustCompile(` using \S+ on (\S+)`)
+	partitionChildSuffix = regexp.MustCompile(`_[0-9]+_prt_.*$`)
+)
+
+func (c ColumnRef) String() string {
+	return c.Qualifier + "." + c.Column
+}
+
+// Parse the equality conditions of a join node.
+// Conditions that are not a plain column = column are skipped.
+func (n *Node) JoinKeys() []JoinKey {
+	keys := []JoinKey{}
+	if n.Category != CategoryJoin {
+		return keys
+	}
+
+	for _, line := range n.ExtraInfo[1:] {
+		m := joinCondPattern.FindStringSubmatch(line)
+		if len(m) != 3 {
+			continue
+		}
+
+		cond := strings.TrimSpace(m[2])
+		if strings.HasPrefix(cond, "(") && strings.HasSuffix(cond, ")") && strings.Count(cond, "(") == 1 {
+			cond = cond[1 : len(cond)-1]
+		}
+
+		for _, part := range strings.Split(cond, " AND ") {
+			sides := strings.Split(part, " = ")
+			if len(sides) != 2 {
+				continue
+			}
+			left, leftCast, ok := parseColumnRef(sides[0])
+			if ok == false {
+				continue
+			}
+			right, rightCast, ok := parseColumnRef(sides[1])
+			if ok == false {
+				continue
+			}
+			keys = append(keys, JoinKey{Left: left, Right: right, LeftCast: leftCast, RightCast: rightCast})
+		}
+	}
+	return keys
+}
+
+// Parse "alias.column", optionally in parentheses and cast.
+// Returns the type of the last cast, empty if there is none.
+func parseColumnRef(s string) (ColumnRef, string, bool) {
+	cast := ""
+	if m := castPattern.FindAllStringSubmatch(s, -1); len(m) > 0 {
+		cast = strings.TrimSpace(m[len(m)-1][1])
+	}
+	s = castPattern.ReplaceAllString(s, "")
+	s = strings.Trim(strings.TrimSpace(s), "() ")
+
+	m := columnRefPattern.FindStringSubmatch(s)
+	if len(m) != 3 {
+		return ColumnRef{}, "", false
+	}
+	return ColumnRef{Qualifier: m[1], Column: m[2]}, cast, true
+}
+
+// Alias of a scan node, the object name if there is no alias
+func (n *Node) Alias() string {
+	if m := scanAliasPattern.FindStringSubmatch(n.Operator); len(m) == 3 {
+		return m[2]
+	}
+	return n.Object
+}
+
+// Table read by a scan node.
+// Child partitions are reported as the partitioned table, e.g. sales for
+// sales_1_prt_2, and index scans as the table of the index.
+func (n *Node) TableName() string {
+	table := ""
+	if n.ObjectType == "TABLE" {
+		table = n.Object
+	} else if n.ObjectType == "INDEX" {
+		if m := indexTablePattern.FindStringSubmatch(n.Operator); len(m) == 2 {
+			table = m[1]
+		}
+	}
+	return partitionChildSuffix.ReplaceAllString(table, "")
+}
+
+// Find the scan at or below this node that a column reference points to
+func (n *Node) ScanOf(ref ColumnRef) *Node {
+	var found *Node
+	n.Walk(func(d *Node) error {
+		if found != nil {
+			return SkipChildren
+		}
+		if d.Category != CategoryScan || d.Object == "" {
+			return nil
+		}
+		if ref.Qualifier == d.Alias() || ref.Qualifier == d.Object || lastIdentifier(ref.Qualifier) == lastIdentifier(d.Object) {
+			found = d
+		}
+		return nil
+	}, nil)
+	return found
+}
+
+// Last part of a qualified name, "sales" for "public.sales"
+func lastIdentifier(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// Return the only table scanned at or below the node, empty if there are
+// none or more than one
+func subtreeTable(n *Node) string {
+	table := ""
+	count := 0
+	n.Walk(func(d *Node) error {
+		if d.Category == CategoryScan && d.TableName() != "" {
+			table = d.TableName()
+			count++
+		}
+		return nil
+	}, nil)
+	if count != 1 {
+		return ""
+	}
+	return table
+}
+
+// Statements refreshing the statistics of the table below each node, for
+// nodes that read a single table
+func analyzeRemediation(nodes ...*Node) []string {
+	statements := []string{}
+	for _, n := range nodes {
+		if table := subtreeTable(n); table != "" && containsString(statements, analyzeStatement(table)) == false {
+			statements = append(statements, analyzeStatement(table))
+		}
+	}
+	return statements
+}
+
+// Statement refreshing the statistics of a scanned table. The estimates of
+// an index scan come from the statistics of its table, so the table is
+// analyzed for indexes too.
+func statisticsRemediation(n *Node) []string {
+	if n.ObjectType == "TABLE" {
+		return []string{analyzeStatement(n.Object)}
+	}
+	if n.ObjectType == "INDEX" {
+		if table := indexedTable(n); table != "" {
+			return []string{analyzeStatement(table)}
+		}
+	}
+	return nil
+}
+
+// Table of an index scan. A Bitmap Index Scan names only the index, its
+// table is the Bitmap Heap Scan above it.
+func indexedTable(n *Node) string {
+	if table := n.TableName(); table != "" {
+		return table
+	}
+	for p := n.Parent; p != nil && strings.Contains(p.Operator, "Bitmap"); p = p.Parent {
+		if p.ObjectType == "TABLE" {
+			return p.TableName()
+		}
+	}
+	return ""
+}
+
+// Statement refreshing the statistics of a table
+func analyzeStatement(table string) string {
+	return fmt.Sprintf("ANALYZE %s;", quoteIdent(table))
+}
+
+// Keywords quote_ident() quotes, the reserved ones and those that can not be
+// used as a column or function name
+var quotedKeywords = strings.Fields(`all analyse analyze and any array as asc
+	asymmetric authorization between bigint binary bit boolean both case cast
+	char character check coalesce collate collation column concurrently
+	constraint create cross current_catalog current_date current_role
+	current_schema current_time current_timestamp current_user dec decimal
+	default deferrable desc distinct do else end except exists extract false
+	fetch float for foreign freeze from full grant greatest group grouping
+	having ilike in initially inner inout int integer intersect interval into
+	is isnull join lateral leading least left like limit localtime
+	localtimestamp national natural nchar none not notnull null nullif numeric
+	offset on only or order out outer overlaps overlay placing position
+	precision primary real references returning right row select
+	session_user setof similar smallint some substring symmetric table
+	tablesample then time timestamp to trailing treat trim true union unique
+	user using values varchar variadic verbose when where window with`)
+
+var plainIdentifierPattern = regexp.MustCompile(`^[a-z_][a-z0-9_$]*$`)
+
+// Quote each part of a possibly schema qualified name the way quote_ident()
+// does, so the statements work for mixed case names and keywords.
+// Parts already quoted in the plan are kept as they are.
+func quoteIdent(name string) string {
+	parts := []string{}
+	part := ""
+	quoted := false
+	for _, r := range name {
+		if r == '"' {
+			quoted = !quoted
+		}
+		if r == '.' && quoted == false {
+			parts = append(parts, part)
+			part = ""
+			continue
+		}
+		part += string(r)
+	}
+	parts = append(parts, part)
+
+	for i, p := range parts {
+		if strings.HasPrefix(p, "\"") || (plainIdentifierPattern.MatchString(p) && containsString(quotedKeywords, p) == false) {
+			continue
+		}
+		parts[i] = "\"" + strings.Replace(p, "\"", "\"\"", -1) + "\""
+	}
+	return strings.Join(parts, ".")
+}
+
+// Statement giving the query the memory it wanted, rounded up to the next MB.
+// The node is optional.
+func statementMemRemediation(e *Explain, n *Node) []string {
+	wanted := ByteSize(0)
+	if e != nil && e.MemoryWanted.Valid {
+		wanted = e.MemoryWanted.Value
+	}
+	if n != nil && n.WorkMemWanted.Valid && n.WorkMemWanted.Value > wanted {
+		wanted = n.WorkMemWanted.Value
+	}
+	if wanted <= 0 {
+		return nil
+	}
+	mb := (wanted + Megabyte - 1) / Megabyte
+	return []string{fmt.Sprintf("SET statement_mem = '%dMB';", mb)}
+}
+
+// Statement enabling ORCA when the plan was produced with it disabled
+func optimizerRemediation(e *Explain) []string {
+	if e != nil && e.Optimizer == "off" {
+		return []string{"SET optimizer = on;"}
+	}
+	return nil
+}
+
+// Return the remediation SQL of all warnings as a script, empty if there is none.
+// Each group of statements is preceded by a comment with the warning and
+// statements already in the script are not repeated.
+func (e *Explain) RemediationScript() string {
+	warnings := append([]Warning{}, e.Warnings...)
+	for _, n := range e.Nodes {
+		warnings = append(warnings, n.Warnings...)
+	}
+
+	script := ""
+	seen := map[string]bool{}
+	for _, w := range warnings {
+		statements := []string{}
+		for _, s := range w.Remediation {
+			if seen[s] == false {
+				seen[s] = true
+				statements = append(statements, s)
+			}
+		}
+		if len(statements) == 0 {
+			continue
+		}
+
+		location := ""
+		if w.Node != nil {
+			location = fmt.Sprintf("#%d %s: ", w.Node.Id, w.Node.Operator)
+		}
+		script += fmt.Sprintf("-- %s%s [%s]\n", location, w.Cause, w.CheckId)
+		script += strings.Join(statements, "\n") + "\n"
+	}
+	return script
+}
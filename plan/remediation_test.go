@@ -0,0 +1,164 @@
+package plan
+
+import (
+	"testing"
+)
+
+func TestJoinKeys(t *testing.T) {
+	tests := []struct {
+		filename string
+		id       int
+		want     []JoinKey
+	}{
+		// Hash Cond: b.col2 = a.col1
+		{"../testdata/explain18.txt", 1, []JoinKey{
+			{Left: ColumnRef{"b", "col2"}, Right: ColumnRef{"a", "col1"}},
+		}},
+		// Hash Cond: public.sales.id = public.sales.year
+		{"../testdata/explain05.txt", 1, []JoinKey{
+			{Left: ColumnRef{"public.sales", "id"}, Right: ColumnRef{"public.sales", "year"}},
+		}},
+		// Quoted column compared with a cast
+		{"../testdata/explain15.txt", 5, []JoinKey{
+			{Left: ColumnRef{"imp", "\"time\""}, Right: ColumnRef{"adwd_imp", "date_dt"}, LeftCast: "date"},
+		}},
+		// Not a join
+		{"../testdata/explain18.txt", 0, []JoinKey{}},
+	}
+
+	for _, test := range tests {
+		n := loadTestExplain(t, test.filename).Nodes[test.id]
+		got := n.JoinKeys()
+		if len(got) != len(test.want) {
+			t.Errorf("%s: node #%d join keys = %+v, want %+v", test.filename, n.Id, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: node #%d join key %d = %+v, want %+v", test.filename, n.Id, i, got[i], test.want[i])
+			}
+		}
+	}
+
+	// Conditions combined with AND are split, casts are per key
+	keys := loadTestExplain(t, "../testdata/explain12.txt").Nodes[8].JoinKeys()
+	if len(keys) != 4 || keys[0].LeftCast != "text" || keys[0].RightCast != "text" || keys[3].LeftCast != "" || keys[3].Left.Column != "recorded_time" {
+		t.Errorf("explain12 join keys = %+v", keys)
+	}
+}
+
+func TestScanNames(t *testing.T) {
+	tests := []struct {
+		filename string
+		id       int
+		alias    string
+		table    string
+	}{
+		{"../testdata/explain18.txt", 3, "b", "bigtable"},
+		{"../testdata/explain05.txt", 4, "sales", "sales"},
+		// Child partitions are reported as the partitioned table
+		{"../testdata/explain04.txt", 2, "sales", "sales"},
+		{"../testdata/explain11.txt", 9, "trn_purch_detail_1_prt_p201601", "trn_purch_detail"},
+	}
+
+	for _, test := range tests {
+		n := loadTestExplain(t, test.filename).Nodes[test.id]
+		if n.Alias() != test.alias || n.TableName() != test.table {
+			t.Errorf("%s: %s has alias %q and table %q, want %q and %q", test.filename, n.Operator, n.Alias(), n.TableName(), test.alias, test.table)
+		}
+	}
+
+	// Join keys are resolved to the scan through the alias
+	e := loadTestExplain(t, "../testdata/explain18.txt")
+	join := e.Nodes[1]
+	keys := join.JoinKeys()
+	if scan := join.ScanOf(keys[0].Left); scan == nil || scan.Alias() != "b" {
+		t.Errorf("b.col2 resolved to %v", scan)
+	}
+	if scan := join.ScanOf(ColumnRef{"c", "col1"}); scan != nil {
+		t.Errorf("c.col1 resolved to node #%d", scan.Id)
+	}
+}
+
+// The script lists each statement once, below the first warning needing it
+func TestRemediationScriptExplain05(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+	e.ApplyFindings(e.CheckWithConfig(CheckConfig{Enabled: []string{"spill-files", "estimated-rows-one", "row-misestimate"}}))
+
+	want := `-- #1 Hash Join: Total 2 spilling segments found, 52.8 MB written to workfiles, work_mem 124.5 MB used, 167.8 MB wanted [spill-files]
+SET statement_mem = '169MB';
+-- #4 Dynamic Table Scan on sales (dynamic scan id: 1): Actual rows is higher than estimated rows [estimated-rows-one]
+ANALYZE sales;
+`
+	if got := e.RemediationScript(); got != want {
+		t.Errorf("remediation script =\n%s\nwant\n%s", got, want)
+	}
+
+	analyze := 0
+	for _, n := range e.Nodes {
+		for _, w := range n.Warnings {
+			if containsString(w.Remediation, "ANALYZE sales;") {
+				analyze++
+			}
+		}
+	}
+	if analyze < 2 {
+		t.Errorf("%d warnings with ANALYZE sales, want the same statement on several", analyze)
+	}
+
+	e = loadTestExplain(t, "../testdata/explain19.txt")
+	e.ApplyFindings(e.Check())
+	if got := e.RemediationScript(); got != "" {
+		t.Errorf("remediation script without warnings = %q", got)
+	}
+}
+
+// Statistics are refreshed on the table scanned, for an index scan on the
+// table of the index
+func TestStatisticsRemediationExplain17(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain17.txt")
+	f := runNodeCheck(t, e, "checkNodeEstimatedRows")
+
+	tests := []struct {
+		id   int
+		want string
+	}{
+		{12, "ANALYZE d_date;"},
+		{15, "ANALYZE d_customer_account;"},
+		// Bitmap Index Scan on account_sk_index below the scan of a13
+		{16, "ANALYZE d_customer_account;"},
+	}
+	for _, test := range tests {
+		w := f.NodeWarnings[e.Nodes[test.id]]
+		if len(w) != 1 || len(w[0].Remediation) != 1 || w[0].Remediation[0] != test.want {
+			t.Errorf("node #%d remediation = %+v, want %q", test.id, w, test.want)
+		}
+	}
+}
+
+// Names are quoted like quote_ident() does, per part of a qualified name
+func TestQuoteIdent(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"sales", "sales"},
+		{"public.sales_1_prt_2", "public.sales_1_prt_2"},
+		{"Sales", `"Sales"`},
+		{"public.order", `public."order"`},
+		{"time", `"time"`},
+		{`"time"`, `"time"`},
+		{`"My.Schema".tbl`, `"My.Schema".tbl`},
+		{`a"b`, `"a""b"`},
+		{"2020_sales", `"2020_sales"`},
+	}
+	for _, test := range tests {
+		if got := quoteIdent(test.name); got != test.want {
+			t.Errorf("quoteIdent(%q) = %s, want %s", test.name, got, test.want)
+		}
+	}
+
+	if got := analyzeStatement("public.Sales"); got != `ANALYZE public."Sales";` {
+		t.Errorf("analyzeStatement = %s", got)
+	}
+}
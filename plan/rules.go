@@ -0,0 +1,399 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule checks
+//
+// Checks can be defined in a file instead of Go code. Each rule has a match
+// expression (see expr.go) and a cause and resolution template, and is added
+// to NODECHECKS or EXPLAINCHECKS so it runs alongside the built in checks.
+//
+//	# YAML
+//	- id: fact-seq-scan
+//	  level: node
+//	  description: Large Seq Scan on a fact table
+//	  match: type == "Seq Scan" && object like "fact_%" && rows > 1e8
+//	  cause: Seq Scan on {{object}} estimated at {{rows}} rows
+//	  resolution: Check the filter on {{object}} can use a partition or index
+//	  severity: warning
+//
+//	// JSON
+//	[{"id": "fact-seq-scan", "match": "...", "cause": "...", "resolution": "..."}]
+//
+// level is "node" (default) or "explain". Templates can contain any
+// expression in {{ }}, the resolution defaults to "Review query".
+// Optional keys are name, category, documentation, created, remediation (an
+// SQL statement template, see remediation.go), optimizers and dialects, the
+// last two as a list.
+//
+// Rule files are read as described in files.go. A template starting with {{
+// must be quoted in YAML.
+
+// A check defined in a rule file
+type Rule struct {
+	Id            string   `json:"id" yaml:"id"`
+	Name          string   `json:"name" yaml:"name"`
+	Level         string   `json:"level" yaml:"level"`
+	Description   string   `json:"description" yaml:"description"`
+	Match         string   `json:"match" yaml:"match"`
+	Cause         string   `json:"cause" yaml:"cause"`
+	Resolution    string   `json:"resolution" yaml:"resolution"`
+	Remediation   string   `json:"remediation" yaml:"remediation"`
+	Severity      string   `json:"severity" yaml:"severity"`
+	Category      string   `json:"category" yaml:"category"`
+	Documentation string   `json:"documentation" yaml:"documentation"`
+	CreatedAt     string   `json:"created" yaml:"created"`
+	Optimizers    []string `json:"optimizers" yaml:"optimizers"`
+	Dialects      []string `json:"dialects" yaml:"dialects"`
+}
+
+var (
+	// Fields available to node rules
+	nodeRuleFields = map[string]func(n *Node) interface{}{
+		"id":                   func(n *Node) interface{} { return float64(n.Id) },
+		"depth":                func(n *Node) interface{} { return float64(n.Depth) },
+		"operator":             func(n *Node) interface{} { return n.Operator },
+		"type":                 func(n *Node) interface{} { return n.Type.String() },
+		"category":             func(n *Node) interface{} { return n.Category.String() },
+		"join_type":            func(n *Node) interface{} { return n.JoinType.String() },
+		"scan_method":          func(n *Node) interface{} { return n.ScanMethod.String() },
+		"object":               func(n *Node) interface{} { return n.Object },
+		"object_type":          func(n *Node) interface{} { return n.ObjectType },
+		"filter":               func(n *Node) interface{} { return n.Filter },
+		"slice":                func(n *Node) interface{} { return float64(n.SliceId()) },
+		"segments":             func(n *Node) interface{} { return optionalIntValue(n.SegmentCount()) },
+		"startup_cost":         func(n *Node) interface{} { return n.StartupCost },
+		"total_cost":           func(n *Node) interface{} { return n.TotalCost },
+		"node_cost":            func(n *Node) interface{} { return n.NodeCost },
+		"prct_cost":            func(n *Node) interface{} { return n.PrctCost },
+		"rows":                 func(n *Node) interface{} { return float64(n.Rows) },
+		"width":                func(n *Node) interface{} { return float64(n.Width) },
+		"analyzed":             func(n *Node) interface{} { return n.IsAnalyzed },
+		"actual_rows":          func(n *Node) interface{} { return optionalCountValue(n.ActualRows) },
+		"avg_rows":             func(n *Node) interface{} { return optionalCountValue(n.AvgRows) },
+		"max_rows":             func(n *Node) interface{} { return optionalCountValue(n.MaxRows) },
+		"max_seg":              func(n *Node) interface{} { return n.MaxSeg },
+		"rows_per_seg":         func(n *Node) interface{} { return optionalCountValue(n.ActualRowsPerSeg) },
+		"q_error":              func(n *Node) interface{} { return optionalFloatValue(n.QError) },
+		"workers":              func(n *Node) interface{} { return optionalIntValue(n.Workers) },
+		"scans":                func(n *Node) interface{} { return optionalIntValue(n.Scans) },
+		"ms_first":             func(n *Node) interface{} { return optionalMsValue(n.MsFirst) },
+		"ms_end":               func(n *Node) interface{} { return optionalMsValue(n.MsEnd) },
+		"ms_offset":            func(n *Node) interface{} { return optionalMsValue(n.MsOffset) },
+		"ms_node":              func(n *Node) interface{} { return optionalMsValue(n.MsNode) },
+		"prct_time":            func(n *Node) interface{} { return n.MsPrct },
+		"critical_path":        func(n *Node) interface{} { return n.OnCriticalPath },
+		"avg_mem":              func(n *Node) interface{} { return optionalBytesValue(n.AvgMem) },
+		"max_mem":              func(n *Node) interface{} { return optionalBytesValue(n.MaxMem) },
+		"spill_files":          func(n *Node) interface{} { return optionalIntValue(n.SpillFile) },
+		"spill_reuse":          func(n *Node) interface{} { return optionalIntValue(n.SpillReuse) },
+		"parts_selected":       func(n *Node) interface{} { return optionalIntValue(n.PartSelected) },
+		"parts_selected_total": func(n *Node) interface{} { return optionalIntValue(n.PartSelectedTotal) },
+		"parts_scanned":        func(n *Node) interface{} { return optionalIntValue(n.PartScanned) },
+		"parts_scanned_total":  func(n *Node) interface{} { return optionalIntValue(n.PartScannedTotal) },
+	}
+
+	// Fields available to explain rules, and to node rules
+	explainRuleFields = map[string]func(e *Explain) interface{}{
+		"optimizer":        func(e *Explain) interface{} { return e.Optimizer },
+		"optimizer_status": func(e *Explain) interface{} { return e.OptimizerStatus },
+		"runtime":          func(e *Explain) interface{} { return optionalMsValue(e.Runtime) },
+		"memory_used":      func(e *Explain) interface{} { return optionalBytesValue(e.MemoryUsed) },
+		"memory_wanted":    func(e *Explain) interface{} { return optionalBytesValue(e.MemoryWanted) },
+		"nodes":            func(e *Explain) interface{} { return float64(len(e.Nodes)) },
+		"slices": func(e *Explain) interface{} {
+			slices := map[int64]bool{0: true}
+			for _, n := range e.Nodes {
+				slices[n.SliceId()] = true
+			}
+			return float64(len(slices))
+		},
+		"motions": func(e *Explain) interface{} {
+			return float64(len(e.FindNodes(ByCategory(CategoryMotion))))
+		},
+	}
+
+	rulePlaceholderPattern = regexp.MustCompile(`\{\{(.*?)\}\}`)
+)
+
+func optionalIntValue(i OptionalInt) interface{} {
+	if i.Valid == false {
+		return nil
+	}
+	return float64(i.Value)
+}
+
+func optionalCountValue(c OptionalCount) interface{} {
+	if c.Valid == false {
+		return nil
+	}
+	return c.Value
+}
+
+func optionalFloatValue(f OptionalFloat) interface{} {
+	if f.Valid == false {
+		return nil
+	}
+	return f.Value
+}
+
+// Durations are in milliseconds
+func optionalMsValue(d OptionalDuration) interface{} {
+	if d.Valid == false {
+		return nil
+	}
+	return d.Ms()
+}
+
+// Sizes are in bytes
+func optionalBytesValue(b OptionalBytes) interface{} {
+	if b.Valid == false {
+		return nil
+	}
+	return float64(b.Value)
+}
+
+// Names of the fields a rule can use at the given level, sorted
+func RuleFields(level string) []string {
+	names := []string{}
+	for name := range explainRuleFields {
+		names = append(names, name)
+	}
+	if level == "node" {
+		for name := range nodeRuleFields {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup a field of the node, or of the explain if the node does not have it
+func ruleEnv(e *Explain, n *Node) exprEnv {
+	return func(name string) interface{} {
+		if strings.HasPrefix(name, "setting:") {
+			if e != nil {
+				for _, s := range e.Settings {
+					if s.Name == name[8:] {
+						return s.Value
+					}
+				}
+			}
+			return nil
+		}
+		if n != nil {
+			if f, ok := nodeRuleFields[name]; ok {
+				return f(n)
+			}
+		}
+		if e != nil {
+			if f, ok := explainRuleFields[name]; ok {
+				return f(e)
+			}
+		}
+		return nil
+	}
+}
+
+// A compiled cause or resolution template
+type ruleTemplate struct {
+	text  []string // Text between the expressions
+	exprs []*Expr
+}
+
+func compileRuleTemplate(src string, fields []string) (*ruleTemplate, error) {
+	t := &ruleTemplate{}
+	last := 0
+	for _, m := range rulePlaceholderPattern.FindAllStringSubmatchIndex(src, -1) {
+		x, err := CompileExpr(strings.TrimSpace(src[m[2]:m[3]]), fields)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("{{%s}}: %s", src[m[2]:m[3]], err))
+		}
+		t.text = append(t.text, src[last:m[0]])
+		t.exprs = append(t.exprs, x)
+		last = m[1]
+	}
+	t.text = append(t.text, src[last:])
+	return t, nil
+}
+
+func (t *ruleTemplate) render(env exprEnv) string {
+	s := t.text[0]
+	for i, x := range t.exprs {
+		s += formatExprValue(x.Eval(env)) + t.text[i+1]
+	}
+	return s
+}
+
+// Render a remediation template as a statement, none if it is empty
+func (t *ruleTemplate) statements(env exprEnv) []string {
+	s := strings.TrimSpace(t.render(env))
+	if s == "" {
+		return nil
+	}
+	if strings.HasSuffix(s, ";") == false {
+		s += ";"
+	}
+	return []string{s}
+}
+
+// Compile the rule in to a node or explain check.
+// Exactly one of the returned checks is set.
+func (r Rule) Compile() (*NodeCheck, *ExplainCheck, error) {
+	if r.Id == "" {
+		return nil, nil, errors.New("rule without an id")
+	}
+	if r.Match == "" || r.Cause == "" {
+		return nil, nil, errors.New(fmt.Sprintf("rule %s: match and cause are required", r.Id))
+	}
+
+	level := r.Level
+	if level == "" {
+		level = "node"
+	}
+	if level != "node" && level != "explain" {
+		return nil, nil, errors.New(fmt.Sprintf("rule %s: level must be node or explain", r.Id))
+	}
+	fields := RuleFields(level)
+
+	match, err := CompileExpr(r.Match, fields)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("rule %s: match: %s", r.Id, err))
+	}
+	cause, err := compileRuleTemplate(r.Cause, fields)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("rule %s: cause: %s", r.Id, err))
+	}
+	if r.Resolution == "" {
+		r.Resolution = "Review query"
+	}
+	resolution, err := compileRuleTemplate(r.Resolution, fields)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("rule %s: resolution: %s", r.Id, err))
+	}
+	remediation, err := compileRuleTemplate(r.Remediation, fields)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("rule %s: remediation: %s", r.Id, err))
+	}
+
+	info := CheckInfo{
+		Id:            r.Id,
+		Name:          r.Name,
+		Description:   r.Description,
+		CreatedAt:     r.CreatedAt,
+		Scope:         r.Optimizers,
+		Dialects:      r.Dialects,
+		Category:      CheckCategory(r.Category),
+		Severity:      SeverityWarning,
+		Documentation: r.Documentation,
+	}
+	if info.Name == "" {
+		info.Name = r.Id
+	}
+	if info.Description == "" {
+		info.Description = r.Match
+	}
+	if info.Category == "" {
+		info.Category = CheckCategoryCustom
+	}
+	if len(info.Scope) == 0 {
+		info.Scope = []string{"orca", "legacy"}
+	}
+	if len(info.Dialects) == 0 {
+		info.Dialects = []string{DialectGreenplum, DialectHawq}
+	}
+	if r.Severity != "" {
+		s, ok := ParseSeverity(r.Severity)
+		if ok == false {
+			return nil, nil, errors.New(fmt.Sprintf("rule %s: unknown severity \"%s\"", r.Id, r.Severity))
+		}
+		info.Severity = s
+	}
+
+	if level == "explain" {
+		return nil, &ExplainCheck{
+			CheckInfo: info,
+			Exec: func(e *Explain, f *Findings) {
+				env := ruleEnv(e, nil)
+				if match.Match(env) {
+					f.AddWarning(Warning{
+						Cause:       cause.render(env),
+						Resolution:  resolution.render(env),
+						Remediation: remediation.statements(env)})
+				}
+			},
+		}, nil
+	}
+
+	return &NodeCheck{
+		CheckInfo: info,
+		Exec: func(n *Node, f *Findings) {
+			env := ruleEnv(f.Explain, n)
+			if match.Match(env) {
+				f.AddNodeWarning(n, Warning{
+					Cause:       cause.render(env),
+					Resolution:  resolution.render(env),
+					Remediation: remediation.statements(env)})
+			}
+		},
+	}, nil, nil
+}
+
+// Read rules from a file.
+// Files ending in .json are read as JSON, anything else as YAML.
+func ReadRuleFile(filename string) ([]Rule, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []Rule{}
+	if err := unmarshalFile(filename, data, &rules); err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to read rules %s: %s", filename, err))
+	}
+	return rules, nil
+}
+
+// Read, compile and register the rules in a file.
+// Nothing is registered if any rule is invalid.
+func LoadRuleFile(filename string) error {
+	rules, err := ReadRuleFile(filename)
+	if err != nil {
+		return err
+	}
+
+	nodeChecks := []NodeCheck{}
+	explainChecks := []ExplainCheck{}
+	ids := map[string]bool{}
+	for _, r := range rules {
+		if _, ok := LookupCheck(r.Id); ok || ids[r.Id] {
+			return errors.New(fmt.Sprintf("%s: check \"%s\" is already registered", filename, r.Id))
+		}
+		ids[r.Id] = true
+
+		nodeCheck, explainCheck, err := r.Compile()
+		if err != nil {
+			return errors.New(fmt.Sprintf("%s: %s", filename, err))
+		}
+		if nodeCheck != nil {
+			nodeChecks = append(nodeChecks, *nodeCheck)
+		} else {
+			explainChecks = append(explainChecks, *explainCheck)
+		}
+	}
+
+	for _, c := range nodeChecks {
+		RegisterNodeCheck(c)
+	}
+	for _, c := range explainChecks {
+		RegisterExplainCheck(c)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+const testRulesYAML = `# Rules used by the tests
+- id: test-seq-scan
+  description: Seq Scan with an estimate  # comment
+  match: category == "scan" && rows > 1000
+  cause: "Scan on {{object}} estimated at {{rows}} rows"
+  resolution: Check the filter on {{object}}
+  severity: info
+  optimizers: [orca, legacy]
+
+- id: test-motions
+  level: explain
+  match: motions >= 3
+  cause: '{{motions}} motions # not a comment'
+  resolution: SET gp_segments_for_planner = {{motions}};
+`
+
+func TestReadRuleFile(t *testing.T) {
+	rules, err := ReadRuleFile(writeTestFile(t, "rules.yml", testRulesYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("read %d rules, want 2", len(rules))
+	}
+
+	r := rules[0]
+	if r.Id != "test-seq-scan" || r.Level != "" || r.Severity != "info" {
+		t.Errorf("first rule = %+v", r)
+	}
+	if r.Description != "Seq Scan with an estimate" {
+		t.Errorf("description = %q, comment not removed", r.Description)
+	}
+	if r.Cause != "Scan on {{object}} estimated at {{rows}} rows" {
+		t.Errorf("cause = %q, quotes not removed", r.Cause)
+	}
+	if strings.Join(r.Optimizers, ",") != "orca,legacy" {
+		t.Errorf("optimizers = %v", r.Optimizers)
+	}
+
+	r = rules[1]
+	if r.Level != "explain" || r.Cause != "{{motions}} motions # not a comment" {
+		t.Errorf("second rule = %+v", r)
+	}
+
+	// JSON is read in to the same rules
+	json := `[{"id": "test-json", "match": "rows > 1", "cause": "{{rows}} rows", "optimizers": ["orca"]}]`
+	rules, err = ReadRuleFile(writeTestFile(t, "rules.json", json))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Id != "test-json" || rules[0].Optimizers[0] != "orca" {
+		t.Errorf("JSON rules = %+v", rules)
+	}
+}
+
+func TestReadRuleFileErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"id: no-list\n", "line 1: cannot unmarshal !!map into []plan.Rule"},
+		{"- id: a\n  colour: red\n", "line 2: field colour not found in type plan.Rule"},
+		{"- id: a\n  match rows > 1\n", "could not find expected ':'"},
+		// A value starting with { is a flow mapping unless quoted
+		{"- id: a\n  cause: {{rows}} rows\n", "did not find expected key"},
+	}
+
+	for _, test := range tests {
+		_, err := ReadRuleFile(writeTestFile(t, "rules.yml", test.content))
+		if err == nil || strings.Contains(err.Error(), test.want) == false {
+			t.Errorf("ReadRuleFile(%q) error = %v, want %q", test.content, err, test.want)
+		}
+	}
+}
+
+func TestRuleCompileErrors(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		want string
+	}{
+		{Rule{Match: "rows > 1", Cause: "x"}, "rule without an id"},
+		{Rule{Id: "a", Cause: "x"}, "rule a: match and cause are required"},
+		{Rule{Id: "a", Match: "rows > 1", Cause: "x", Level: "slice"}, "rule a: level must be node or explain"},
+		{Rule{Id: "a", Match: "rows >", Cause: "x"}, "rule a: match: unexpected \"end of expression\" at position 7"},
+		{Rule{Id: "a", Match: "rows > 1", Level: "explain", Cause: "x"}, "rule a: match: unknown field \"rows\" at position 1"},
+		{Rule{Id: "a", Match: "rows > 1", Cause: "{{rows +}}"}, "rule a: cause: {{rows +}}: unexpected \"end of expression\" at position 7"},
+		{Rule{Id: "a", Match: "rows > 1", Cause: "x", Severity: "fatal"}, "rule a: unknown severity \"fatal\""},
+	}
+
+	for _, test := range tests {
+		_, _, err := test.rule.Compile()
+		if err == nil || err.Error() != test.want {
+			t.Errorf("Compile(%+v) error = %v, want %q", test.rule, err, test.want)
+		}
+	}
+}
+
+// Run the rules on every plan in testdata and compare the warnings with the
+// nodes the rules should match
+func TestRulesOnTestdata(t *testing.T) {
+	rules, err := ReadRuleFile(writeTestFile(t, "rules.yml", testRulesYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanRule, _, err := rules[0].Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, motionRule, err := rules[1].Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scanRule.Severity != SeverityInfo || motionRule.Severity != SeverityWarning {
+		t.Errorf("severities = %s, %s", scanRule.Severity, motionRule.Severity)
+	}
+
+	matched := 0
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+		f := NewFindings()
+		f.Explain = e
+		for _, n := range e.Nodes {
+			scanRule.Exec(n, f)
+		}
+		motionRule.Exec(e, f)
+
+		for _, n := range e.Nodes {
+			want := n.Category == CategoryScan && n.Rows > 1000
+			warnings := f.NodeWarnings[n]
+			if want != (len(warnings) == 1) {
+				t.Errorf("%s: node #%d %s has %d warnings, want match %t", filename, n.Id, n.Operator, len(warnings), want)
+				continue
+			}
+			if want {
+				matched++
+				cause := "Scan on " + n.Object + " estimated at " + formatExprValue(float64(n.Rows)) + " rows"
+				if warnings[0].Cause != cause {
+					t.Errorf("%s: node #%d cause = %q, want %q", filename, n.Id, warnings[0].Cause, cause)
+				}
+			}
+		}
+
+		motions := len(e.FindNodes(ByCategory(CategoryMotion)))
+		if (motions >= 3) != (len(f.Warnings) == 1) {
+			t.Errorf("%s: %d motions and %d plan warnings", filename, motions, len(f.Warnings))
+		} else if motions >= 3 {
+			statement := "SET gp_segments_for_planner = " + formatExprValue(float64(motions)) + ";"
+			if f.Warnings[0].Resolution != statement {
+				t.Errorf("%s: resolution = %q, want %q", filename, f.Warnings[0].Resolution, statement)
+			}
+		}
+	}
+	if matched == 0 {
+		t.Errorf("the scan rule did not match any node in testdata")
+	}
+}
+
+// Loaded rules run with the built in checks, nothing is registered when a
+// rule is invalid
+func TestLoadRuleFile(t *testing.T) {
+	nodeChecks, explainChecks := NODECHECKS, EXPLAINCHECKS
+	defer func() {
+		NODECHECKS, EXPLAINCHECKS = nodeChecks, explainChecks
+	}()
+
+	err := LoadRuleFile(writeTestFile(t, "rules.yml", testRulesYAML+"\n- id: spill-files\n  match: rows > 1\n  cause: x\n"))
+	if err == nil || strings.HasSuffix(err.Error(), "check \"spill-files\" is already registered") == false {
+		t.Errorf("LoadRuleFile() error = %v", err)
+	}
+	if len(NODECHECKS) != len(nodeChecks) || len(EXPLAINCHECKS) != len(explainChecks) {
+		t.Fatalf("checks registered from an invalid rule file")
+	}
+
+	if err := LoadRuleFile(writeTestFile(t, "rules.yml", testRulesYAML)); err != nil {
+		t.Fatal(err)
+	}
+	e := loadTestExplain(t, "../testdata/explain14.txt")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"test-motions"}})
+	if len(f.Warnings) != 1 || f.Warnings[0].CheckId != "test-motions" || f.Warnings[0].Severity != SeverityWarning {
+		t.Errorf("test-motions warnings = %+v", f.Warnings)
+	}
+}
@@ -0,0 +1,239 @@
+package plan
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Segment skew
+//
+// EXPLAIN ANALYZE reports the average over the segments and the segment with
+// the highest value for the rows, executor memory and work_mem of a node:
+//
+//	Rows out:  Avg 71116.4 rows x 40 workers.  Max 71617 rows (seg7) with 8100 ms to first row, ...
+//	Executor memory:  4579457K bytes avg, 5006083K bytes max (seg9).
+//	Work_mem used:  31573K bytes avg, 63481K bytes max (seg1).
+//
+// The skew coefficient of each measure is max / avg, 1 when every segment
+// did the same. Node.Skew is the highest coefficient of the node and
+// Node.SkewSegment the segment it points to. Rows below skewMinRows and
+// memory below skewMinMemory are not compared.
+//
+// Only the time of the segment with the most rows is reported, so the time
+// the other segments wait for it is estimated from the rows:
+//
+//	wait = MsNode x (1 - avg / max)
+//
+// A segment that is the maximum of most skewed nodes, across different
+// tables and slices, points to a slow host rather than the distribution of
+// one table. SkewSummary() counts this for the plan summary and the
+// straggler-segment check.
+
+// Rows and memory below these are not compared between segments
+const (
+	skewMinRows   = 1000
+	skewMinMemory = Megabyte
+)
+
+// Skew of one measure of a node
+type SkewMeasure struct {
+	Kind        string // rows, executor memory or work_mem
+	Avg         float64
+	Max         float64
+	Segment     string
+	Coefficient float64
+}
+
+// Skew of the segments across the plan
+type SkewSummary struct {
+	SkewedNodes    int    // Nodes with a skew coefficient of at least the factor
+	Straggler      string // Segment causing the skew of most skewed nodes
+	StragglerNodes int    // Skewed nodes caused by the Straggler
+}
+
+// Return the skew of each measure reported for the node
+func (n *Node) SkewMeasures() []SkewMeasure {
+	measures := []SkewMeasure{}
+	add := func(kind string, avg float64, max float64, segment string) {
+		if avg > 0 && max >= avg && segment != "" {
+			measures = append(measures, SkewMeasure{kind, avg, max, segment, max / avg})
+		}
+	}
+	if n.AvgRows.Valid && n.Workers.Value > 1 && n.MaxRows.Value >= skewMinRows {
+		add("rows", n.AvgRows.Value, n.MaxRows.Value, n.MaxSeg)
+	}
+	if n.ExecMemAvg.Valid && n.ExecMemMax.Value >= skewMinMemory {
+		add("executor memory", float64(n.ExecMemAvg.Value), float64(n.ExecMemMax.Value), n.ExecMemSeg)
+	}
+	if n.AvgMem.Valid && n.MaxMem.Value >= skewMinMemory {
+		add("work_mem", float64(n.AvgMem.Value), float64(n.MaxMem.Value), n.WorkMemSeg)
+	}
+	return measures
+}
+
+// Return the skew of one kind of measure
+func (n *Node) skewOf(kind string) (SkewMeasure, bool) {
+	for _, m := range n.SkewMeasures() {
+		if m.Kind == kind {
+			return m, true
+		}
+	}
+	return SkewMeasure{}, false
+}
+
+// Populate Skew and SkewSegment on every analyzed node
+func (e *Explain) calculateSkew() {
+	for _, n := range e.Nodes {
+		n.Skew = OptionalFloat{}
+		n.SkewSegment = ""
+		for _, m := range n.SkewMeasures() {
+			if n.Skew.Valid == false || m.Coefficient > n.Skew.Value {
+				n.Skew = validFloat(m.Coefficient)
+				n.SkewSegment = m.Segment
+			}
+		}
+	}
+}
+
+// Count the skewed nodes and the segment causing the skew of most of them
+func (e *Explain) SkewSummary(factor float64) SkewSummary {
+	s := SkewSummary{}
+	bySegment := map[string]int{}
+	for _, n := range e.Nodes {
+		if n.Skew.Valid == false || n.Skew.Value < factor {
+			continue
+		}
+		s.SkewedNodes++
+		bySegment[n.SkewSegment]++
+	}
+
+	segments := []string{}
+	for segment := range bySegment {
+		segments = append(segments, segment)
+	}
+	sort.Strings(segments)
+	for _, segment := range segments {
+		if bySegment[segment] > s.StragglerNodes {
+			s.Straggler = segment
+			s.StragglerNodes = bySegment[segment]
+		}
+	}
+	return s
+}
+
+var skewChecks = []NodeCheck{
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "time-skew",
+			Name:          "checkNodeTimeSkew",
+			Description:   "Segments waiting for a skewed segment",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategorySkew,
+			Severity:      SeverityWarning,
+			Documentation: "One segment processed skew_factor times the average rows, so the others wait for it. The wait is estimated from the self time of the node and the rows, as only the time of the busiest segment is reported. Reported when the estimated wait is skew_min_ms or more.",
+			Parameters:    []string{"skew_factor", "skew_min_ms"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			m, ok := n.skewOf("rows")
+			if ok == false || m.Coefficient < f.Params.Float("skew_factor") || n.MsNode.Valid == false {
+				return
+			}
+			wait := time.Duration(float64(n.MsNode.Value) * (1 - m.Avg/m.Max))
+			if wait < time.Duration(f.Params.Float("skew_min_ms"))*time.Millisecond {
+				return
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      fmt.Sprintf("%s processed %.1fx the average rows (%.0f vs %.0f), the other segments wait an estimated %s of %s", m.Segment, m.Coefficient, m.Max, m.Avg, FormatDuration(wait), n.MsNode),
+				Resolution: "Check the distribution key of the tables for values with many rows"})
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "memory-skew",
+			Name:          "checkNodeMemorySkew",
+			Description:   "One segment using much more memory than the others",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategorySkew,
+			Severity:      SeverityWarning,
+			Documentation: "The executor memory or work_mem of one segment is skew_factor times the average and at least skew_min_memory_mb. The segment is the first to spill or run out of memory, usually because it received more rows or larger groups.",
+			Parameters:    []string{"skew_factor", "skew_min_memory_mb"},
+		},
+		Exec: func(n *Node, f *Findings) {
+			for _, kind := range []string{"executor memory", "work_mem"} {
+				m, ok := n.skewOf(kind)
+				if ok == false || m.Coefficient < f.Params.Float("skew_factor") {
+					continue
+				}
+				if m.Max < f.Params.Float("skew_min_memory_mb")*float64(Megabyte) {
+					continue
+				}
+				f.AddNodeWarning(n, Warning{
+					Cause:      fmt.Sprintf("%s used %s of %s, %.1fx the average %s", m.Segment, ByteSize(m.Max), kind, m.Coefficient, ByteSize(m.Avg)),
+					Resolution: "Check the rows each segment receives, e.g. the distribution key or grouping columns"})
+				return
+			}
+		}},
+	NodeCheck{
+		CheckInfo: CheckInfo{
+			Id:            "spill-skew",
+			Name:          "checkNodeSpillSkew",
+			Description:   "Spilling concentrated on few segments",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategorySkew,
+			Severity:      SeverityWarning,
+			Documentation: "Only a few of the segments spilled to workfiles, at most half of them. The other segments had enough memory, so the spilling segments received more rows than the rest.",
+		},
+		Exec: func(n *Node, f *Findings) {
+			segments := n.SegmentCount()
+			if n.SpillFile.Value < 1 || segments.Value < 4 || n.SpillFile.Value*2 > segments.Value {
+				return
+			}
+			cause := fmt.Sprintf("Only %d of %d segments spilled", n.SpillFile.Value, segments.Value)
+			if m, ok := n.skewOf("work_mem"); ok {
+				cause += fmt.Sprintf(", %s used %.1fx the average work_mem", m.Segment, m.Coefficient)
+			}
+			f.AddNodeWarning(n, Warning{
+				Cause:      cause,
+				Resolution: "Check the rows each segment receives, e.g. the distribution key or grouping columns"})
+		}},
+}
+
+var skewExplainChecks = []ExplainCheck{
+	ExplainCheck{
+		CheckInfo: CheckInfo{
+			Id:            "straggler-segment",
+			Name:          "checkExplainStragglerSegment",
+			Description:   "Same segment slowest across the plan",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategorySkew,
+			Severity:      SeverityWarning,
+			Documentation: "The same segment has the most rows or memory in most of the nodes with a skew coefficient of skew_factor or more, at least straggler_min_nodes of them. When this happens across different tables it points to the host of the segment, e.g. slow disks, rather than the distribution of the data.",
+			Parameters:    []string{"skew_factor", "straggler_min_nodes"},
+		},
+		Exec: func(e *Explain, f *Findings) {
+			s := e.SkewSummary(f.Params.Float("skew_factor"))
+			if int64(s.StragglerNodes) < f.Params.Int("straggler_min_nodes") || s.StragglerNodes*2 <= s.SkewedNodes {
+				return
+			}
+			f.AddWarning(Warning{
+				Cause:      fmt.Sprintf("%s is the busiest segment in %d of %d skewed nodes", s.Straggler, s.StragglerNodes, s.SkewedNodes),
+				Resolution: fmt.Sprintf("Check the host of %s for hardware problems, and the distribution of the tables it reads", s.Straggler)})
+		}},
+}
+
+func init() {
+	for _, c := range skewChecks {
+		RegisterNodeCheck(c)
+	}
+	for _, c := range skewExplainChecks {
+		RegisterExplainCheck(c)
+	}
+}
@@ -0,0 +1,134 @@
+package plan
+
+import (
+	"testing"
+)
+
+func TestSkewMeasures(t *testing.T) {
+	// Sort #5 of explain12:
+	//   Rows out:  Avg 149826.4 rows x 20 workers.  Max 163647 rows (seg9)
+	//   Executor memory:  31573K bytes avg, 63481K bytes max (seg1).
+	//   Work_mem used:  31573K bytes avg, 63481K bytes max (seg1).
+	n := loadTestExplain(t, "../testdata/explain12.txt").Nodes[5]
+	measures := n.SkewMeasures()
+	want := []struct {
+		kind    string
+		segment string
+	}{{"rows", "seg9"}, {"executor memory", "seg1"}, {"work_mem", "seg1"}}
+	if len(measures) != len(want) {
+		t.Fatalf("measures = %+v", measures)
+	}
+	for i, m := range measures {
+		if m.Kind != want[i].kind || m.Segment != want[i].segment || m.Coefficient != m.Max/m.Avg {
+			t.Errorf("measure %d = %+v, want %s on %s", i, m, want[i].kind, want[i].segment)
+		}
+	}
+	if n.ExecMemAvg != validBytes(31573*Kilobyte) || n.ExecMemMax != validBytes(63481*Kilobyte) || n.ExecMemSeg != "seg1" || n.WorkMemSeg != "seg1" {
+		t.Errorf("executor memory %s avg, %s max (%s), work_mem on %s", n.ExecMemAvg, n.ExecMemMax, n.ExecMemSeg, n.WorkMemSeg)
+	}
+	// The highest coefficient is the executor memory
+	if n.Skew.Valid == false || int(n.Skew.Value*100) != 201 || n.SkewSegment != "seg1" {
+		t.Errorf("skew = %s on %s, want 2.01 on seg1", n.Skew, n.SkewSegment)
+	}
+
+	// Redistribute Motion 2:2 of explain13: Avg 2333.5 rows x 2 workers.  Max 3333 rows (seg1)
+	n = loadTestExplain(t, "../testdata/explain13.txt").Nodes[4]
+	if m, ok := n.skewOf("rows"); ok == false || m.Avg != 2333.5 || m.Max != 3333 || m.Segment != "seg1" {
+		t.Errorf("rows skew = %+v", m)
+	}
+
+	// Rows of a single segment and EXPLAIN without ANALYZE are not compared
+	for _, filename := range []string{"../testdata/explain01.txt", "../testdata/explain17.txt"} {
+		for _, n := range loadTestExplain(t, filename).Nodes {
+			if n.Skew.Valid {
+				t.Errorf("%s: node #%d skew %s", filename, n.Id, n.Skew)
+			}
+		}
+	}
+}
+
+func TestSkewSummary(t *testing.T) {
+	tests := []struct {
+		filename string
+		factor   float64
+		want     SkewSummary
+	}{
+		// seg177, seg187 and seg232 cause the skew of 2 nodes each
+		{"../testdata/explain15.txt", 2, SkewSummary{8, "seg177", 2}},
+		{"../testdata/explain15.txt", 200, SkewSummary{2, "seg130", 1}},
+		{"../testdata/explain12.txt", 2, SkewSummary{1, "seg1", 1}},
+		{"../testdata/explain05.txt", 2, SkewSummary{}},
+	}
+
+	for _, test := range tests {
+		if got := loadTestExplain(t, test.filename).SkewSummary(test.factor); got != test.want {
+			t.Errorf("%s: skew summary with factor %v = %+v, want %+v", test.filename, test.factor, got, test.want)
+		}
+	}
+
+	if s := loadTestExplain(t, "../testdata/explain15.txt").Summarize(5, nil); s.Skew != (SkewSummary{8, "seg177", 2}) {
+		t.Errorf("summary skew = %+v", s.Skew)
+	}
+	// The summary uses the skew_factor of the params
+	p := NewParams()
+	p.Set("skew_factor", 200)
+	if s := loadTestExplain(t, "../testdata/explain15.txt").Summarize(5, p); s.Skew != (SkewSummary{2, "seg130", 1}) {
+		t.Errorf("summary skew with skew_factor 200 = %+v", s.Skew)
+	}
+}
+
+// Warnings of the skew checks on testdata with the default thresholds
+func TestSkewChecksTestdata(t *testing.T) {
+	got := testdataWarnings(t, "time-skew", "memory-skew", "spill-skew", "straggler-segment")
+	compareTestdataWarnings(t, got, map[string][]string{
+		// The Hash Joins and a motion where the others wait more than a second
+		"explain15.txt": {"#1 time-skew", "#3 time-skew", "#4 time-skew"},
+	})
+}
+
+func TestSkewCheckCauses(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain15.txt")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"time-skew"}})
+	want := "seg187 processed 64.7x the average rows (6875838 vs 106297), the other segments wait an estimated 1.4 min of 1.5 min"
+	if w := f.NodeWarnings[e.Nodes[1]]; len(w) != 1 || w[0].Cause != want {
+		t.Errorf("time-skew warnings = %+v, want %q", w, want)
+	}
+
+	// The skewed memory of explain12 is below 100 MB
+	e = loadTestExplain(t, "../testdata/explain12.txt")
+	if f := e.CheckWithConfig(CheckConfig{Enabled: []string{"memory-skew"}}); len(f.NodeWarnings) != 0 {
+		t.Errorf("memory-skew warnings = %+v", f.NodeWarnings)
+	}
+	p := NewParams()
+	p.Set("skew_min_memory_mb", 50)
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"memory-skew"}, Params: p})
+	want = "seg1 used 62.0 MB of executor memory, 2.0x the average 30.8 MB"
+	if w := f.NodeWarnings[e.Nodes[5]]; len(f.NodeWarnings) != 1 || len(w) != 1 || w[0].Cause != want {
+		t.Errorf("memory-skew warnings = %+v, want %q", f.NodeWarnings, want)
+	}
+
+	// seg1 is the busiest segment of the only skewed node
+	p = NewParams()
+	p.Set("straggler_min_nodes", 1)
+	f = e.CheckWithConfig(CheckConfig{Enabled: []string{"straggler-segment"}, Params: p})
+	if len(f.Warnings) != 1 || f.Warnings[0].Cause != "seg1 is the busiest segment in 1 of 1 skewed nodes" {
+		t.Errorf("straggler-segment warnings = %+v", f.Warnings)
+	}
+}
+
+// explain12 with 3 of the segments of Sort #5 spilling
+func TestSpillSkew(t *testing.T) {
+	e := loadEditedExplain(t, "../testdata/explain12.txt",
+		"63481K bytes max (seg1). Workfile: (0 spilling, 0 reused)", "63481K bytes max (seg1). Workfile: (3 spilling, 0 reused)")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"spill-skew"}})
+	want := "Only 3 of 40 segments spilled, seg1 used 2.0x the average work_mem"
+	if w := f.NodeWarnings[e.Nodes[5]]; len(f.NodeWarnings) != 1 || len(w) != 1 || w[0].Cause != want {
+		t.Errorf("spill-skew warnings = %+v, want %q", f.NodeWarnings, want)
+	}
+
+	// Both segments of explain05 spilled
+	e = loadTestExplain(t, "../testdata/explain05.txt")
+	if f := e.CheckWithConfig(CheckConfig{Enabled: []string{"spill-skew"}}); len(f.NodeWarnings) != 0 {
+		t.Errorf("spill-skew warnings = %+v", f.NodeWarnings)
+	}
+}
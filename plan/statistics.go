@@ -0,0 +1,157 @@
+package plan
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Statistics freshness
+//
+// A table may be scanned several times in one plan: by a self join, from a
+// subquery or as the child partitions of a partitioned table. The estimate of
+// a single scan says little, but together the scans show whether the
+// optimizer knows the table:
+//
+//	never analyzed  every scan estimates 1 row and, with EXPLAIN ANALYZE,
+//	                at least one scan returned more
+//	stale           a scan estimates 1 row but returned more, or the rows
+//	                of a scan are misestimated by stale_statistics_factor
+//	ok              otherwise
+//
+// TableStatistics() groups the scans per table, child partitions under the
+// parent table and index scans under the table of the index.
+
+// Statistics status of a table
+const (
+	StatisticsNeverAnalyzed = "never analyzed"
+	StatisticsStale         = "stale"
+	StatisticsOk            = "ok"
+)
+
+// Row estimates of all scans of a table
+type TableStatistics struct {
+	Table     string
+	Status    string
+	Estimated int64         // Highest estimated rows of the scans
+	Actual    OptionalCount // Highest actual rows per segment of the scans, EXPLAIN ANALYZE only
+	QError    OptionalFloat // Worst q-error of the scans, EXPLAIN ANALYZE only
+	Scans     []*Node       `json:"-"`
+}
+
+// Group the scans of tables per table, in plan order, and classify the
+// statistics of each table using the factor
+func (e *Explain) TableStatistics(factor float64) []TableStatistics {
+	tables := []*TableStatistics{}
+	byTable := map[string]*TableStatistics{}
+	for _, n := range e.Nodes {
+		if n.Category != CategoryScan || n.TableName() == "" {
+			continue
+		}
+		t, ok := byTable[n.TableName()]
+		if ok == false {
+			t = &TableStatistics{Table: n.TableName()}
+			byTable[n.TableName()] = t
+			tables = append(tables, t)
+		}
+		t.Scans = append(t.Scans, n)
+	}
+
+	result := []TableStatistics{}
+	for _, t := range tables {
+		t.classify(factor)
+		result = append(result, *t)
+	}
+	return result
+}
+
+// Work out the estimates and status from the scans
+func (t *TableStatistics) classify(factor float64) {
+	allOne := true
+	oneExceeded := false
+	for _, n := range t.Scans {
+		if n.Rows > t.Estimated {
+			t.Estimated = n.Rows
+		}
+		if n.Rows != 1 {
+			allOne = false
+		}
+		if n.ActualRowsPerSeg.Valid && n.ActualRowsPerSeg.Value >= t.Actual.Value {
+			t.Actual = n.ActualRowsPerSeg
+		}
+		if n.QError.Valid && n.QError.Value >= t.QError.Value {
+			t.QError = n.QError
+		}
+		if n.Rows == 1 && n.IsAnalyzed && (n.ActualRows.Value > 1 || n.AvgRows.Value > 1) {
+			oneExceeded = true
+		}
+	}
+
+	switch {
+	case allOne && (t.Actual.Valid == false || oneExceeded):
+		t.Status = StatisticsNeverAnalyzed
+	case oneExceeded, t.QError.Valid && t.QError.Value >= factor:
+		t.Status = StatisticsStale
+	default:
+		t.Status = StatisticsOk
+	}
+}
+
+// Describe the statistics of a table, e.g.
+// "sales: stale, 2 scans, estimated 100 rows, actual 52000 rows per segment"
+func (t TableStatistics) String() string {
+	s := fmt.Sprintf("%s: %s, %s, estimated %s", t.Table, t.Status, plural(len(t.Scans), "scan"), plural(int(t.Estimated), "row"))
+	if t.Actual.Valid {
+		s += fmt.Sprintf(", actual %s rows per segment", t.Actual)
+	}
+	return s
+}
+
+var statisticsChecks = []ExplainCheck{
+	ExplainCheck{
+		CheckInfo: CheckInfo{
+			Id:            "table-statistics",
+			Name:          "checkExplainTableStatistics",
+			Description:   "Tables never analyzed or with stale statistics",
+			CreatedAt:     "2026-10-19",
+			Scope:         []string{"orca", "legacy"},
+			Dialects:      []string{DialectGreenplum, DialectHawq},
+			Category:      CheckCategoryStatistics,
+			Severity:      SeverityWarning,
+			Documentation: "All scans of each table are compared. A table is never analyzed when every scan estimates 1 row, and stale when a scan estimating 1 row returned more or its rows are misestimated by stale_statistics_factor or more. One ANALYZE statement is suggested per table, never analyzed tables first.",
+			Parameters:    []string{"stale_statistics_factor"},
+		},
+		Exec: func(e *Explain, f *Findings) {
+			tables := []TableStatistics{}
+			for _, t := range e.TableStatistics(f.Params.Float("stale_statistics_factor")) {
+				if t.Status != StatisticsOk {
+					tables = append(tables, t)
+				}
+			}
+			if len(tables) == 0 {
+				return
+			}
+			sort.SliceStable(tables, func(i, j int) bool {
+				return tables[i].Status == StatisticsNeverAnalyzed && tables[j].Status != StatisticsNeverAnalyzed
+			})
+
+			details := ""
+			remediation := []string{}
+			for i, t := range tables {
+				if i > 0 {
+					details += ", "
+				}
+				details += fmt.Sprintf("%s (%s)", t.Table, t.Status)
+				remediation = append(remediation, analyzeStatement(t.Table))
+			}
+			f.AddWarning(Warning{
+				Cause:       fmt.Sprintf("Statistics missing or stale on %s: %s", plural(len(tables), "table"), details),
+				Resolution:  "Run ANALYZE on the tables so the optimizer knows their size and data distribution",
+				Remediation: remediation})
+		}},
+}
+
+func init() {
+	for _, c := range statisticsChecks {
+		RegisterExplainCheck(c)
+	}
+}
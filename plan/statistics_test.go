@@ -0,0 +1,99 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTableStatistics(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     []string
+	}{
+		// EXPLAIN estimating 1 row
+		{"../testdata/explain01.txt", []string{"sales: never analyzed, 1 scan, estimated 1 row"}},
+		{"../testdata/explain02.txt", []string{"sales: never analyzed, 1 scan, estimated 1 row, actual 2750 rows per segment"}},
+		// The default partition estimates 1 row and returned none, the other 2477
+		{"../testdata/explain04.txt", []string{"sales: stale, 2 scans, estimated 2477 rows, actual 2750 rows per segment"}},
+		{"../testdata/explain14.txt", []string{
+			"adwv_ac: stale, 1 scan, estimated 370 rows, actual 0 rows per segment",
+			"adwd_date: ok, 2 scans, estimated 34 rows, actual 33 rows per segment",
+			// Estimated 1 row and returned none
+			"adwd_insertion_order: ok, 1 scan, estimated 1 row, actual 0 rows per segment",
+			"adwt_rtb_global_placement: never analyzed, 1 scan, estimated 1 row",
+			"adwt_rtb_dlvd_event_creative_time_info: ok, 1 scan, estimated 1 row, actual 0 rows per segment",
+			"adwd_adserver_insertion_order: stale, 1 scan, estimated 44 rows, actual 0 rows per segment",
+			"adwt_activity_ads: ok, 1 scan, estimated 1 row, actual 0 rows per segment",
+		}},
+		// Index scans are counted for the table of the index
+		{"../testdata/explain17.txt", []string{
+			"f_fenix_itraffic: ok, 1 scan, estimated 42317088 rows",
+			"d_date: never analyzed, 1 scan, estimated 1 row",
+			"d_is_circuits_old: ok, 1 scan, estimated 11098 rows",
+			"d_customer_account: never analyzed, 2 scans, estimated 1 row",
+		}},
+		{"../testdata/explain18.txt", []string{"bigtable: ok, 2 scans, estimated 500859 rows, actual 500000 rows per segment"}},
+	}
+
+	for _, test := range tests {
+		got := []string{}
+		for _, table := range loadTestExplain(t, test.filename).TableStatistics(10) {
+			got = append(got, table.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: table statistics = %q, want %q", test.filename, got, test.want)
+		}
+	}
+
+	// Child partitions are grouped under the partitioned table
+	tables := loadTestExplain(t, "../testdata/explain19.txt").TableStatistics(10)
+	if len(tables) != 1 || tables[0].Table != "mi_asset_position" || len(tables[0].Scans) != 1196 {
+		t.Errorf("explain19 table statistics = %v", tables)
+	}
+
+	// The q-error of 15 of explain21 is only stale from a factor of 15
+	for _, test := range []struct {
+		factor float64
+		want   string
+	}{{10, StatisticsStale}, {15, StatisticsStale}, {16, StatisticsOk}} {
+		tables := loadTestExplain(t, "../testdata/explain21.txt").TableStatistics(test.factor)
+		if len(tables) != 1 || tables[0].Status != test.want {
+			t.Errorf("explain21 with factor %v = %v, want %s", test.factor, tables, test.want)
+		}
+	}
+}
+
+// Warnings of the statistics check on testdata with the default factor
+func TestStatisticsCheckTestdata(t *testing.T) {
+	got := testdataWarnings(t, "table-statistics")
+	want := map[string][]string{}
+	for _, filename := range []string{"explain01.txt", "explain02.txt", "explain04.txt", "explain05.txt", "explain07.txt",
+		"explain13.txt", "explain14.txt", "explain15.txt", "explain16.txt", "explain17.txt", "explain21.txt"} {
+		want[filename] = []string{"table-statistics"}
+	}
+	compareTestdataWarnings(t, got, want)
+}
+
+// Never analyzed tables come first, with one ANALYZE per table
+func TestStatisticsCheckExplain14(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain14.txt")
+	f := e.CheckWithConfig(CheckConfig{Enabled: []string{"table-statistics"}})
+	want := "Statistics missing or stale on 3 tables: adwt_rtb_global_placement (never analyzed), adwv_ac (stale), adwd_adserver_insertion_order (stale)"
+	if len(f.Warnings) != 1 || f.Warnings[0].Cause != want {
+		t.Fatalf("table-statistics warnings = %+v, want %q", f.Warnings, want)
+	}
+	remediation := "ANALYZE adwt_rtb_global_placement; ANALYZE adwv_ac; ANALYZE adwd_adserver_insertion_order;"
+	if got := strings.Join(f.Warnings[0].Remediation, " "); got != remediation {
+		t.Errorf("remediation = %q, want %q", got, remediation)
+	}
+
+	if s := e.Summarize(5, nil); len(s.TableStatistics) != 7 || s.TableStatistics[3].Status != StatisticsNeverAnalyzed {
+		t.Errorf("summary table statistics = %v", s.TableStatistics)
+	}
+	// The summary uses the stale_statistics_factor of the params
+	p := NewParams()
+	p.Set("stale_statistics_factor", 16)
+	if s := loadTestExplain(t, "../testdata/explain21.txt").Summarize(5, p); len(s.TableStatistics) != 1 || s.TableStatistics[0].Status != StatisticsOk {
+		t.Errorf("explain21 summary table statistics with factor 16 = %v", s.TableStatistics)
+	}
+}
@@ -0,0 +1,250 @@
+package plan
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Plan summary
+//
+// A plan level overview so a plan can be judged without reading every node.
+// It is built in ApplyFindings() as it includes the warning counts.
+//
+// The health score starts at 100 and every warning takes points off
+// depending on its severity (SeverityPenalty). It can not go below 0.
+// A root cause is only counted once: warnings of the same check on the same
+// table or object are counted once, and so are warnings whose remediation
+// is already suggested by a warning counted before, e.g. estimated-rows-one,
+// row-misestimate and table-statistics all suggesting "ANALYZE sales;". The
+// most severe warnings are counted first.
+//
+//	80 - 100  good
+//	50 - 79   fair
+//	 0 - 49   poor
+
+// Points taken off the health score for each warning
+var SeverityPenalty = map[Severity]int{
+	SeverityInfo:     2,
+	SeverityWarning:  10,
+	SeverityCritical: 25,
+}
+
+// Number of nodes listed in Summary.TopNodesByTime and Summary.TopNodesByCost
+var SummaryTopNodes = 5
+
+var (
+	sliceStatsNamePattern   = regexp.MustCompile(`\(slice([0-9]+)\)`)
+	sliceStatsMemoryPattern = regexp.MustCompile(`(Executor|Peak) memory: ([0-9]+)K bytes( avg x [0-9]+ workers, ([0-9]+)K bytes max \((seg[0-9]+)\))?`)
+)
+
+// Plan level overview of an Explain
+type Summary struct {
+	Warnings           int              // Total number of warnings, plan and node
+	Suppressed         int              // Number of warnings suppressed by a waiver
+	WarningsBySeverity map[Severity]int // Number of warnings of each severity
+	TopNodesByTime     []*Node          `json:"-"` // Nodes with the highest MsNode, EXPLAIN ANALYZE only
+	TopNodesByCost     []*Node          `json:"-"` // Nodes with the highest NodeCost
+	SpillNodes         int              // Number of nodes that spilled to workfiles
+	SpillFiles         int64            // Number of spilling workfiles across all nodes
+	SpillBytes         OptionalBytes    // Bytes written to workfiles, EXPLAIN ANALYZE with workfile details only
+	PeakMemory         OptionalBytes    // Highest memory used by a segment in any slice
+	PeakMemorySlice    OptionalInt      // Slice using PeakMemory
+	PeakMemorySegment  string           // Segment using PeakMemory, empty if not reported
+	CoordinatorTime    OptionalDuration // Self time of the nodes on the coordinator, EXPLAIN ANALYZE only
+	CoordinatorPercent float64          // CoordinatorTime as a percentage of the runtime
+	Skew               SkewSummary      // Skewed nodes and the segment causing most of them
+	PartitionedTables  []PartitionedTable
+	TableStatistics    []TableStatistics // Statistics status of each table scanned
+	Slices             int
+	Motions            int
+	Score              int // Health score from 0 (poor) to 100 (good)
+}
+
+// Build the summary of the Explain with the thresholds of the params used
+// by the checks, or the defaults when params is nil.
+// Warnings must already be applied to the Explain and nodes.
+func (e *Explain) Summarize(top int, params *Params) Summary {
+	if params == nil {
+		params = NewParams()
+	}
+	s := Summary{
+		WarningsBySeverity: map[Severity]int{},
+	}
+
+	warnings := append([]Warning{}, e.Warnings...)
+	for _, n := range e.Nodes {
+		warnings = append(warnings, n.Warnings...)
+	}
+	for _, w := range warnings {
+		s.WarningsBySeverity[w.Severity]++
+	}
+	s.Warnings = len(warnings)
+	s.Suppressed = len(e.Suppressed)
+
+	// Slice 0 on the master always exists, even when no node runs in it
+	slices := map[int64]bool{0: true}
+	for _, n := range e.Nodes {
+		slices[n.SliceId()] = true
+		if n.Category == CategoryMotion {
+			s.Motions++
+		}
+		if n.SpillFile.Value > 0 || n.WorkfileBytes.Value > 0 {
+			s.SpillNodes++
+			s.SpillFiles += n.SpillFile.Value
+		}
+		if n.WorkfileBytes.Valid {
+			s.SpillBytes = validBytes(s.SpillBytes.Value + n.WorkfileBytes.Value)
+		}
+	}
+	s.Slices = len(slices)
+
+	s.TopNodesByCost = topNodes(e.Nodes, top, func(n *Node) (float64, bool) {
+		return n.NodeCost, n.NodeCost > 0
+	})
+	s.TopNodesByTime = topNodes(e.Nodes, top, func(n *Node) (float64, bool) {
+		return float64(n.MsNode.Value), n.MsNode.Value > 0
+	})
+
+	e.summarizeMemory(&s)
+	s.CoordinatorTime, s.CoordinatorPercent = e.CoordinatorTime()
+	s.Skew = e.SkewSummary(params.Float("skew_factor"))
+	s.PartitionedTables = e.PartitionedTables()
+	s.TableStatistics = e.TableStatistics(params.Float("stale_statistics_factor"))
+
+	s.Score = 100
+	for _, w := range scoredWarnings(warnings) {
+		s.Score -= SeverityPenalty[w.Severity]
+	}
+	if s.Score < 0 {
+		s.Score = 0
+	}
+
+	return s
+}
+
+// Return the warnings counted in the health score, one per root cause
+func scoredWarnings(warnings []Warning) []Warning {
+	sorted := append([]Warning{}, warnings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Severity > sorted[j].Severity
+	})
+
+	scored := []Warning{}
+	seen := map[string]bool{}
+	remediated := map[string]bool{}
+	for _, w := range sorted {
+		object := ""
+		if w.Node != nil {
+			if object = w.Node.TableName(); object == "" {
+				object = w.Node.Object
+			}
+		}
+		key := w.CheckId + "|" + object
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		known := len(w.Remediation) > 0
+		for _, statement := range w.Remediation {
+			if remediated[statement] == false {
+				known = false
+			}
+			remediated[statement] = true
+		}
+		if known {
+			continue
+		}
+		scored = append(scored, w)
+	}
+	return scored
+}
+
+// Describe the spilling, e.g. "52.8 MB written to 3 spilling workfiles in 2 nodes"
+func (s Summary) SpillDescription() string {
+	files := fmt.Sprintf("%s in %s", plural(int(s.SpillFiles), "spilling workfile"), plural(s.SpillNodes, "node"))
+	if s.SpillBytes.Valid {
+		return fmt.Sprintf("%s written to %s", s.SpillBytes, files)
+	}
+	return files
+}
+
+// Find the peak memory from the slice statistics.
+// "Peak memory" is reported by newer versions and preferred over "Executor memory".
+func (e *Explain) summarizeMemory(s *Summary) {
+	foundPeak := false
+	for _, stat := range e.SliceStats {
+		slice := OptionalInt{}
+		if m := sliceStatsNamePattern.FindStringSubmatch(stat); len(m) > 0 {
+			if i, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+				slice = validInt(i)
+			}
+		}
+
+		for _, m := range sliceStatsMemoryPattern.FindAllStringSubmatch(stat, -1) {
+			isPeak := m[1] == "Peak"
+			if foundPeak == true && isPeak == false {
+				continue
+			}
+
+			size := m[2]
+			segment := ""
+			if m[4] != "" {
+				size = m[4]
+				segment = m[5]
+			}
+			b, err := ParseByteSize(size + "K")
+			if err != nil {
+				continue
+			}
+
+			// Peak memory replaces any executor memory found so far
+			if isPeak == true && foundPeak == false {
+				foundPeak = true
+				s.PeakMemory = OptionalBytes{}
+			}
+
+			if s.PeakMemory.Valid == false || b > s.PeakMemory.Value {
+				s.PeakMemory = validBytes(b)
+				s.PeakMemorySlice = slice
+				s.PeakMemorySegment = segment
+			}
+		}
+	}
+}
+
+// Return up to limit nodes with the highest value, highest first.
+// Nodes are skipped when value returns false.
+func topNodes(nodes []*Node, limit int, value func(*Node) (float64, bool)) []*Node {
+	found := []*Node{}
+	for _, n := range nodes {
+		if _, ok := value(n); ok {
+			found = append(found, n)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		vi, _ := value(found[i])
+		vj, _ := value(found[j])
+		return vi > vj
+	})
+
+	if len(found) > limit {
+		found = found[:limit]
+	}
+
+	return found
+}
+
+// Describe the score as good, fair or poor
+func (s Summary) Rating() string {
+	switch {
+	case s.Score >= 80:
+		return "good"
+	case s.Score >= 50:
+		return "fair"
+	}
+	return "poor"
+}
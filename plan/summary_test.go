@@ -0,0 +1,143 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+// Load the explain and apply all checks so the summary is built
+func loadTestSummary(t *testing.T, filename string) (*Explain, Summary) {
+	e := loadTestExplain(t, filename)
+	e.ApplyFindings(e.Check())
+	return e, e.Summary
+}
+
+func TestSummaryExplain05(t *testing.T) {
+	_, s := loadTestSummary(t, "../testdata/explain05.txt")
+
+	if s.Slices != 3 || s.Motions != 2 {
+		t.Errorf("%d slices and %d motions, want 3 and 2", s.Slices, s.Motions)
+	}
+	if s.SpillNodes != 1 || s.SpillFiles != 2 || s.SpillBytes != validBytes(54048*Kilobyte) {
+		t.Errorf("%d spill files of %s in %d nodes, want 2 of 54048K in 1", s.SpillFiles, s.SpillBytes, s.SpillNodes)
+	}
+	if got := s.SpillDescription(); got != "52.8 MB written to 2 spilling workfiles in 1 node" {
+		t.Errorf("spill description = %q", got)
+	}
+
+	// Only Executor memory is reported, the max of the segments is used
+	if s.PeakMemory != validBytes(205136*Kilobyte) || s.PeakMemorySlice != validInt(2) || s.PeakMemorySegment != "seg0" {
+		t.Errorf("peak memory %s in slice %s %s, want 205136K in slice 2 seg0", s.PeakMemory, s.PeakMemorySlice, s.PeakMemorySegment)
+	}
+
+	if got := nodeIds(s.TopNodesByTime); sameIds(got, []int{6, 5, 7, 9, 1}) == false {
+		t.Errorf("top nodes by time = %v", got)
+	}
+	if got := nodeIds(s.TopNodesByCost); sameIds(got, []int{4, 9, 3, 8}) == false {
+		t.Errorf("top nodes by cost = %v", got)
+	}
+}
+
+// Peak memory is preferred over Executor memory when reported
+func TestSummaryPeakMemory(t *testing.T) {
+	_, s := loadTestSummary(t, "../testdata/explain13.txt")
+	if s.PeakMemory != validBytes(13372*Kilobyte) || s.PeakMemorySlice != validInt(2) || s.PeakMemorySegment != "seg1" {
+		t.Errorf("peak memory %s in slice %s %s, want 13372K in slice 2 seg1", s.PeakMemory, s.PeakMemorySlice, s.PeakMemorySegment)
+	}
+
+	// Without EXPLAIN ANALYZE there is no time or memory
+	_, s = loadTestSummary(t, "../testdata/explain01.txt")
+	if s.PeakMemory.Valid || len(s.TopNodesByTime) != 0 || len(s.TopNodesByCost) == 0 {
+		t.Errorf("explain01 summary has peak memory %s and %d nodes by time", s.PeakMemory, len(s.TopNodesByTime))
+	}
+}
+
+// The warnings add up and every root cause takes the penalty off the score
+func TestSummaryScoreTestdata(t *testing.T) {
+	for _, filename := range testExplainFiles(t) {
+		e, s := loadTestSummary(t, filename)
+
+		warnings := append([]Warning{}, e.Warnings...)
+		for _, n := range e.Nodes {
+			warnings = append(warnings, n.Warnings...)
+		}
+		bySeverity := map[Severity]int{}
+		for _, w := range warnings {
+			bySeverity[w.Severity]++
+		}
+		want := 100
+		for _, w := range scoredWarnings(warnings) {
+			want -= SeverityPenalty[w.Severity]
+		}
+		if s.Warnings != len(warnings) {
+			t.Errorf("%s: summary has %d warnings, want %d", filename, s.Warnings, len(warnings))
+		}
+		for severity, count := range bySeverity {
+			if s.WarningsBySeverity[severity] != count {
+				t.Errorf("%s: summary has %d %s warnings, want %d", filename, s.WarningsBySeverity[severity], severity, count)
+			}
+		}
+
+		if want < 0 {
+			want = 0
+		}
+		if s.Score != want {
+			t.Errorf("%s: score %d with %d warnings, want %d", filename, s.Score, len(warnings), want)
+		}
+
+		if len(s.TopNodesByCost) > SummaryTopNodes || len(s.TopNodesByTime) > SummaryTopNodes {
+			t.Errorf("%s: more than %d top nodes", filename, SummaryTopNodes)
+		}
+		for i := 1; i < len(s.TopNodesByCost); i++ {
+			if s.TopNodesByCost[i].NodeCost > s.TopNodesByCost[i-1].NodeCost {
+				t.Errorf("%s: top nodes by cost not in order", filename)
+			}
+		}
+	}
+}
+
+// Warnings of explain05 sharing a root cause are counted once
+func TestSummaryScoreExplain05(t *testing.T) {
+	e, s := loadTestSummary(t, "../testdata/explain05.txt")
+	warnings := append([]Warning{}, e.Warnings...)
+	for _, n := range e.Nodes {
+		warnings = append(warnings, n.Warnings...)
+	}
+
+	// SET statement_mem and ANALYZE sales are suggested by several checks,
+	// and row-misestimate reported on 3 nodes is counted once
+	got := []string{}
+	for _, w := range scoredWarnings(warnings) {
+		got = append(got, w.CheckId)
+	}
+	want := []string{"statement-mem", "table-statistics", "row-misestimate", "data-skew", "partition-scans"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("scored warnings = %v, want %v", got, want)
+	}
+	if s.Warnings != 12 || s.Score != 50 {
+		t.Errorf("score %d with %d warnings, want 50 with 12", s.Score, s.Warnings)
+	}
+}
+
+func TestSummaryRating(t *testing.T) {
+	tests := []struct {
+		score int
+		want  string
+	}{
+		{100, "good"},
+		{80, "good"},
+		{79, "fair"},
+		{50, "fair"},
+		{49, "poor"},
+		{0, "poor"},
+	}
+
+	for _, test := range tests {
+		if got := (Summary{Score: test.score}).Rating(); got != test.want {
+			t.Errorf("score %d rated %s, want %s", test.score, got, test.want)
+		}
+	}
+	if SeverityCritical.String() != "critical" || Severity(9).String() != "unknown" {
+		t.Errorf("severity names %s and %s", SeverityCritical, Severity(9))
+	}
+}
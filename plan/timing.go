@@ -0,0 +1,144 @@
+package plan
+
+import (
+	"sort"
+	"time"
+)
+
+// Time attribution model
+//
+// For each node EXPLAIN ANALYZE reports, for the segment that produced the
+// most rows, when the node started relative to the start of the query
+// ("start offset by") and how long it ran ("ms to end"). Together they give
+// the interval the node was running:
+//
+//	StartTime = MsOffset
+//	EndTime   = MsOffset + MsEnd
+//
+// When a node has no offset it inherits the StartTime of its parent, as
+// nodes in the same slice start together.
+//
+// The time spent in a node itself (MsNode) is its interval minus the time
+// any of its children (SubNodes and SubPlans) were running within that
+// interval. The child intervals are merged before being subtracted so:
+//   - Children in other slices (below a Motion) run concurrently on other
+//     segments and are only counted once where they overlap
+//   - Children that start after the parent, i.e. with a later offset, only
+//     count from when they started
+//   - Children that report a segment with a longer runtime than the parent
+//     are clipped to the parent's interval and can not produce a negative
+//     time
+//
+// A child that is rescanned ("ms to end of 96 scans", Scans > 1) reports the
+// time of all its scans added up, and the scans are spread over the running
+// time of the parent rather than forming one interval from its offset. Its
+// time is therefore subtracted as busy time, up to the parent's interval,
+// instead of being merged with the intervals of the other children.
+//
+// A Motion receives rows from a slice running on other segments. Until that
+// slice has started the Motion is only waiting, so time before the earliest
+// child started is not counted as time spent in the Motion.
+//
+// Percentages are relative to the top node's MsEnd which is the runtime of
+// the whole plan. Slices run in parallel so the percentages of all nodes
+// can add up to more than 100%.
+
+// Populate StartTime and EndTime on every node
+func (e *Explain) calculateTimeline() {
+	e.Walk(func(n *Node) error {
+		n.StartTime = OptionalDuration{}
+		n.EndTime = OptionalDuration{}
+
+		if n.MsEnd.Valid == false {
+			return nil
+		}
+
+		start := time.Duration(0)
+		if n.MsOffset.Valid {
+			start = n.MsOffset.Value
+		} else if n.Parent != nil && n.Parent.StartTime.Valid {
+			start = n.Parent.StartTime.Value
+		}
+
+		n.StartTime = validDuration(start)
+		n.EndTime = validDuration(start + n.MsEnd.Value)
+		return nil
+	}, nil)
+}
+
+// Calculate MsNode from the intervals of the node and its children.
+// Requires StartTime and EndTime to be populated.
+func (n *Node) calculateSelfTime() {
+	n.MsNode = OptionalDuration{}
+
+	if n.StartTime.Valid == false || n.EndTime.Valid == false {
+		return
+	}
+
+	start := n.StartTime.Value
+	end := n.EndTime.Value
+
+	// Child intervals clipped to this node, and the time of rescanned children
+	intervals := [][2]time.Duration{}
+	rescanned := time.Duration(0)
+	for _, c := range n.Children() {
+		if c.StartTime.Valid == false || c.EndTime.Valid == false {
+			continue
+		}
+		if c.Scans.Value > 1 {
+			rescanned += c.MsEnd.Value
+			continue
+		}
+		cStart := c.StartTime.Value
+		cEnd := c.EndTime.Value
+		if cStart < start {
+			cStart = start
+		}
+		if cEnd > end {
+			cEnd = end
+		}
+		if cEnd > cStart {
+			intervals = append(intervals, [2]time.Duration{cStart, cEnd})
+		}
+	}
+
+	// Motions are idle until the sending slice starts
+	if n.Category == CategoryMotion && len(intervals) > 0 {
+		first := end
+		for _, iv := range intervals {
+			if iv[0] < first {
+				first = iv[0]
+			}
+		}
+		start = first
+	}
+
+	self := (end - start) - mergedLength(intervals) - rescanned
+	if self < 0 {
+		self = 0
+	}
+
+	n.MsNode = validDuration(self)
+}
+
+// Total length covered by the intervals, counting overlaps once
+func mergedLength(intervals [][2]time.Duration) time.Duration {
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i][0] < intervals[j][0]
+	})
+
+	total := time.Duration(0)
+	var curStart, curEnd time.Duration
+	for i, iv := range intervals {
+		if i == 0 || iv[0] > curEnd {
+			total += curEnd - curStart
+			curStart = iv[0]
+			curEnd = iv[1]
+		} else if iv[1] > curEnd {
+			curEnd = iv[1]
+		}
+	}
+	total += curEnd - curStart
+
+	return total
+}
@@ -0,0 +1,98 @@
+package plan
+
+import (
+	"testing"
+	"time"
+)
+
+func ms(n float64) time.Duration {
+	return msToDuration(n)
+}
+
+func TestMergedLength(t *testing.T) {
+	tests := []struct {
+		intervals [][2]time.Duration
+		want      time.Duration
+	}{
+		{nil, 0},
+		{[][2]time.Duration{{ms(0), ms(10)}}, ms(10)},
+		{[][2]time.Duration{{ms(0), ms(10)}, {ms(20), ms(30)}}, ms(20)},
+		{[][2]time.Duration{{ms(0), ms(10)}, {ms(5), ms(15)}}, ms(15)},
+		{[][2]time.Duration{{ms(0), ms(30)}, {ms(5), ms(15)}}, ms(30)},
+		{[][2]time.Duration{{ms(20), ms(30)}, {ms(0), ms(10)}, {ms(8), ms(22)}}, ms(30)},
+		{[][2]time.Duration{{ms(0), ms(10)}, {ms(10), ms(20)}}, ms(20)},
+	}
+
+	for _, test := range tests {
+		if got := mergedLength(test.intervals); got != test.want {
+			t.Errorf("mergedLength(%v) = %s, want %s", test.intervals, got, test.want)
+		}
+	}
+}
+
+// Nodes start at their offset, or with their parent without one, and run
+// for their ms to end
+func TestTimelineTestdata(t *testing.T) {
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+		for _, n := range e.Nodes {
+			if n.MsEnd.Valid == false {
+				if n.StartTime.Valid || n.EndTime.Valid || n.MsNode.Valid {
+					t.Errorf("%s: node #%d without timing has a timeline", filename, n.Id)
+				}
+				continue
+			}
+
+			start := time.Duration(0)
+			if n.MsOffset.Valid {
+				start = n.MsOffset.Value
+			} else if n.Parent != nil && n.Parent.StartTime.Valid {
+				start = n.Parent.StartTime.Value
+			}
+			if n.StartTime != validDuration(start) || n.EndTime != validDuration(start+n.MsEnd.Value) {
+				t.Errorf("%s: node #%d runs from %s to %s, want %s to %s", filename, n.Id, n.StartTime, n.EndTime, FormatDuration(start), FormatDuration(start+n.MsEnd.Value))
+			}
+
+			// The time of a node lies within its own interval
+			if n.MsNode.Valid == false || n.MsNode.Value < 0 || n.MsNode.Value > n.MsEnd.Value {
+				t.Errorf("%s: node #%d has MsNode %s outside %s to %s", filename, n.Id, n.MsNode, n.StartTime, n.EndTime)
+			}
+		}
+	}
+}
+
+// explain05 runs the inner side of the Hash Join, below a Redistribute
+// Motion, from 42 ms while the outer side only starts at 6937 ms
+func TestSelfTimeExplain05(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+
+	tests := []struct {
+		id   int
+		want time.Duration
+		why  string
+	}{
+		{0, 0, "Gather Motion only waits until the Hash Join starts"},
+		{1, ms(7429 - (7042 - 149)), "Hash Join minus the Hash, the Sequence runs inside the Hash"},
+		{5, ms((7042 - 149) - (4593 - 149)), "Hash minus the Redistribute Motion from when the Hash started"},
+		{6, ms((4593 - 42) - (1474 - 42)), "Redistribute Motion minus the Sequence below it"},
+		{9, ms(700), "Dynamic Table Scan without children"},
+	}
+
+	for _, test := range tests {
+		n := e.Nodes[test.id]
+		if n.MsNode.Valid == false || n.MsNode.Value != test.want {
+			t.Errorf("node #%d %s: MsNode = %s, want %s: %s", n.Id, n.Operator, n.MsNode, FormatDuration(test.want), test.why)
+		}
+	}
+}
+
+// The Materialize below the Nested Loop of explain12 reports 106 ms to end of
+// 77284 scans. The scans are spread over the Nested Loop, so their time is
+// taken off as busy time rather than as an interval from its start offset.
+func TestSelfTimeRescanned(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain12.txt")
+	n := e.Nodes[7]
+	if n.MsNode.Valid == false || n.MsNode.Value != ms(60) {
+		t.Errorf("node #%d %s: MsNode = %s, want 60 ms", n.Id, n.Operator, n.MsNode)
+	}
+}
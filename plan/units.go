@@ -0,0 +1,232 @@
+package plan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of bytes, a plain number of bytes in JSON
+type ByteSize int64
+
+const (
+	Byte     ByteSize = 1
+	Kilobyte          = 1024 * Byte
+	Megabyte          = 1024 * Kilobyte
+	Gigabyte          = 1024 * Megabyte
+	Terabyte          = 1024 * Gigabyte
+)
+
+// Values are absent when Valid is false, replacing the -1 sentinel.
+// When converted to JSON absent values become null, bytes are a number of
+// bytes and durations a number of milliseconds, fractional below 1 ms, to
+// match the Ms* field names and the "ms" of the plan text.
+
+// Optional duration, used for EXPLAIN ANALYZE timings
+type OptionalDuration struct {
+	Value time.Duration
+	Valid bool
+}
+
+// Optional size in bytes, used for memory and workfile sizes
+type OptionalBytes struct {
+	Value ByteSize
+	Valid bool
+}
+
+// Optional row count. Can be fractional when it is an average
+type OptionalCount struct {
+	Value float64
+	Valid bool
+}
+
+// Optional integer such as number of workers or partitions
+type OptionalInt struct {
+	Value int64
+	Valid bool
+}
+
+var byteSizePattern = regexp.MustCompile(`(?i)^\s*([0-9.]+)\s*([KMGT]?)B?(\s*bytes)?\s*$`)
+
+// Format as human readable units, e.g. "1.2 GB"
+func (b ByteSize) String() string {
+	switch {
+	case b >= Terabyte || b <= -Terabyte:
+		return fmt.Sprintf("%.1f TB", float64(b)/float64(Terabyte))
+	case b >= Gigabyte || b <= -Gigabyte:
+		return fmt.Sprintf("%.1f GB", float64(b)/float64(Gigabyte))
+	case b >= Megabyte || b <= -Megabyte:
+		return fmt.Sprintf("%.1f MB", float64(b)/float64(Megabyte))
+	case b >= Kilobyte || b <= -Kilobyte:
+		return fmt.Sprintf("%.1f KB", float64(b)/float64(Kilobyte))
+	}
+	return fmt.Sprintf("%d B", int64(b))
+}
+
+// Parse a size with an optional K/M/G/T suffix as printed in the plan:
+//
+//	128000K
+//	128000K bytes
+//	1.5 GB
+func ParseByteSize(s string) (ByteSize, error) {
+	m := byteSizePattern.FindStringSubmatch(s)
+	if len(m) == 0 {
+		return 0, errors.New(fmt.Sprintf("Unable to parse size \"%s\"", s))
+	}
+
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	unit := Byte
+	switch strings.ToUpper(m[2]) {
+	case "K":
+		unit = Kilobyte
+	case "M":
+		unit = Megabyte
+	case "G":
+		unit = Gigabyte
+	case "T":
+		unit = Terabyte
+	}
+
+	return ByteSize(value * float64(unit)), nil
+}
+
+// Parse a number of milliseconds as printed by EXPLAIN ANALYZE
+func parseMs(s string) (time.Duration, error) {
+	ms, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return msToDuration(ms), nil
+}
+
+func msToDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// Format a duration as human readable units, e.g. "7.4 s"
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%.1f h", d.Hours())
+	case d >= time.Minute:
+		return fmt.Sprintf("%.1f min", d.Minutes())
+	case d >= time.Second:
+		return fmt.Sprintf("%.1f s", d.Seconds())
+	}
+	return fmt.Sprintf("%.0f ms", durationToMs(d))
+}
+
+func durationToMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func validDuration(d time.Duration) OptionalDuration {
+	return OptionalDuration{d, true}
+}
+
+// Milliseconds, or 0 if absent
+func (d OptionalDuration) Ms() float64 {
+	return durationToMs(d.Value)
+}
+
+func (d OptionalDuration) String() string {
+	if d.Valid == false {
+		return "-"
+	}
+	return FormatDuration(d.Value)
+}
+
+// Milliseconds in JSON, e.g. 7429.4
+func (d OptionalDuration) MarshalJSON() ([]byte, error) {
+	if d.Valid == false {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.Ms())
+}
+
+func validBytes(b ByteSize) OptionalBytes {
+	return OptionalBytes{b, true}
+}
+
+func (b OptionalBytes) String() string {
+	if b.Valid == false {
+		return "-"
+	}
+	return b.Value.String()
+}
+
+// Bytes in JSON
+func (b OptionalBytes) MarshalJSON() ([]byte, error) {
+	if b.Valid == false {
+		return []byte("null"), nil
+	}
+	return json.Marshal(int64(b.Value))
+}
+
+func validCount(c float64) OptionalCount {
+	return OptionalCount{c, true}
+}
+
+func (c OptionalCount) String() string {
+	if c.Valid == false {
+		return "-"
+	}
+	return strconv.FormatFloat(c.Value, 'f', 0, 64)
+}
+
+func (c OptionalCount) MarshalJSON() ([]byte, error) {
+	if c.Valid == false {
+		return []byte("null"), nil
+	}
+	return json.Marshal(c.Value)
+}
+
+func validInt(i int64) OptionalInt {
+	return OptionalInt{i, true}
+}
+
+func (i OptionalInt) String() string {
+	if i.Valid == false {
+		return "-"
+	}
+	return strconv.FormatInt(i.Value, 10)
+}
+
+func (i OptionalInt) MarshalJSON() ([]byte, error) {
+	if i.Valid == false {
+		return []byte("null"), nil
+	}
+	return json.Marshal(i.Value)
+}
+
+// Optional floating point value such as a ratio
+type OptionalFloat struct {
+	Value float64
+	Valid bool
+}
+
+func validFloat(f float64) OptionalFloat {
+	return OptionalFloat{f, true}
+}
+
+func (f OptionalFloat) String() string {
+	if f.Valid == false {
+		return "-"
+	}
+	return strconv.FormatFloat(f.Value, 'f', 1, 64)
+}
+
+func (f OptionalFloat) MarshalJSON() ([]byte, error) {
+	if f.Valid == false {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.Value)
+}
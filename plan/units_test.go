@@ -0,0 +1,221 @@
+package plan
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		s    string
+		want ByteSize
+	}{
+		{"512", 512 * Byte},
+		{"0", 0},
+		{"128000K", 128000 * Kilobyte},
+		{"128000K bytes", 128000 * Kilobyte},
+		{"12kB", 12 * Kilobyte},
+		{"64MB", 64 * Megabyte},
+		{"1.5 GB", Gigabyte + 512*Megabyte},
+		{"2T", 2 * Terabyte},
+		{" 100 bytes ", 100 * Byte},
+	}
+
+	for _, test := range tests {
+		got, err := ParseByteSize(test.s)
+		if err != nil {
+			t.Errorf("ParseByteSize(%q) returned error: %s", test.s, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseByteSize(%q) = %d, want %d", test.s, got, test.want)
+		}
+	}
+}
+
+func TestParseByteSizeErrors(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"", "Unable to parse size \"\""},
+		{"many", "Unable to parse size \"many\""},
+		{"12P", "Unable to parse size \"12P\""},
+		{"-1K", "Unable to parse size \"-1K\""},
+		{"1.2.3K", "strconv.ParseFloat: parsing \"1.2.3\": invalid syntax"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseByteSize(test.s)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("ParseByteSize(%q) error = %v, want %q", test.s, err, test.want)
+		}
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	tests := []struct {
+		b    ByteSize
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{Kilobyte, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{5 * Megabyte, "5.0 MB"},
+		{-2 * Gigabyte, "-2.0 GB"},
+		{Terabyte, "1.0 TB"},
+	}
+
+	for _, test := range tests {
+		if got := test.b.String(); got != test.want {
+			t.Errorf("ByteSize(%d).String() = %q, want %q", int64(test.b), got, test.want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0 ms"},
+		{500 * time.Millisecond, "500 ms"},
+		{1500 * time.Millisecond, "1.5 s"},
+		{90 * time.Second, "1.5 min"},
+		{2 * time.Hour, "2.0 h"},
+	}
+
+	for _, test := range tests {
+		if got := FormatDuration(test.d); got != test.want {
+			t.Errorf("FormatDuration(%s) = %q, want %q", test.d, got, test.want)
+		}
+	}
+}
+
+func TestParseMs(t *testing.T) {
+	d, err := parseMs("12.5")
+	if err != nil || d != 12500*time.Microsecond {
+		t.Errorf("parseMs(\"12.5\") = %s, %v", d, err)
+	}
+	if _, err := parseMs("12.5ms"); err == nil {
+		t.Errorf("parseMs(\"12.5ms\") did not return an error")
+	}
+}
+
+func TestOptionalString(t *testing.T) {
+	tests := []struct {
+		value interface{ String() string }
+		want  string
+	}{
+		{OptionalDuration{}, "-"},
+		{validDuration(1500 * time.Millisecond), "1.5 s"},
+		{OptionalBytes{}, "-"},
+		{validBytes(2 * Megabyte), "2.0 MB"},
+		{OptionalCount{}, "-"},
+		{validCount(1234.6), "1235"},
+		{OptionalInt{}, "-"},
+		{validInt(-3), "-3"},
+		{OptionalFloat{}, "-"},
+		{validFloat(13.75), "13.8"},
+	}
+
+	for _, test := range tests {
+		if got := test.value.String(); got != test.want {
+			t.Errorf("%#v.String() = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+// Absent values are null in JSON, present values plain numbers with
+// durations in milliseconds and sizes in bytes
+func TestOptionalJSON(t *testing.T) {
+	type values struct {
+		Duration OptionalDuration
+		Bytes    OptionalBytes
+		Count    OptionalCount
+		Int      OptionalInt
+		Float    OptionalFloat
+	}
+
+	tests := []struct {
+		v    values
+		want string
+	}{
+		{values{}, `{"Duration":null,"Bytes":null,"Count":null,"Int":null,"Float":null}`},
+		{values{
+			Duration: validDuration(2 * time.Millisecond),
+			Bytes:    validBytes(Kilobyte),
+			Count:    validCount(0.5),
+			Int:      validInt(0),
+			Float:    validFloat(1.5),
+		}, `{"Duration":2,"Bytes":1024,"Count":0.5,"Int":0,"Float":1.5}`},
+		{values{Duration: validDuration(7429*time.Millisecond + 441*time.Microsecond), Bytes: validBytes(54048 * Kilobyte)},
+			`{"Duration":7429.441,"Bytes":55345152,"Count":null,"Int":null,"Float":null}`},
+		// A present zero value is not null
+		{values{Duration: validDuration(0), Bytes: validBytes(0)}, `{"Duration":0,"Bytes":0,"Count":null,"Int":null,"Float":null}`},
+	}
+
+	for _, test := range tests {
+		data, err := json.Marshal(test.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.want {
+			t.Errorf("json.Marshal(%+v) = %s, want %s", test.v, data, test.want)
+		}
+	}
+}
+
+// Metrics parsed from explain05 keep their units
+func TestParsedMetrics(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+	gather, join, scan := e.Nodes[0], e.Nodes[1], e.Nodes[4]
+
+	durations := []struct {
+		name string
+		got  OptionalDuration
+		want time.Duration
+	}{
+		{"gather ms to end", gather.MsEnd, 7441 * time.Millisecond},
+		{"gather offset", gather.MsOffset, 365 * time.Microsecond},
+		{"join ms to first row", join.MsFirst, 6897 * time.Millisecond},
+		{"join ms to end", join.MsEnd, 7429 * time.Millisecond},
+		{"scan ms to first row", scan.MsFirst, 34 * time.Microsecond},
+		{"scan ms to end", scan.MsEnd, 394 * time.Microsecond},
+		{"scan offset", scan.MsOffset, 6937 * time.Millisecond},
+	}
+	for _, d := range durations {
+		if d.got.Valid == false || d.got.Value != d.want {
+			t.Errorf("%s = %s (%d ns), want %s", d.name, d.got, int64(d.got.Value), d.want)
+		}
+	}
+
+	if join.MaxMem != validBytes(127501*Kilobyte) || join.SpillFile != validInt(2) || join.SpillReuse != validInt(0) {
+		t.Errorf("join memory = %s, spilling %s, reused %s", join.MaxMem, join.SpillFile, join.SpillReuse)
+	}
+	if join.ActualRows != validCount(11000) || join.AvgRows.Valid {
+		t.Errorf("join rows = %s, avg %s", join.ActualRows, join.AvgRows)
+	}
+	if scan.AvgRows != validCount(2750) || scan.Workers != validInt(2) || scan.MaxRows != validCount(2752) || scan.ActualRows.Valid {
+		t.Errorf("scan rows = avg %s x %s workers, max %s, actual %s", scan.AvgRows, scan.Workers, scan.MaxRows, scan.ActualRows)
+	}
+	if scan.PartScanned != validInt(1) || scan.PartScannedTotal != validInt(100) {
+		t.Errorf("scan partitions = %s out of %s", scan.PartScanned, scan.PartScannedTotal)
+	}
+	if e.MemoryUsed != validBytes(128000*Kilobyte) || e.Runtime != validDuration(7442441*time.Microsecond) {
+		t.Errorf("statement memory %s, runtime %s (%d ns)", e.MemoryUsed, e.Runtime, int64(e.Runtime.Value))
+	}
+
+	// Without EXPLAIN ANALYZE none of the values are present
+	e = loadTestExplain(t, "../testdata/explain01.txt")
+	for _, n := range e.Nodes {
+		if n.MsEnd.Valid || n.MsOffset.Valid || n.ActualRows.Valid || n.MaxMem.Valid || n.SpillFile.Valid {
+			t.Errorf("explain01 node #%d has EXPLAIN ANALYZE values", n.Id)
+		}
+	}
+	if e.Runtime.Valid || e.MemoryUsed.Valid {
+		t.Errorf("explain01 has runtime %s and memory %s", e.Runtime, e.MemoryUsed)
+	}
+}
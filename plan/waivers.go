@@ -0,0 +1,180 @@
+package plan
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Waivers
+//
+// A waiver suppresses warnings that are expected. Suppressed warnings are
+// not removed: they are kept in Findings.Suppressed (Explain.Suppressed
+// after ApplyFindings) with the waiver that matched, so reviewers can see
+// them.
+//
+// Waivers are read from a file and match a check Id plus, optionally, the
+// object and operator of the node and the fingerprint of the query. Every
+// field that is set must match:
+//
+//	- check: nested-loop-*    # check Id, can contain * wildcards
+//	  object: lookup_*        # node object, can contain * wildcards
+//	  operator: Nested Loop   # text contained in the node operator
+//	  query: 3f2a9c01         # query fingerprint, or its first characters
+//	  reason: Small lookup tables, expected
+//
+// Waivers can also be written as a comment in the query captured from the
+// psql prompt lines, and apply to that query only:
+//
+//	-- planchecker:ignore nested-loop-rescan,data-skew Small lookup tables
+//	/* planchecker:ignore spill-files */
+//
+// The query fingerprint is a hash of the query with comments, literals and
+// the EXPLAIN keywords removed, so it does not change with the parameters.
+
+// Suppresses matching warnings
+type Waiver struct {
+	Check    string `json:"check" yaml:"check"`
+	Object   string `json:"object" yaml:"object"`
+	Operator string `json:"operator" yaml:"operator"`
+	Query    string `json:"query" yaml:"query"`
+	Reason   string `json:"reason" yaml:"reason"`
+	Source   string `json:"-" yaml:"-"` // File the waiver was read from or "inline"
+}
+
+var (
+	inlineWaiverPattern = regexp.MustCompile(`planchecker:ignore\s+([A-Za-z0-9_*,.-]+)[ \t]*([^\n]*?)\s*(\*/|\n|$)`)
+	sqlCommentPattern   = regexp.MustCompile(`(?s)--[^\n]*|/\*.*?\*/`)
+	sqlStringPattern    = regexp.MustCompile(`'(''|[^'])*'`)
+	sqlNumberPattern    = regexp.MustCompile(`\b[0-9]+(\.[0-9]+)?\b`)
+	sqlExplainPattern   = regexp.MustCompile(`(?i)^\s*explain\s+((analyze|verbose)\s+)*`)
+	whitespacePattern   = regexp.MustCompile(`\s+`)
+)
+
+// Read waivers from a file.
+// Files ending in .json are read as JSON, anything else as YAML.
+func LoadWaiverFile(filename string) ([]Waiver, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	waivers := []Waiver{}
+	if err := unmarshalFile(filename, data, &waivers); err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to read waivers %s: %s", filename, err))
+	}
+
+	for i := range waivers {
+		if waivers[i].Check == "" || waivers[i].Reason == "" {
+			return nil, errors.New(fmt.Sprintf("Unable to read waivers %s: waiver %d needs a check and a reason", filename, i+1))
+		}
+		waivers[i].Source = filename
+	}
+	return waivers, nil
+}
+
+// Return the waivers written as planchecker:ignore comments in the query
+func (e *Explain) InlineWaivers() []Waiver {
+	waivers := []Waiver{}
+	for _, m := range inlineWaiverPattern.FindAllStringSubmatch(e.Query, -1) {
+		reason := strings.TrimSpace(m[2])
+		if reason == "" {
+			reason = "Suppressed in query"
+		}
+		for _, id := range ParseCheckIds(m[1]) {
+			waivers = append(waivers, Waiver{
+				Check:  id,
+				Reason: reason,
+				Source: "inline",
+			})
+		}
+	}
+	return waivers
+}
+
+// Fingerprint of the query, empty if no query was captured
+func (e *Explain) QueryFingerprint() string {
+	if strings.TrimSpace(e.Query) == "" {
+		return ""
+	}
+	return QueryFingerprint(e.Query)
+}
+
+// Fingerprint of a query, the same for queries differing only in literals,
+// comments, whitespace, case or EXPLAIN options
+func QueryFingerprint(query string) string {
+	q := sqlCommentPattern.ReplaceAllString(query, " ")
+	q = sqlExplainPattern.ReplaceAllString(q, "")
+	q = sqlStringPattern.ReplaceAllString(q, "?")
+	q = sqlNumberPattern.ReplaceAllString(q, "?")
+	q = strings.ToLower(whitespacePattern.ReplaceAllString(q, " "))
+	q = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(q), ";"))
+
+	sum := sha1.Sum([]byte(q))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// True if the waiver applies to the warning
+func (w Waiver) Matches(warning Warning, fingerprint string) bool {
+	if matched, err := path.Match(w.Check, warning.CheckId); err != nil || matched == false {
+		return false
+	}
+	if w.Object != "" {
+		if warning.Node == nil || warning.Node.Object == "" {
+			return false
+		}
+		if matched, err := path.Match(w.Object, warning.Node.Object); err != nil || matched == false {
+			return false
+		}
+	}
+	if w.Operator != "" {
+		if warning.Node == nil || strings.Contains(strings.ToLower(warning.Node.Operator), strings.ToLower(w.Operator)) == false {
+			return false
+		}
+	}
+	if w.Query != "" {
+		if fingerprint == "" || strings.HasPrefix(fingerprint, w.Query) == false {
+			return false
+		}
+	}
+	return true
+}
+
+// Move warnings matching a waiver to Suppressed
+func (f *Findings) suppress(waivers []Waiver, fingerprint string) {
+	if len(waivers) == 0 {
+		return
+	}
+
+	f.Warnings = f.suppressWarnings(f.Warnings, waivers, fingerprint)
+	for _, n := range f.Explain.Nodes {
+		if warnings, ok := f.NodeWarnings[n]; ok {
+			f.NodeWarnings[n] = f.suppressWarnings(warnings, waivers, fingerprint)
+		}
+	}
+}
+
+// Return the warnings not matching a waiver
+func (f *Findings) suppressWarnings(warnings []Warning, waivers []Waiver, fingerprint string) []Warning {
+	kept := []Warning{}
+	for _, warning := range warnings {
+		waived := false
+		for i := range waivers {
+			if waivers[i].Matches(warning, fingerprint) {
+				warning.Waiver = &waivers[i]
+				f.Suppressed = append(f.Suppressed, warning)
+				waived = true
+				break
+			}
+		}
+		if waived == false {
+			kept = append(kept, warning)
+		}
+	}
+	return kept
+}
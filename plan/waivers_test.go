@@ -0,0 +1,246 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWaiverMatches(t *testing.T) {
+	loop := &Node{Operator: "Nested Loop", Object: "lookup_country"}
+	scan := &Node{Operator: "Seq Scan", Object: "sales"}
+	fingerprint := "3f2a9c01d4e5b6a7"
+
+	tests := []struct {
+		waiver  Waiver
+		warning Warning
+		want    bool
+	}{
+		// Check Id
+		{Waiver{Check: "nested-loop-rescan"}, Warning{CheckId: "nested-loop-rescan", Node: loop}, true},
+		{Waiver{Check: "nested-loop-*"}, Warning{CheckId: "nested-loop-rescan", Node: loop}, true},
+		{Waiver{Check: "*"}, Warning{CheckId: "slice-count"}, true},
+		{Waiver{Check: "nested-loop"}, Warning{CheckId: "nested-loop-rescan", Node: loop}, false},
+		{Waiver{Check: "[invalid"}, Warning{CheckId: "[invalid"}, false},
+
+		// Object
+		{Waiver{Check: "*", Object: "lookup_*"}, Warning{CheckId: "a", Node: loop}, true},
+		{Waiver{Check: "*", Object: "lookup_*"}, Warning{CheckId: "a", Node: scan}, false},
+		{Waiver{Check: "*", Object: "lookup_*"}, Warning{CheckId: "a"}, false},
+		{Waiver{Check: "*", Object: "sales"}, Warning{CheckId: "a", Node: scan}, true},
+
+		// Operator, contained and ignoring case
+		{Waiver{Check: "*", Operator: "nested loop"}, Warning{CheckId: "a", Node: loop}, true},
+		{Waiver{Check: "*", Operator: "Loop"}, Warning{CheckId: "a", Node: loop}, true},
+		{Waiver{Check: "*", Operator: "Hash Join"}, Warning{CheckId: "a", Node: loop}, false},
+		{Waiver{Check: "*", Operator: "Seq Scan"}, Warning{CheckId: "a"}, false},
+
+		// Query fingerprint or its first characters
+		{Waiver{Check: "*", Query: fingerprint}, Warning{CheckId: "a"}, true},
+		{Waiver{Check: "*", Query: "3f2a9c01"}, Warning{CheckId: "a"}, true},
+		{Waiver{Check: "*", Query: "9c01"}, Warning{CheckId: "a"}, false},
+
+		// Every field that is set must match
+		{Waiver{Check: "a", Object: "lookup_*", Operator: "loop", Query: "3f2a"}, Warning{CheckId: "a", Node: loop}, true},
+		{Waiver{Check: "a", Object: "lookup_*", Operator: "scan", Query: "3f2a"}, Warning{CheckId: "a", Node: loop}, false},
+		{Waiver{Check: "b", Object: "lookup_*", Operator: "loop", Query: "3f2a"}, Warning{CheckId: "a", Node: loop}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.waiver.Matches(test.warning, fingerprint); got != test.want {
+			t.Errorf("%+v matches %s on %+v = %t, want %t", test.waiver, test.warning.CheckId, test.warning.Node, got, test.want)
+		}
+	}
+
+	// A waiver for a query never matches when no query was captured
+	if (Waiver{Check: "*", Query: "3f2a"}).Matches(Warning{CheckId: "a"}, "") {
+		t.Errorf("waiver with a query matched without a fingerprint")
+	}
+}
+
+func TestQueryFingerprint(t *testing.T) {
+	query := "SELECT * FROM sales WHERE id = 1 AND region = 'EMEA';"
+	same := []string{
+		"select *\n  from sales\n where id = 42 and region = 'APAC'",
+		"EXPLAIN ANALYZE SELECT * FROM sales WHERE id = 1 AND region = 'it''s';",
+		"explain verbose select * from sales -- comment\nwhere id = 1.5 /* block */ and region = ''",
+	}
+	different := []string{
+		"SELECT * FROM sales WHERE id = 1",
+		"SELECT * FROM sales2 WHERE id = 1 AND region = 'EMEA'",
+		"SELECT * FROM sales WHERE id = 1 OR region = 'EMEA'",
+	}
+
+	want := QueryFingerprint(query)
+	if len(want) != 16 {
+		t.Errorf("QueryFingerprint(%q) = %q, want 16 characters", query, want)
+	}
+	for _, q := range same {
+		if got := QueryFingerprint(q); got != want {
+			t.Errorf("QueryFingerprint(%q) = %s, want %s", q, got, want)
+		}
+	}
+	for _, q := range different {
+		if got := QueryFingerprint(q); got == want {
+			t.Errorf("QueryFingerprint(%q) = %s, same as %q", q, got, query)
+		}
+	}
+
+	if got := (&Explain{Query: " \n"}).QueryFingerprint(); got != "" {
+		t.Errorf("fingerprint without a query = %q, want empty", got)
+	}
+}
+
+func TestInlineWaivers(t *testing.T) {
+	e := &Explain{Query: `-- planchecker:ignore nested-loop-rescan,data-skew Small lookup tables
+/* planchecker:ignore spill-files */
+SELECT * FROM sales; -- planchecker:ignore slice-*`}
+
+	want := []Waiver{
+		{Check: "nested-loop-rescan", Reason: "Small lookup tables", Source: "inline"},
+		{Check: "data-skew", Reason: "Small lookup tables", Source: "inline"},
+		{Check: "spill-files", Reason: "Suppressed in query", Source: "inline"},
+		{Check: "slice-*", Reason: "Suppressed in query", Source: "inline"},
+	}
+	got := e.InlineWaivers()
+	if len(got) != len(want) {
+		t.Fatalf("InlineWaivers() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("inline waiver %d = %+v, want %+v", i+1, got[i], want[i])
+		}
+	}
+
+	if got := (&Explain{Query: "SELECT 1"}).InlineWaivers(); len(got) != 0 {
+		t.Errorf("InlineWaivers() without comments = %+v", got)
+	}
+}
+
+func TestLoadWaiverFile(t *testing.T) {
+	filename := writeTestFile(t, "waivers.yml", `# Expected warnings
+- check: nested-loop-*   # wildcard
+  object: lookup_*
+  operator: Nested Loop
+  reason: "Small lookup tables # expected"
+
+- check: spill-files
+  query: 3f2a9c01
+  reason: Nightly batch
+`)
+	waivers, err := LoadWaiverFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Waiver{
+		{Check: "nested-loop-*", Object: "lookup_*", Operator: "Nested Loop", Reason: "Small lookup tables # expected", Source: filename},
+		{Check: "spill-files", Query: "3f2a9c01", Reason: "Nightly batch", Source: filename},
+	}
+	if len(waivers) != len(want) {
+		t.Fatalf("LoadWaiverFile() = %+v, want %+v", waivers, want)
+	}
+	for i := range want {
+		if waivers[i] != want[i] {
+			t.Errorf("waiver %d = %+v, want %+v", i+1, waivers[i], want[i])
+		}
+	}
+
+	// JSON is read in to the same waivers
+	filename = writeTestFile(t, "waivers.json", `[{"check": "data-skew", "object": "sales", "reason": "Known skew"}]`)
+	waivers, err = LoadWaiverFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(waivers) != 1 || waivers[0] != (Waiver{Check: "data-skew", Object: "sales", Reason: "Known skew", Source: filename}) {
+		t.Errorf("JSON waivers = %+v", waivers)
+	}
+}
+
+func TestLoadWaiverFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"waivers.yml", "- check: a\n", "waiver 1 needs a check and a reason"},
+		{"waivers.yml", "- check: a\n  reason: b\n- reason: c\n", "waiver 2 needs a check and a reason"},
+		{"waivers.yml", "- check: a\n  table: b\n", "line 2: field table not found in type plan.Waiver"},
+		{"waivers.json", "{\"check\": \"a\"}", "cannot unmarshal object"},
+		{"waivers.json", "[{\"check\": \"a\", \"table\": \"b\"}]", "unknown field \"table\""},
+	}
+
+	for _, test := range tests {
+		filename := writeTestFile(t, test.name, test.content)
+		_, err := LoadWaiverFile(filename)
+		if err == nil || strings.HasPrefix(err.Error(), "Unable to read waivers "+filename+": ") == false || strings.Contains(err.Error(), test.want) == false {
+			t.Errorf("LoadWaiverFile(%q) error = %v, want %q", test.content, err, test.want)
+		}
+	}
+}
+
+// Matching warnings move to Suppressed with the waiver that matched
+func TestSuppress(t *testing.T) {
+	loop := &Node{Operator: "Nested Loop", Object: "lookup_country"}
+	scan := &Node{Operator: "Seq Scan", Object: "sales"}
+
+	f := NewFindings()
+	f.Explain = &Explain{Nodes: []*Node{loop, scan}}
+	f.AddWarning(Warning{CheckId: "slice-count"})
+	f.AddWarning(Warning{CheckId: "spill-files"})
+	f.AddNodeWarning(loop, Warning{CheckId: "nested-loop-rescan"})
+	f.AddNodeWarning(scan, Warning{CheckId: "nested-loop-rescan"})
+
+	waivers := []Waiver{
+		{Check: "spill-*", Reason: "Expected"},
+		{Check: "nested-loop-rescan", Object: "lookup_*", Reason: "Small lookup tables"},
+	}
+	f.suppress(waivers, "")
+
+	if len(f.Warnings) != 1 || f.Warnings[0].CheckId != "slice-count" {
+		t.Errorf("warnings = %+v", f.Warnings)
+	}
+	if len(f.NodeWarnings[loop]) != 0 || len(f.NodeWarnings[scan]) != 1 {
+		t.Errorf("node warnings = %+v", f.NodeWarnings)
+	}
+	if len(f.Suppressed) != 2 {
+		t.Fatalf("suppressed = %+v", f.Suppressed)
+	}
+	if f.Suppressed[0].Waiver != &waivers[0] || f.Suppressed[1].Waiver != &waivers[1] || f.Suppressed[1].Node != loop {
+		t.Errorf("suppressed = %+v", f.Suppressed)
+	}
+}
+
+// explain05 captures the query from the psql prompt so waivers can be
+// limited to it
+func TestWaiversExplain05(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+	want := "explain analyze select * from sales s1, sales s2 where s1.year = 2015 and s1.id = s2.year;"
+	if e.Query != want || e.QueryFingerprint() != QueryFingerprint(want) {
+		t.Fatalf("query = %q", e.Query)
+	}
+
+	all := e.CheckWithConfig(CheckConfig{})
+	if len(all.Suppressed) != 0 {
+		t.Errorf("%d warnings suppressed without waivers", len(all.Suppressed))
+	}
+
+	waivers := []Waiver{{Check: "row-misestimate", Object: "sales", Query: e.QueryFingerprint()[:8], Reason: "Known"}}
+	f := e.CheckWithConfig(CheckConfig{Waivers: waivers})
+	for _, w := range f.Suppressed {
+		if w.CheckId != "row-misestimate" || w.Node.Object != "sales" || w.Waiver == nil || w.Waiver.Reason != "Known" {
+			t.Errorf("suppressed %q on node #%d", w.CheckId, w.Node.Id)
+		}
+	}
+	if len(f.Suppressed) != 2 || countWarnings(f) != countWarnings(all)-2 {
+		t.Errorf("%d warnings suppressed, %d of %d left", len(f.Suppressed), countWarnings(f), countWarnings(all))
+	}
+	e.ApplyFindings(f)
+	if len(e.Suppressed) != 2 || e.Summary.Suppressed != 2 || e.Summary.Warnings != countWarnings(f) {
+		t.Errorf("summary has %d warnings and %d suppressed", e.Summary.Warnings, e.Summary.Suppressed)
+	}
+
+	// A waiver for another query suppresses nothing
+	waivers[0].Query = "0000"
+	if f := e.CheckWithConfig(CheckConfig{Waivers: waivers}); len(f.Suppressed) != 0 {
+		t.Errorf("waiver for another query suppressed %d warnings", len(f.Suppressed))
+	}
+}
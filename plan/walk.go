@@ -0,0 +1,117 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Returned by a pre-order WalkFunc to skip the children of the current node.
+// Any other non-nil error stops the walk and is returned by Walk().
+var SkipChildren = errors.New("skip children")
+
+// Called for each node visited by Walk()
+type WalkFunc func(n *Node) error
+
+// Return the child nodes in the order they appear in the plan:
+// SubNodes first followed by the TopNode of each SubPlan
+func (n *Node) Children() []*Node {
+	children := make([]*Node, 0, len(n.SubNodes)+len(n.SubPlans))
+	children = append(children, n.SubNodes...)
+	for _, p := range n.SubPlans {
+		if p.TopNode != nil {
+			children = append(children, p.TopNode)
+		}
+	}
+	return children
+}
+
+// Walk the node and all nodes below it, including SubPlans.
+// pre is called before the children are visited and post after,
+// either can be nil.
+func (n *Node) Walk(pre WalkFunc, post WalkFunc) error {
+	if pre != nil {
+		err := pre(n)
+		if err == SkipChildren {
+			if post != nil {
+				return post(n)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, c := range n.Children() {
+		err := c.Walk(pre, post)
+		if err != nil {
+			return err
+		}
+	}
+
+	if post != nil {
+		return post(n)
+	}
+
+	return nil
+}
+
+// Walk every node in the plan starting at the top node
+func (e *Explain) Walk(pre WalkFunc, post WalkFunc) error {
+	if len(e.Plans) == 0 || e.Plans[0].TopNode == nil {
+		return nil
+	}
+	return e.Plans[0].TopNode.Walk(pre, post)
+}
+
+// Return the node with the given Id
+func (e *Explain) NodeById(id int) *Node {
+	if id < 0 || id >= len(e.Nodes) {
+		return nil
+	}
+	return e.Nodes[id]
+}
+
+// Return the node with the given Path, for example "0.1.2"
+func (e *Explain) NodeByPath(path string) *Node {
+	for _, n := range e.Nodes {
+		if n.Path == path {
+			return n
+		}
+	}
+	return nil
+}
+
+// Populate Id, Path, Depth, Parent and Plan on every node.
+// Called at the end of BuildTree() once SubNodes/SubPlans are linked.
+func (e *Explain) linkTree() {
+	// Id is the position in the plan text so it is stable across runs
+	for i, n := range e.Nodes {
+		n.Id = i
+	}
+
+	// Mark the top node of each plan
+	for _, p := range e.Plans {
+		if p.TopNode != nil {
+			p.TopNode.Plan = p
+		}
+	}
+
+	if len(e.Plans) == 0 || e.Plans[0].TopNode == nil {
+		return
+	}
+
+	top := e.Plans[0].TopNode
+	top.Parent = nil
+	top.Depth = 0
+	top.Path = "0"
+
+	top.Walk(func(n *Node) error {
+		for i, c := range n.Children() {
+			c.Parent = n
+			c.Depth = n.Depth + 1
+			c.Path = fmt.Sprintf("%s.%d", n.Path, i)
+		}
+		return nil
+	}, nil)
+}
@@ -0,0 +1,131 @@
+package plan
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Every node is visited once, parents before their children in pre-order
+// and after them in post-order
+func TestWalkTestdata(t *testing.T) {
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+
+		pre := map[*Node]int{}
+		post := map[*Node]int{}
+		e.Walk(func(n *Node) error {
+			pre[n] = len(pre)
+			return nil
+		}, func(n *Node) error {
+			post[n] = len(post)
+			return nil
+		})
+
+		if len(pre) != len(e.Nodes) || len(post) != len(e.Nodes) {
+			t.Errorf("%s: walk visited %d nodes pre-order and %d post-order, want %d", filename, len(pre), len(post), len(e.Nodes))
+		}
+		for n := range pre {
+			if n.Parent == nil {
+				continue
+			}
+			if pre[n.Parent] > pre[n] || post[n.Parent] < post[n] {
+				t.Errorf("%s: node #%d visited before its parent #%d", filename, n.Id, n.Parent.Id)
+			}
+		}
+	}
+}
+
+// Id, Path, Depth and Parent locate each node in the tree
+func TestLinkTreeTestdata(t *testing.T) {
+	for _, filename := range testExplainFiles(t) {
+		e := loadTestExplain(t, filename)
+
+		if e.Nodes[0].Parent != nil || e.Nodes[0].Path != "0" || e.Nodes[0].Depth != 0 {
+			t.Errorf("%s: top node has Parent %v, Path %q and Depth %d", filename, e.Nodes[0].Parent, e.Nodes[0].Path, e.Nodes[0].Depth)
+		}
+		for i, n := range e.Nodes {
+			if n.Id != i || e.NodeById(n.Id) != n {
+				t.Errorf("%s: node %d has Id %d", filename, i, n.Id)
+			}
+			if e.NodeByPath(n.Path) != n {
+				t.Errorf("%s: NodeByPath(%q) is not node #%d", filename, n.Path, n.Id)
+			}
+			if n.Parent == nil {
+				continue
+			}
+			if n.Depth != n.Parent.Depth+1 || strings.HasPrefix(n.Path, n.Parent.Path+".") == false {
+				t.Errorf("%s: node #%d at %q depth %d below #%d at %q depth %d", filename, n.Id, n.Path, n.Depth, n.Parent.Id, n.Parent.Path, n.Parent.Depth)
+			}
+		}
+	}
+
+	e := loadTestExplain(t, "../testdata/explain01.txt")
+	if e.NodeById(-1) != nil || e.NodeById(len(e.Nodes)) != nil || e.NodeByPath("0.9") != nil {
+		t.Errorf("nodes found outside the plan")
+	}
+}
+
+// SubPlans are walked below the node they belong to
+func TestWalkSubPlans(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain06.txt")
+	found := false
+	for _, n := range e.Nodes {
+		if n.Plan == nil || n == e.Nodes[0] {
+			continue
+		}
+		found = true
+		if n.Parent == nil {
+			t.Errorf("SubPlan top node #%d has no parent", n.Id)
+			continue
+		}
+		children := n.Parent.Children()
+		if children[len(n.Parent.SubNodes)+indexOfPlan(n.Parent.SubPlans, n.Plan)] != n {
+			t.Errorf("SubPlan top node #%d is not a child of #%d after its SubNodes", n.Id, n.Parent.Id)
+		}
+	}
+	if found == false {
+		t.Errorf("no SubPlan found in explain06")
+	}
+}
+
+func indexOfPlan(plans []*Plan, p *Plan) int {
+	for i := range plans {
+		if plans[i] == p {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestWalkSkipAndStop(t *testing.T) {
+	e := loadTestExplain(t, "../testdata/explain05.txt")
+
+	// Skipping the children of the top node still calls post for it
+	visited := 0
+	posted := 0
+	e.Walk(func(n *Node) error {
+		visited++
+		return SkipChildren
+	}, func(n *Node) error {
+		posted++
+		return nil
+	})
+	if visited != 1 || posted != 1 {
+		t.Errorf("SkipChildren on the top node visited %d and posted %d nodes, want 1 and 1", visited, posted)
+	}
+
+	// Any other error stops the walk and is returned
+	stop := errors.New("stop")
+	visited = 0
+	err := e.Walk(func(n *Node) error {
+		visited++
+		if visited == 3 {
+			return stop
+		}
+		return nil
+	}, nil)
+	if err != stop || visited != 3 {
+		t.Errorf("Walk returned %v after %d nodes, want stop after 3", err, visited)
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"html"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,6 +37,12 @@ var (
 
 	// Database constring
 	dbconnstring string
+
+	// Check parameters loaded from the profile in PROFILE
+	checkParams = plan.NewParams()
+
+	// Waivers loaded from the files in WAIVERS
+	checkWaivers = []plan.Waiver{}
 )
 
 // Generate random string
@@ -155,20 +163,20 @@ func InsertPlan(planText string) (PlanRecord, error) {
 func GenerateChecklistHtml() string {
 	checks := ""
 	checks += "<table class=\"table table-bordered table-condensed table-striped\">\n"
-	checks += "<tr><th class=\"text-left\">Description</th><th class=\"text-left\">Optimizer</th><th class=\"text-left\">Added</th></tr>"
-	for _, c := range plan.NODECHECKS {
-		scope := ""
-		for _, s := range c.Scope {
-			scope += fmt.Sprintf(" <span class=\"label optimizer-%[1]s\">%[1]s</span> ", s)
-		}
-		checks += fmt.Sprintf("<tr><td>%s</td><td class=\"nowrap\">%s</td><td class=\"nowrap\">%s</td></tr>", c.Description, scope, c.CreatedAt)
-	}
-	for _, c := range plan.EXPLAINCHECKS {
+	checks += "<tr><th class=\"text-left\">Id</th><th class=\"text-left\">Description</th><th class=\"text-left\">Category</th><th class=\"text-left\">Severity</th><th class=\"text-left\">Optimizer</th><th class=\"text-left\">Added</th></tr>"
+	for _, c := range plan.Checks() {
 		scope := ""
 		for _, s := range c.Scope {
 			scope += fmt.Sprintf(" <span class=\"label optimizer-%[1]s\">%[1]s</span> ", s)
 		}
-		checks += fmt.Sprintf("<tr><td>%s</td><td class=\"nowrap\">%s</td><td class=\"nowrap\">%s</td></tr>", c.Description, scope, c.CreatedAt)
+		checks += fmt.Sprintf("<tr id=\"check-%[1]s\"><td class=\"nowrap\"><code>%[1]s</code></td><td>%[2]s<br><small class=\"text-muted\">%[3]s</small></td><td class=\"nowrap\">%[4]s</td><td class=\"nowrap\">%[5]s</td><td class=\"nowrap\">%[6]s</td><td class=\"nowrap\">%[7]s</td></tr>",
+			c.Id,
+			html.EscapeString(c.Description),
+			html.EscapeString(c.Documentation),
+			c.Category,
+			c.Severity,
+			scope,
+			c.CreatedAt)
 	}
 	checks += "</table>\n"
 	return checks
@@ -266,15 +274,45 @@ func GenerateExplain(w http.ResponseWriter, r *http.Request, planRecord PlanReco
 	// Init the explain from string
 	err := explain.InitFromString(planRecord.Plantext, true)
 	if err != nil {
-		fmt.Fprintf(w, "<!DOCTYPE html><pre>Oops... we had a problem parsing the plan:\n--\n%s\n\n<a href=\"/\">Back</a></pre>", err)
+		fmt.Fprintf(w, "<!DOCTYPE html><pre>Oops... we had a problem parsing the plan:\n--\n%s\n\n<a href=\"/\">Back</a></pre>", html.EscapeString(err.Error()))
 		return
 	}
 
+	// Find nodes to highlight from the query parameters
+	filters, err := NodeFiltersFromRequest(r)
+	if err != nil {
+		fmt.Fprintf(w, "<!DOCTYPE html><pre>Oops... we had a problem with the node filter:\n--\n%s\n\n<a href=\"/\">Back</a></pre>", html.EscapeString(err.Error()))
+		return
+	}
+	// Run the requested checks with the requested parameters
+	checkConfig, err := CheckConfigFromRequest(r)
+	if err != nil {
+		fmt.Fprintf(w, "<!DOCTYPE html><pre>Oops... we had a problem with the check selection:\n--\n%s\n\n<a href=\"/\">Back</a></pre>", html.EscapeString(err.Error()))
+		return
+	}
+	// The values are echoed in the error so it must be escaped
+	if err := ParamsFromRequest(r, checkConfig.Params); err != nil {
+		fmt.Fprintf(w, "<!DOCTYPE html><pre>Oops... we had a problem with the check parameters:\n--\n%s\n\n<a href=\"/\">Back</a></pre>", html.EscapeString(err.Error()))
+		return
+	}
+	explain.ApplyFindings(explain.CheckWithConfig(checkConfig))
+
+	highlight := map[*plan.Node]bool{}
+	if len(filters) > 0 {
+		for _, n := range explain.FindNodes(filters...) {
+			highlight[n] = true
+		}
+	}
+
 	planTextEncoded := base64.StdEncoding.EncodeToString([]byte(planRecord.Plantext))
 
 	// Generate the plan HTML
 	//planHtml := explain.PrintPlanHtml()
-	planHtml := RenderExplainHtml(&explain)
+	planHtml := ""
+	if len(filters) > 0 {
+		planHtml += fmt.Sprintf("<p><span class=\"label label-info\">Filter</span> %d matching nodes highlighted</p>", len(highlight))
+	}
+	planHtml += RenderExplainHtml(&explain, highlight)
 
 	// Load HTML page
 	pageHtml := LoadHtml("templates/plan.html")
@@ -283,36 +321,207 @@ func GenerateExplain(w http.ResponseWriter, r *http.Request, planRecord PlanReco
 	fmt.Fprintf(w, pageHtml,
 		planHtml,
 		planTextEncoded,
-		planRecord.Ref)
+		planRecord.Ref,
+		RenderSummaryHtml(&explain)+RenderParamsHtml(checkConfig.Params))
+}
+
+// Build the check selection from the query parameters:
+//
+//	enable=spill-files,data-skew
+//	disable=cartesian-join
+//	optimizer=orca
+//	dialect=greenplum
+//
+// The parameters start from the loaded profile, see ParamsFromRequest.
+func CheckConfigFromRequest(r *http.Request) (plan.CheckConfig, error) {
+	c := plan.CheckConfig{
+		Enabled:   plan.ParseCheckIds(r.FormValue("enable")),
+		Disabled:  plan.ParseCheckIds(r.FormValue("disable")),
+		Optimizer: r.FormValue("optimizer"),
+		Dialect:   r.FormValue("dialect"),
+		Params:    checkParams.Clone(),
+		Waivers:   checkWaivers,
+	}
+
+	if err := c.Validate(); err != nil {
+		return c, err
+	}
+
+	return c, nil
 }
 
-// Render node for output to HTML
-func RenderNodeHtml(n *plan.Node, indent int) string {
-	indent += 1
+// Apply the check parameters of the parsed form, see CheckConfigFromRequest,
+// to params:
+//
+//	param.slice_count=300
+//	param.enable_nestloop=on
+func ParamsFromRequest(r *http.Request, params *plan.Params) error {
+	for key, values := range r.Form {
+		if strings.HasPrefix(key, "param.") == false || len(values) == 0 || values[0] == "" {
+			continue
+		}
+		if err := params.SetString(strings.TrimPrefix(key, "param."), values[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render a form to run the checks again with other parameters
+func RenderParamsHtml(params *plan.Params) string {
+	HTML := "<details class=\"params\"><summary>Check parameters</summary>"
+	HTML += "<form method=\"POST\" action=\"/plan/\" enctype=\"multipart/form-data\" onsubmit=\"setPlanText(this)\">"
+	HTML += "<input type=\"hidden\" name=\"action\" value=\"parse\"><input type=\"hidden\" name=\"plantext\" value=\"\">"
+	HTML += "<table class=\"table table-condensed summary-table\">"
+	for _, p := range plan.PARAMETERS {
+		HTML += fmt.Sprintf("<tr><th><code>%[1]s</code></th><td><input type=\"text\" class=\"form-control input-sm\" name=\"param.%[1]s\" value=\"%[2]s\"></td><td class=\"text-muted\">%[3]s (default %[4]s)</td></tr>",
+			p.Name,
+			strconv.FormatFloat(params.Float(p.Name), 'f', -1, 64),
+			html.EscapeString(p.Description),
+			strconv.FormatFloat(p.Default, 'f', -1, 64))
+	}
+	for _, name := range params.EnableGucNames() {
+		HTML += fmt.Sprintf("<tr><th><code>%[1]s</code></th><td><input type=\"text\" class=\"form-control input-sm\" name=\"param.%[1]s\" value=\"%[2]s\"></td><td class=\"text-muted\">Default value of the GUC</td></tr>",
+			name,
+			html.EscapeString(params.EnableGucs[name]))
+	}
+	HTML += "</table>"
+	HTML += "<button type=\"submit\" class=\"btn btn-primary btn-sm\">Run checks</button>"
+	HTML += "</form></details>"
+	return HTML
+}
+
+// Build node filters from the query parameters, all filters must match:
+//
+//	type=Hash Join or type=motion
+//	object=sales_1_prt_*
+//	operator=Seq Scan
+//	slice=3
+//	warnings=1
+//	mincost=1000
+//	mintime=500
+func NodeFiltersFromRequest(r *http.Request) ([]plan.NodeFilter, error) {
+	filters := []plan.NodeFilter{}
+
+	if v := r.FormValue("type"); v != "" {
+		if t, ok := plan.ParseNodeType(v); ok {
+			filters = append(filters, plan.ByType(t))
+		} else if c, ok := plan.ParseCategory(v); ok {
+			filters = append(filters, plan.ByCategory(c))
+		} else {
+			return nil, errors.New(fmt.Sprintf("Unknown node type \"%s\"", v))
+		}
+	}
+
+	if v := r.FormValue("object"); v != "" {
+		filters = append(filters, plan.ByObject(v))
+	}
+
+	if v := r.FormValue("operator"); v != "" {
+		filters = append(filters, plan.ByOperator(v))
+	}
+
+	if v := r.FormValue("slice"); v != "" {
+		slice, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid slice \"%s\"", v))
+		}
+		filters = append(filters, plan.BySlice(slice))
+	}
+
+	if v := r.FormValue("warnings"); v != "" && v != "0" {
+		filters = append(filters, plan.WithWarnings())
+	}
+
+	if v := r.FormValue("mincost"); v != "" {
+		cost, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid mincost \"%s\"", v))
+		}
+		filters = append(filters, plan.MinCost(cost))
+	}
+
+	if v := r.FormValue("mintime"); v != "" {
+		ms, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid mintime \"%s\"", v))
+		}
+		filters = append(filters, plan.MinTime(time.Duration(ms*float64(time.Millisecond))))
+	}
+
+	return filters, nil
+}
+
+// Render node and all nodes below it for output to HTML
+// Nodes in highlight are marked so they stand out
+func RenderNodeHtml(n *plan.Node, indent int, highlight map[*plan.Node]bool) string {
+	HTML := ""
+	colspan := 8
+	if n.IsAnalyzed == true {
+		colspan = 14
+	}
+
+	n.Walk(func(s *plan.Node) error {
+		// SubPlan name is rendered above its top node
+		if s != n && s.Plan != nil {
+			HTML += RenderPlanNameHtml(s.Plan, indent+1, colspan)
+			indent += 1
+		}
+		indent += 1
+		HTML += RenderNodeRowHtml(s, indent, highlight[s])
+		return nil
+	}, func(s *plan.Node) error {
+		indent -= 1
+		if s != n && s.Plan != nil {
+			indent -= 1
+		}
+		return nil
+	})
+
+	return HTML
+}
+
+// Render a single node as a table row
+// The row id and data-path attributes allow a node to be referenced directly
+func RenderNodeRowHtml(n *plan.Node, indent int, highlighted bool) string {
 	//indentString := strings.Repeat(" ", indent * indentDepth)
 	indentPixels := indent * indentDepth * 10
-	colspan := 8
 
-	HTML := fmt.Sprintf("<tr><td style=\"padding-left:%dpx\">", indentPixels)
+	rowClass := ""
+	if highlighted == true {
+		rowClass += " highlight"
+	}
+	if n.OnCriticalPath == true {
+		rowClass += " critical-path"
+	}
+	rowClass = strings.TrimSpace(rowClass)
 
-	if n.Slice > -1 {
+	HTML := fmt.Sprintf("<tr id=\"node-%d\" data-path=\"%s\" class=\"%s\"><td style=\"padding-left:%dpx\">", n.Id, n.Path, rowClass, indentPixels)
+
+	HTML += fmt.Sprintf("<a class=\"node-id\" href=\"#node-%[1]d\" title=\"Node %[1]d (path %[2]s)\">#%[1]d</a> ", n.Id, n.Path)
+
+	if n.Slice.Valid {
 		HTML += fmt.Sprintf("   <span class=\"label label-success\">Slice %d</span>\n",
-			n.Slice)
+			n.Slice.Value)
 	}
 	HTML += fmt.Sprintf("<strong>-> %s (cost=%.2f..%.2f rows=%d width=%d)</strong>\n",
 		//HTML += fmt.Sprintf("%s<strong>-> %s</strong>\n",
-		n.Operator,
+		html.EscapeString(n.Operator),
 		n.StartupCost,
 		n.TotalCost,
 		n.Rows,
 		n.Width)
 
 	for _, e := range n.ExtraInfo[1:] {
-		HTML += fmt.Sprintf("   %s\n", strings.Trim(e, " "))
+		HTML += fmt.Sprintf("   %s\n", html.EscapeString(strings.Trim(e, " ")))
 	}
 
 	for _, w := range n.Warnings {
-		HTML += fmt.Sprintf("   <span class=\"label label-danger\">WARNING: %s | %s</span>\n", w.Cause, w.Resolution)
+		HTML += fmt.Sprintf("   %s\n", RenderWarningHtml(w))
+	}
+
+	if n.OnCriticalPath == true {
+		HTML += "   <span class=\"label label-warning\">CRITICAL PATH</span>\n"
 	}
 
 	HTML += "</td>"
@@ -325,7 +534,7 @@ func RenderNodeHtml(n *plan.Node, indent int) string {
 			"<td class=\"text-right\">%.0f%%</td>"+
 			"<td class=\"text-right\">%.0f</td>"+
 			"<td class=\"text-right\">%d</td>\n",
-		n.Object,
+		html.EscapeString(n.Object),
 		n.ObjectType,
 		n.StartupCost,
 		n.NodeCost,
@@ -334,82 +543,162 @@ func RenderNodeHtml(n *plan.Node, indent int) string {
 		n.Rows)
 
 	if n.IsAnalyzed == true {
-		colspan = 13
-		if n.ActualRows > -1 {
-			HTML += fmt.Sprintf(
-				"<td class=\"text-right\">%.0f</td>"+
-					"<td class=\"text-right\">%s</td>"+
-					"<td class=\"text-right\">%s</td>"+
-					"<td class=\"text-right\">%s</td>"+
-					"<td class=\"text-right\">%s</td>\n",
-				n.ActualRows,
-				"-",
-				"-",
-				n.MaxSeg,
-				"-")
-			HTML += fmt.Sprintf(
-				"<td class=\"text-right\">%.0f</td>"+
-					"<td class=\"text-right\">%.0f</td>"+
-					"<td class=\"text-right\">%.0f%%</td>"+
-					"<td class=\"text-right\">%.0f</td>"+
-					"<td class=\"text-right\">%.0f</td>",
-				n.MsFirst,
-				n.MsNode,
-				n.MsPrct,
-				n.MsEnd,
-				n.MsOffset)
-		} else {
-			HTML += fmt.Sprintf("<td class=\"text-right\">%s</td>"+
-				"<td class=\"text-right\">%.0f</td>"+
-				"<td class=\"text-right\">%.0f</td>"+
-				"<td class=\"text-right\">%s</td>\n"+
-				"<td class=\"text-right\">%d</td>\n",
-				"-",
-				n.AvgRows,
-				n.MaxRows,
-				n.MaxSeg,
-				n.Workers)
-			HTML += fmt.Sprintf(
-				"<td class=\"text-right\">%.0f</td>"+
-					"<td class=\"text-right\">%.0f</td>"+
-					"<td class=\"text-right\">%.0f%%</td>"+
-					"<td class=\"text-right\">%.0f</td>"+
-					"<td class=\"text-right\">%.0f</td>",
-				n.MsFirst,
-				n.MsNode,
-				n.MsPrct,
-				n.MsEnd,
-				n.MsOffset)
+		maxSeg := n.MaxSeg
+		if maxSeg == "" {
+			maxSeg = "-"
 		}
+		// Values not present in the plan are rendered as "-"
+		HTML += fmt.Sprintf(
+			"<td class=\"text-right\">%s</td>"+
+				"<td class=\"text-right\">%s</td>"+
+				"<td class=\"text-right\">%s</td>"+
+				"<td class=\"text-right\">%s</td>"+
+				"<td class=\"text-right\">%s</td>"+
+				"<td class=\"text-right\">%s</td>\n",
+			n.ActualRows,
+			n.AvgRows,
+			n.MaxRows,
+			maxSeg,
+			n.Workers,
+			n.QError)
+		HTML += fmt.Sprintf(
+			"<td class=\"text-right\">%s</td>"+
+				"<td class=\"text-right\">%s</td>"+
+				"<td class=\"text-right\">%.0f%%</td>"+
+				"<td class=\"text-right\">%s</td>"+
+				"<td class=\"text-right\">%s</td>",
+			n.MsFirst,
+			n.MsNode,
+			n.MsPrct,
+			n.MsEnd,
+			n.MsOffset)
 	}
 
 	HTML += "</tr>"
 
-	// Render sub nodes
-	for _, s := range n.SubNodes {
-		HTML += RenderNodeHtml(s, indent)
+	return HTML
+}
+
+// Render a warning label, linking to the check that produced it
+func RenderWarningHtml(w plan.Warning) string {
+	labelClass := "label-danger"
+	if w.Severity == plan.SeverityInfo {
+		labelClass = "label-info"
 	}
 
-	for _, s := range n.SubPlans {
-		HTML += RenderPlanHtml(s, indent, colspan)
+	documentation := ""
+	if c, ok := plan.LookupCheck(w.CheckId); ok {
+		documentation = c.Documentation
 	}
 
+	return fmt.Sprintf("<span class=\"label %s\" title=\"%s\">%s: %s | %s <a href=\"/#check-%s\">%s</a></span>",
+		labelClass,
+		html.EscapeString(documentation),
+		strings.ToUpper(w.Severity.String()),
+		html.EscapeString(w.Cause),
+		html.EscapeString(w.Resolution),
+		html.EscapeString(w.CheckId),
+		html.EscapeString(w.CheckId))
+}
+
+// Render plan for output to HTML
+func RenderPlanHtml(p *plan.Plan, indent int, colspan int, highlight map[*plan.Node]bool) string {
+	HTML := RenderPlanNameHtml(p, indent+1, colspan)
+	HTML += RenderNodeHtml(p.TopNode, indent+1, highlight)
 	return HTML
 }
 
-// Render plan for output to console
-func RenderPlanHtml(p *plan.Plan, indent int, colspan int) string {
-	HTML := ""
-	indent += 1
-	//indentString := strings.Repeat(" ", indent * indentDepth)
+// Render the plan name row
+func RenderPlanNameHtml(p *plan.Plan, indent int, colspan int) string {
 	indentPixels := indent * indentDepth * 10
+	return fmt.Sprintf("<tr><td style=\"padding-left:%dpx;\"><strong>%s</strong></td><td colspan=\"%d\"></td></tr>", indentPixels, html.EscapeString(p.Name), colspan)
+}
+
+// Render the plan summary for output to HTML
+func RenderSummaryHtml(e *plan.Explain) string {
+	s := e.Summary
+
+	labelClass := "label-success"
+	if s.Rating() == "fair" {
+		labelClass = "label-warning"
+	} else if s.Rating() == "poor" {
+		labelClass = "label-danger"
+	}
+
+	HTML := "<div class=\"summary\">"
+	if len(e.Diagnostics) > 0 {
+		HTML += "<div class=\"alert alert-warning diagnostics\">"
+		HTML += "<strong>Plan diagnostics:</strong> the plan looks damaged or edited, the analysis may be wrong<ul>"
+		for _, d := range e.Diagnostics {
+			node := ""
+			if d.Node != nil {
+				node = fmt.Sprintf("<a href=\"#node-%d\">#%d %s</a>: ", d.Node.Id, d.Node.Id, html.EscapeString(d.Node.Operator))
+			}
+			HTML += fmt.Sprintf("<li>%s%s <span class=\"text-muted\">%s</span></li>", node, html.EscapeString(d.Message), d.Kind)
+		}
+		HTML += "</ul></div>"
+	}
+	HTML += fmt.Sprintf("<h4>Health score <span class=\"label %s\">%d (%s)</span></h4>", labelClass, s.Score, s.Rating())
+	HTML += "<table class=\"table table-condensed summary-table\">"
+	HTML += fmt.Sprintf("<tr><th>Warnings</th><td>%d (%d critical, %d warning, %d info)</td></tr>",
+		s.Warnings,
+		s.WarningsBySeverity[plan.SeverityCritical],
+		s.WarningsBySeverity[plan.SeverityWarning],
+		s.WarningsBySeverity[plan.SeverityInfo])
+	if s.Suppressed > 0 {
+		HTML += fmt.Sprintf("<tr><th>Suppressed</th><td><a href=\"#suppressed\">%d</a></td></tr>", s.Suppressed)
+	}
+	if fingerprint := e.QueryFingerprint(); fingerprint != "" {
+		HTML += fmt.Sprintf("<tr><th>Query fingerprint</th><td><code>%s</code></td></tr>", fingerprint)
+	}
+	HTML += fmt.Sprintf("<tr><th>Slices</th><td>%d</td></tr>", s.Slices)
+	HTML += fmt.Sprintf("<tr><th>Motions</th><td>%d</td></tr>", s.Motions)
+	if s.SpillNodes > 0 {
+		HTML += fmt.Sprintf("<tr><th>Spill</th><td>%s</td></tr>", s.SpillDescription())
+	}
+	if s.PeakMemory.Valid {
+		HTML += fmt.Sprintf("<tr><th>Peak memory</th><td>%s in slice %s %s</td></tr>", s.PeakMemory, s.PeakMemorySlice, s.PeakMemorySegment)
+	}
+	if s.Skew.SkewedNodes > 0 {
+		HTML += fmt.Sprintf("<tr><th>Skewed nodes</th><td>%d, %s the busiest in %d</td></tr>", s.Skew.SkewedNodes, s.Skew.Straggler, s.Skew.StragglerNodes)
+	}
+	if s.CoordinatorTime.Valid {
+		HTML += fmt.Sprintf("<tr><th>Coordinator time</th><td>%s (%.0f%% of runtime)</td></tr>", s.CoordinatorTime, s.CoordinatorPercent)
+	}
+	if len(s.PartitionedTables) > 0 {
+		HTML += "<tr><th>Partitioned tables</th><td>"
+		for _, t := range s.PartitionedTables {
+			HTML += fmt.Sprintf("%s<br>", html.EscapeString(t.String()))
+		}
+		HTML += "</td></tr>"
+	}
+	if len(s.TableStatistics) > 0 {
+		HTML += "<tr><th>Table statistics</th><td>"
+		for _, t := range s.TableStatistics {
+			HTML += fmt.Sprintf("%s<br>", html.EscapeString(t.String()))
+		}
+		HTML += "</td></tr>"
+	}
+	if len(s.TopNodesByTime) > 0 {
+		HTML += "<tr><th>Top nodes by time</th><td>"
+		for _, n := range s.TopNodesByTime {
+			HTML += fmt.Sprintf("<a href=\"#node-%d\">#%d</a> %s | %s<br>", n.Id, n.Id, n.MsNode, html.EscapeString(n.Operator))
+		}
+		HTML += "</td></tr>"
+	}
+	if len(s.TopNodesByCost) > 0 {
+		HTML += "<tr><th>Top nodes by cost</th><td>"
+		for _, n := range s.TopNodesByCost {
+			HTML += fmt.Sprintf("<a href=\"#node-%d\">#%d</a> %.2f | %s<br>", n.Id, n.Id, n.NodeCost, html.EscapeString(n.Operator))
+		}
+		HTML += "</td></tr>"
+	}
+	HTML += "</table></div>"
 
-	HTML += fmt.Sprintf("<tr><td style=\"padding-left:%dpx;\"><strong>%s</strong></td><td colspan=\"%d\"></td></tr>", indentPixels, p.Name, colspan)
-	HTML += RenderNodeHtml(p.TopNode, indent)
 	return HTML
 }
 
-func RenderExplainHtml(e *plan.Explain) string {
+func RenderExplainHtml(e *plan.Explain, highlight map[*plan.Node]bool) string {
 	HTML := ""
 	HTML += `<table class="table table-condensed table-striped table-bordered">`
 	HTML += "<tr>"
@@ -428,13 +717,14 @@ func RenderExplainHtml(e *plan.Explain) string {
 		"<th class=\"text-right\">Total</th>" +
 		"<th class=\"text-right\">Rows</th>"
 	if e.Plans[0].TopNode.IsAnalyzed == true {
-		HTMLTH1 += "<th colspan=\"5\" class=\"text-center\">Row Stats</th>"
+		HTMLTH1 += "<th colspan=\"6\" class=\"text-center\">Row Stats</th>"
 		HTMLTH2 += "<th class=\"text-right\">Actual</th>" +
 			"<th class=\"text-right\">Avg</th>" +
 			"<th class=\"text-right\">Max</th>" +
 			"<th class=\"text-right\">Seg</th>" +
-			"<th class=\"text-right\">Workers</th>"
-		HTMLTH1 += "<th colspan=\"5\" class=\"text-center\">Time Ms</th>"
+			"<th class=\"text-right\">Workers</th>" +
+			"<th class=\"text-right\">Q-Err</th>"
+		HTMLTH1 += "<th colspan=\"5\" class=\"text-center\">Time</th>"
 		HTMLTH2 += "<th class=\"text-right\">First</th>" +
 			"<th class=\"text-right\">Node</th>" +
 			"<th class=\"text-right\">Prct</th>" +
@@ -448,46 +738,87 @@ func RenderExplainHtml(e *plan.Explain) string {
 	HTML += HTMLTH1
 	HTML += HTMLTH2
 
-	HTML += RenderNodeHtml(e.Plans[0].TopNode, 0)
+	HTML += RenderNodeHtml(e.Plans[0].TopNode, 0, highlight)
 	HTML += `</table>`
 
 	if len(e.Warnings) > 0 {
 		HTML += fmt.Sprintf("<strong>Warnings:</strong>\n")
 		for _, w := range e.Warnings {
-			HTML += fmt.Sprintf("\t<span class=\"label label-danger\">%s | %s</span>\n", w.Cause, w.Resolution)
+			HTML += fmt.Sprintf("\t%s\n", RenderWarningHtml(w))
+		}
+	}
+
+	if len(e.Suppressed) > 0 {
+		HTML += fmt.Sprintf("<strong id=\"suppressed\">Suppressed warnings:</strong>\n")
+		for _, w := range e.Suppressed {
+			node := ""
+			if w.Node != nil {
+				node = fmt.Sprintf("<a href=\"#node-%d\">#%d</a> ", w.Node.Id, w.Node.Id)
+			}
+			HTML += fmt.Sprintf("\t%s<span class=\"label label-default\">%s | %s %s</span> waived by %s: %s\n",
+				node,
+				html.EscapeString(w.Cause),
+				html.EscapeString(w.Resolution),
+				html.EscapeString(w.CheckId),
+				html.EscapeString(w.Waiver.Source),
+				html.EscapeString(w.Waiver.Reason))
+		}
+	}
+
+	if len(e.CriticalPath) > 0 {
+		HTML += fmt.Sprintf("<strong>Critical path:</strong>\n")
+		for _, s := range e.CriticalPath {
+			HTML += fmt.Sprintf("\t<a href=\"#node-%d\">slice %d | %s | %s</a>\n", s.NodeId, s.Slice, plan.FormatDuration(s.Duration), html.EscapeString(s.Node.Operator))
+		}
+		HTML += fmt.Sprintf("\tstarted after %s\n", e.CriticalPathStart)
+		HTML += fmt.Sprintf("<strong>Critical path time by slice:</strong>\n")
+		for _, s := range e.CriticalPathBySlice() {
+			HTML += fmt.Sprintf("\tslice %d | %s\n", s.Slice, plan.FormatDuration(s.Duration))
+		}
+	}
+
+	if misestimates := e.WorstMisestimates(5); len(misestimates) > 0 {
+		HTML += fmt.Sprintf("<strong>Worst row estimates:</strong>\n")
+		for _, n := range misestimates {
+			HTML += fmt.Sprintf("\t<a href=\"#node-%d\">q-error %s | estimated %d, actual %s | %s</a>\n", n.Id, n.QError, n.Rows, n.ActualRowsPerSeg, html.EscapeString(n.Operator))
 		}
 	}
 
 	if len(e.SliceStats) > 0 {
 		HTML += fmt.Sprintf("<strong>Slice statistics:</strong>\n")
 		for _, stat := range e.SliceStats {
-			HTML += fmt.Sprintf("\t%s\n", stat)
+			HTML += fmt.Sprintf("\t%s\n", html.EscapeString(stat))
 		}
 	}
 
-	if e.MemoryUsed > 0 {
+	if e.MemoryUsed.Valid {
 		HTML += fmt.Sprintf("<strong>Statement statistics:</strong>\n")
-		HTML += fmt.Sprintf("\tMemory used: %d\n", e.MemoryUsed)
-		if e.MemoryWanted > 0 {
-			HTML += fmt.Sprintf("\tMemory wanted: %d\n", e.MemoryWanted)
+		HTML += fmt.Sprintf("\tMemory used: %s\n", e.MemoryUsed)
+		if e.MemoryWanted.Valid {
+			HTML += fmt.Sprintf("\tMemory wanted: %s\n", e.MemoryWanted)
 		}
 	}
 
 	if len(e.Settings) > 0 {
 		HTML += fmt.Sprintf("<strong>Settings:</strong>\n")
 		for _, setting := range e.Settings {
-			HTML += fmt.Sprintf("\t%s = %s\n", setting.Name, setting.Value)
+			HTML += fmt.Sprintf("\t%s = %s\n", html.EscapeString(setting.Name), html.EscapeString(setting.Value))
 		}
 	}
 
 	if e.OptimizerStatus != "" {
 		HTML += fmt.Sprintf("<strong>Optimizer status:</strong>\n")
-		HTML += fmt.Sprintf("\t%s\n", e.OptimizerStatus)
+		HTML += fmt.Sprintf("\t%s\n", html.EscapeString(e.OptimizerStatus))
 	}
 
-	if e.Runtime > 0 {
+	if e.Runtime.Valid {
 		HTML += fmt.Sprintf("<strong>Total runtime:</strong>\n")
-		HTML += fmt.Sprintf("\t%.0f ms\n", e.Runtime)
+		HTML += fmt.Sprintf("\t%s\n", e.Runtime)
+	}
+
+	if script := e.RemediationScript(); script != "" {
+		HTML += fmt.Sprintf("<strong>Remediation SQL:</strong> <button class=\"btn btn-default btn-xs\" onclick=\"copyRemediation(this)\">Copy</button>\n")
+		HTML += fmt.Sprintf("<pre id=\"remediation-sql\" class=\"remediation\">%s</pre>\n", html.EscapeString(script))
 	}
 
 	return HTML
@@ -505,6 +836,41 @@ func main() {
 	}
 	fmt.Printf("Binding to port %s\n", port)
 
+	// Load check parameters from a profile file
+	if profile := os.Getenv("PROFILE"); profile != "" {
+		params, err := plan.LoadParams(profile)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+		checkParams = params
+		fmt.Printf("Loaded check parameters from %s\n", profile)
+	}
+
+	// Load rule checks, a comma separated list of files
+	if rules := os.Getenv("RULES"); rules != "" {
+		for _, filename := range strings.Split(rules, ",") {
+			if err := plan.LoadRuleFile(strings.TrimSpace(filename)); err != nil {
+				fmt.Printf("%s\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Loaded rule checks from %s\n", filename)
+		}
+	}
+
+	// Load waivers, a comma separated list of files
+	if waivers := os.Getenv("WAIVERS"); waivers != "" {
+		for _, filename := range strings.Split(waivers, ",") {
+			w, err := plan.LoadWaiverFile(strings.TrimSpace(filename))
+			if err != nil {
+				fmt.Printf("%s\n", err)
+				os.Exit(1)
+			}
+			checkWaivers = append(checkWaivers, w...)
+			fmt.Printf("Loaded %d waivers from %s\n", len(w), filename)
+		}
+	}
+
 	dbconnstring = os.Getenv("CONSTRING")
 	if dbconnstring == "" {
 		fmt.Println("CONSTRING env variable not set. No database configured")